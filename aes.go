@@ -0,0 +1,296 @@
+package gozip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	aesCompression  Compression = 99
+	aesExtraFieldID             = 0x9901
+
+	aesVerifierLength       = 2
+	aesAuthenticationLength = 10
+	aesKeyIterations        = 1000
+
+	// AE-2 entries leave the CRC-32 zero and rely on the
+	// authentication code alone.
+	aesVersion2 = 2
+)
+
+var (
+	ErrInvalidAESExtraField = fmt.Errorf("Invalid AES extra field")
+	ErrAuthentication       = fmt.Errorf("AES authentication failed")
+)
+
+// aesExtraField is the WinZip AES extra field, 0x9901, which holds
+// what an entry's method would be if it weren't encrypted.
+type aesExtraField struct {
+	version     uint16
+	strength    uint8
+	compression Compression
+}
+
+func parseAESExtraField(extraField []byte) (*aesExtraField, error) {
+	data, ok := findExtraField(extraField, aesExtraFieldID)
+	if !ok || len(data) < 7 || string(data[2:4]) != "AE" {
+		return nil, ErrInvalidAESExtraField
+	}
+
+	strength := data[4]
+	if strength < 1 || strength > 3 {
+		return nil, ErrInvalidAESExtraField
+	}
+
+	return &aesExtraField{
+		version:     binary.LittleEndian.Uint16(data[0:2]),
+		strength:    strength,
+		compression: Compression(binary.LittleEndian.Uint16(data[5:7])),
+	}, nil
+}
+
+// keyLength is 16, 24 or 32 bytes for AES-128, 192 and 256. The salt is
+// half as long.
+func (a *aesExtraField) keyLength() int {
+	return 8 + 8*int(a.strength)
+}
+
+func pbkdf2SHA1(password, salt []byte, iterations, length int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var key []byte
+	var counter [4]byte
+	for block := uint32(1); len(key) < length; block++ {
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:length]
+}
+
+// aesCTR is AES in counter mode the way WinZip does it: the counter is
+// a little-endian integer starting at one, not big-endian like
+// crypto/cipher's CTR.
+type aesCTR struct {
+	block     cipher.Block
+	counter   [aes.BlockSize]byte
+	keystream [aes.BlockSize]byte
+	used      int
+}
+
+func newAESCTR(block cipher.Block) *aesCTR {
+	return &aesCTR{block: block, used: aes.BlockSize}
+}
+
+func (c *aesCTR) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.keystream[:], c.counter[:])
+			c.used = 0
+		}
+
+		dst[i] = src[i] ^ c.keystream[c.used]
+		c.used++
+	}
+}
+
+type aesReader struct {
+	r    io.Reader
+	full io.Reader
+	ctr  *aesCTR
+	mac  hash.Hash
+	err  error
+}
+
+// Read decrypts the entry's data and, once it has all been read,
+// checks the authentication code that follows it.
+func (ar *aesReader) Read(p []byte) (int, error) {
+	if ar.err != nil {
+		return 0, ar.err
+	}
+
+	n, err := ar.r.Read(p)
+	ar.mac.Write(p[:n])
+	ar.ctr.XORKeyStream(p[:n], p[:n])
+	if err != io.EOF {
+		return n, err
+	}
+
+	code := make([]byte, aesAuthenticationLength)
+	if _, err := io.ReadFull(ar.full, code); err != nil {
+		ar.err = err
+		return n, err
+	}
+
+	if !hmac.Equal(code, ar.mac.Sum(nil)[:aesAuthenticationLength]) {
+		ar.err = ErrAuthentication
+		return n, ar.err
+	}
+
+	ar.err = io.EOF
+	return n, io.EOF
+}
+
+// finish reads whatever of the data is left unread, so that the
+// authentication code is checked.
+func (ar *aesReader) finish() error {
+	_, err := io.Copy(ioutil.Discard, ar)
+	return err
+}
+
+// aesEntryReader checks an entry's authentication code once its
+// decompressor is done with it or closed. Decompressors such as flate
+// stop at the end of their stream without reading to the end of the
+// data, and the code covers all of it.
+type aesEntryReader struct {
+	io.ReadCloser
+	ar *aesReader
+}
+
+func (r *aesEntryReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if ferr := r.ar.finish(); ferr != nil {
+			return n, ferr
+		}
+	}
+
+	return n, err
+}
+
+func (r *aesEntryReader) Close() error {
+	err := r.ar.finish()
+	if cerr := r.ReadCloser.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// newAESReader checks password against the salt and verifier at the
+// start of the size bytes in r and returns a reader of the decrypted
+// data that follows them.
+func newAESReader(r io.Reader, size int64, password []byte, a *aesExtraField) (*aesReader, error) {
+	saltLength := a.keyLength() / 2
+	dataLength := size - int64(saltLength) - aesVerifierLength - aesAuthenticationLength
+	if dataLength < 0 {
+		return nil, ErrOverranBuffer
+	}
+
+	header := make([]byte, saltLength+aesVerifierLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	salt, verifier := header[:saltLength], header[saltLength:]
+
+	keyLength := a.keyLength()
+	keys := pbkdf2SHA1(password, salt, aesKeyIterations, 2*keyLength+aesVerifierLength)
+	if subtle.ConstantTimeCompare(keys[2*keyLength:], verifier) != 1 {
+		return nil, ErrPassword
+	}
+
+	block, err := aes.NewCipher(keys[:keyLength])
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesReader{
+		r:    io.LimitReader(r, dataLength),
+		full: r,
+		ctr:  newAESCTR(block),
+		mac:  hmac.New(sha1.New, keys[keyLength:2*keyLength]),
+	}, nil
+}
+
+type aesWriter struct {
+	w   io.Writer
+	ctr *aesCTR
+	mac hash.Hash
+}
+
+// newAESWriter writes a random salt and the password verifier to w and
+// returns a writer that encrypts to w what is written to it. Closing it
+// writes the authentication code and does not close w.
+func newAESWriter(w io.Writer, password []byte) (io.WriteCloser, error) {
+	keyLength := aes256Strength.keyLength()
+	salt := make([]byte, keyLength/2)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	keys := pbkdf2SHA1(password, salt, aesKeyIterations, 2*keyLength+aesVerifierLength)
+	block, err := aes.NewCipher(keys[:keyLength])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(append(salt, keys[2*keyLength:]...)); err != nil {
+		return nil, err
+	}
+
+	return &aesWriter{
+		w:   w,
+		ctr: newAESCTR(block),
+		mac: hmac.New(sha1.New, keys[keyLength:2*keyLength]),
+	}, nil
+}
+
+func (aw *aesWriter) Write(p []byte) (int, error) {
+	enc := make([]byte, len(p))
+	aw.ctr.XORKeyStream(enc, p)
+	aw.mac.Write(enc)
+	return aw.w.Write(enc)
+}
+
+func (aw *aesWriter) Close() error {
+	_, err := aw.w.Write(aw.mac.Sum(nil)[:aesAuthenticationLength])
+	return err
+}
+
+// aes256Strength describes the entries the Writer encrypts: AES-256
+// and AE-2, so no CRC-32 that would leak information about the
+// contents.
+var aes256Strength = &aesExtraField{version: aesVersion2, strength: 3}
+
+// aesExtraFieldFor returns the 0x9901 extra field record for an AES-256
+// entry whose data is compressed with compression.
+func aesExtraFieldFor(compression Compression) []byte {
+	var b byteWriter
+	b.uint16(aesExtraFieldID)
+	b.uint16(7)
+	b.uint16(aes256Strength.version)
+	b.WriteString("AE")
+	b.WriteByte(aes256Strength.strength)
+	b.uint16(uint16(compression))
+	return b.Bytes()
+}
@@ -0,0 +1,135 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAESAuthentication(t *testing.T) {
+	contents := testRandom(20000)
+
+	tests := []struct {
+		name   string
+		method Compression
+		tamper bool
+	}{
+		{"stored", NoCompression, false},
+		{"deflate", DeflateCompression, false},
+		{"stored tampered", NoCompression, true},
+		{"deflate tampered", DeflateCompression, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				w.SetEncryption(AESEncryption, "secret")
+				writeEntry(t, w, "file.txt", test.method, contents)
+			})
+
+			e := lookupEntry(t, readArchive(t, bs), "file.txt")
+			offset, err := e.DataOffset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.tamper {
+				// The authentication code is the last 10 bytes.
+				bs[offset+int64(e.CompressedSize)-1] ^= 0xFF
+			}
+
+			e = lookupEntry(t, readArchive(t, bs, WithPassword("secret")), "file.txt")
+			got, err := e.ReadAll()
+			if test.tamper {
+				if !errors.Is(err, ErrAuthentication) {
+					t.Fatalf("got %v, want ErrAuthentication", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, contents) {
+				t.Fatal("contents differ")
+			}
+		})
+	}
+}
+
+func TestAESInnerMethod(t *testing.T) {
+	contents := bytes.Repeat([]byte("under the encryption\n"), 100)
+
+	for _, method := range []Compression{NoCompression, DeflateCompression, ZstdCompression} {
+		t.Run(method.String(), func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				w.SetEncryption(AESEncryption, "secret")
+				writeEntry(t, w, "file.txt", method, contents)
+			})
+
+			// The entry's method is 99, and the AE field has the one its
+			// decrypted data is decompressed with.
+			e := lookupEntry(t, readArchive(t, bs, WithPassword("secret")), "file.txt")
+			if e.Method != aesCompression {
+				t.Errorf("got method %s, want %s", e.Method, aesCompression)
+			}
+			a, ok := e.AESExtraField()
+			if !ok {
+				t.Fatal("no AES extra field")
+			}
+			if a.Method != method {
+				t.Errorf("got inner method %s, want %s", a.Method, method)
+			}
+			got, err := e.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, contents) {
+				t.Error("contents differ")
+			}
+
+			// With the central directory's AE field naming a method
+			// nothing decompresses, the entry can't be read.
+			cd := bytes.Index(bs, []byte("PK\x01\x02"))
+			ae := cd + bytes.Index(bs[cd:], []byte("AE\x03"))
+			binary.LittleEndian.PutUint16(bs[ae+3:], 0xF00D)
+			e = lookupEntry(t, readArchive(t, bs, WithPassword("secret")), "file.txt")
+			if _, err := e.ReadAll(); !errors.Is(err, ErrUnsupportedCompression) {
+				t.Errorf("got %v, want %v", err, ErrUnsupportedCompression)
+			}
+		})
+	}
+}
+
+func TestAESAuthenticationOnClose(t *testing.T) {
+	contents := testRandom(20000)
+
+	for _, method := range []Compression{NoCompression, DeflateCompression} {
+		t.Run(method.String(), func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				w.SetEncryption(AESEncryption, "secret")
+				writeEntry(t, w, "file.txt", method, contents)
+			})
+
+			e := lookupEntry(t, readArchive(t, bs), "file.txt")
+			offset, err := e.DataOffset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			bs[offset+int64(e.CompressedSize)-1] ^= 0xFF
+
+			e = lookupEntry(t, readArchive(t, bs, WithPassword("secret")), "file.txt")
+			rc, err := e.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.CopyN(ioutil.Discard, rc, 100); err != nil {
+				t.Fatal(err)
+			}
+			if err := rc.Close(); !errors.Is(err, ErrAuthentication) {
+				t.Fatalf("got %v, want ErrAuthentication", err)
+			}
+		})
+	}
+}
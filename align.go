@@ -0,0 +1,57 @@
+package gozip
+
+const (
+	// alignmentExtraFieldID is the extra field Android's tools pad local
+	// headers with to align entries' data: the alignment, then zeros.
+	alignmentExtraFieldID = 0xD935
+	alignmentFieldLength  = 6
+)
+
+// SetAlignment makes the data of stored entries added from now on,
+// copied raw included, start a multiple of n bytes into the file, as
+// zipalign does to Android packages so their entries can be read in
+// place: 4 for most entries, or 4096 for shared libraries loaded
+// straight from the package. The local header's extra field is padded
+// to get there. Encrypted entries aren't aligned, and 0 stops aligning.
+func (w *Writer) SetAlignment(n int) error {
+	if n < 0 || n > 0xFFFF {
+		return ErrInvalidAlignment
+	}
+
+	w.alignment = n
+	return nil
+}
+
+// aligns reports whether the data of the entry cdr is aligned.
+func (w *Writer) aligns(cdr *centralDirectoryRecord) bool {
+	return w.alignment > 1 && cdr.compression == NoCompression && cdr.bitFlag&encryptedFlag == 0
+}
+
+// alignmentField returns the extra field record that pads a local
+// header of length bytes at offset so the data after it starts at a
+// multiple of n.
+func alignmentField(offset int64, length int, n int) []byte {
+	end := offset + int64(length) + alignmentFieldLength
+	padding := (int64(n) - end%int64(n)) % int64(n)
+
+	var b byteWriter
+	b.uint16(alignmentExtraFieldID)
+	b.uint16(uint16(2 + padding))
+	b.uint16(uint16(n))
+	b.Write(make([]byte, padding))
+	return b.Bytes()
+}
+
+// DataOffset returns where in the file the entry's data starts, after
+// its local header.
+func (e *Entry) DataOffset() (int64, error) {
+	if e.dataOffset == -1 {
+		dataOffset, err := localFileDataOffset(e.r, e.headerOffset)
+		if err != nil {
+			return 0, err
+		}
+		e.dataOffset = dataOffset
+	}
+
+	return e.dataOffset, nil
+}
@@ -0,0 +1,91 @@
+package gozip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestAlignedArchive(t *testing.T) {
+	contents := map[string][]byte{
+		"a.txt":         []byte("a"),
+		"b.txt":         []byte("bb\n"),
+		"lib/libc.so":   bytes.Repeat([]byte{0x7F}, 5),
+		"compressed.md": []byte("not aligned, since it's deflated\n"),
+	}
+	names := []string{"a.txt", "b.txt", "lib/libc.so", "compressed.md"}
+
+	for _, alignment := range []int{4, 4096} {
+		bs := writeArchive(t, func(w *Writer) {
+			if err := w.SetAlignment(alignment); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range names {
+				method := NoCompression
+				if name == "compressed.md" {
+					method = DeflateCompression
+				}
+				writeEntry(t, w, name, method, contents[name])
+			}
+		})
+
+		tests := []struct {
+			name string
+			bs   []byte
+		}{
+			{"central directory", bs},
+			// Without the central directory, entries are found by walking
+			// from one local header past its padded extra field and data to
+			// the next.
+			{"local headers", bs[:bytes.Index(bs, []byte("PK\x01\x02"))]},
+		}
+
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%d %s", alignment, test.name), func(t *testing.T) {
+				testAlignedEntries(t, readArchive(t, test.bs), alignment, names, contents)
+			})
+		}
+	}
+}
+
+// testAlignedEntries checks that r has the entries names with their
+// contents, and that only the stored ones are padded to alignment.
+func testAlignedEntries(t *testing.T, r *Reader, alignment int, names []string, contents map[string][]byte) {
+	t.Helper()
+
+	if len(r.Entries()) != len(names) {
+		t.Fatalf("got %d entries, want %d", len(r.Entries()), len(names))
+	}
+
+	for _, name := range names {
+		e := lookupEntry(t, r, name)
+		offset, err := e.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		local, err := e.LocalExtra()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, padded := findExtraField(local, alignmentExtraFieldID)
+		if stored := e.Method == NoCompression; stored != padded {
+			t.Errorf("%s: padded is %t, want %t", name, padded, stored)
+		} else if stored && offset%int64(alignment) != 0 {
+			t.Errorf("%s: data at %d isn't aligned to %d", name, offset, alignment)
+		}
+
+		rc, err := e.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, contents[name]) {
+			t.Errorf("%s: got %q, want %q", name, got, contents[name])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package gozip
+
+import (
+	"io"
+	"os"
+)
+
+// Append returns a Writer that adds entries to the archive in f, which
+// must be open for reading and writing. New entries are written over
+// the central directory, and Close writes it again after them, with
+// the new entries' records after the old ones and the archive comment
+// kept. An entry added with the same name as one already in the archive
+// replaces it in the central directory, though its old data stays in
+// the file. The central directory of a ZIP64 archive is written again
+// as ZIP64, as it is for one that comes to need it, and entries added
+// past 4GiB into the file, or bigger than that, get ZIP64 extra fields.
+func Append(f *os.File) (*Writer, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	cd, err := readCentralDirectory(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(cd.start(), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	w := NewWriter(f)
+	w.w.count = int64(cd.eocd.centralDirectoryOffset)
+	w.records = cd.records
+	w.existing = len(cd.records)
+	w.comment = cd.eocd.comment
+	w.file = f
+	w.zip64 = cd.zip64
+	return w, nil
+}
+
+// replaceExisting drops the records of entries named name that Append
+// found in the archive, for the entry about to be added in their place.
+func (w *Writer) replaceExisting(name string) {
+	kept := w.records[:0]
+	for _, cdr := range w.records[:w.existing] {
+		if cdr.fileName == name {
+			continue
+		}
+		kept = append(kept, cdr)
+	}
+	kept = append(kept, w.records[w.existing:]...)
+
+	w.existing -= len(w.records) - len(kept)
+	w.records = kept
+}
+
+// truncate cuts the file Append opened off at the end of what Close
+// wrote, in case the old central directory and comment ran longer.
+func (w *Writer) truncate() error {
+	if w.file == nil {
+		return nil
+	}
+
+	end, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	return w.file.Truncate(end)
+}
@@ -0,0 +1,250 @@
+package gozip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Mismatch is something an entry's local header or data descriptor
+// says that its central directory record doesn't, which readers that
+// trust one over the other would disagree about. Smuggling a file past
+// a scanner that reads one of them is done this way.
+type Mismatch struct {
+	Entry string
+	// Offset is where the entry's local header is.
+	Offset int64
+	// Field is what they disagree about: name, version needed, flags,
+	// method, modified time, CRC-32, compressed size or uncompressed
+	// size, the last three in the data descriptor too, local header
+	// for one the central directory has no record of, or central
+	// directory record for one walking the local headers doesn't find.
+	// The last two leave Local and Central empty.
+	Field          string
+	Local, Central string
+}
+
+func (m Mismatch) String() string {
+	if m.Local == "" && m.Central == "" {
+		if m.Field == "central directory record" {
+			return m.Field + " has no local header walking from the front"
+		}
+		return m.Field + " has no central directory record"
+	}
+
+	return fmt.Sprintf("%s is %s locally but %s in the central directory", m.Field, m.Local, m.Central)
+}
+
+// VerifyHeaders compares the entry's local header, and its data
+// descriptor if it has one, with its central directory record and
+// returns where they disagree. Entries read without a central directory
+// have nothing to compare.
+func (e *Entry) VerifyHeaders() ([]Mismatch, error) {
+	if e.record == nil {
+		return nil, nil
+	}
+
+	lfh, err := e.localHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := headerMismatches(e.record, lfh)
+	if lfh.bitFlag&dataDescriptorFlag != 0 {
+		end := e.headerOffset + int64(lfh.dataOffset) + int64(e.record.compressedSize)
+		dd, err := e.readDataDescriptor(end, hasZip64ExtraField(lfh.extraField))
+		if err != nil {
+			return nil, formatError(end, "data descriptor", err)
+		}
+		mismatches = append(mismatches, descriptorMismatches(e.record, dd)...)
+	}
+
+	for i := range mismatches {
+		mismatches[i].Entry = e.Name
+		mismatches[i].Offset = e.headerOffset
+	}
+
+	return mismatches, nil
+}
+
+// readDataDescriptor reads the entry's data descriptor, at offset after
+// its data, with 8 byte sizes if zip64.
+func (e *Entry) readDataDescriptor(offset int64, zip64 bool) (*dataDescriptor, error) {
+	bs := make([]byte, 24)
+	n, err := e.r.ReadAt(bs, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	bs = bs[:n]
+
+	i := 0
+	if signature, next, err := readUint32(bs, 0); err == nil && signature == dataDescriptorSignature {
+		i = next
+	}
+
+	crc32, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, ErrNoDataDescriptor
+	}
+	compressedSize, i, err := readDataDescriptorSize(bs, i, zip64)
+	if err != nil {
+		return nil, ErrNoDataDescriptor
+	}
+	uncompressedSize, _, err := readDataDescriptorSize(bs, i, zip64)
+	if err != nil {
+		return nil, ErrNoDataDescriptor
+	}
+
+	return &dataDescriptor{crc32, compressedSize, uncompressedSize}, nil
+}
+
+func descriptorMismatches(cdr *centralDirectoryRecord, dd *dataDescriptor) []Mismatch {
+	var mismatches []Mismatch
+	if dd.crc32 != cdr.crc32 {
+		mismatches = append(mismatches, Mismatch{Field: "data descriptor CRC-32", Local: fmt.Sprintf("%08x", dd.crc32), Central: fmt.Sprintf("%08x", cdr.crc32)})
+	}
+	if dd.compressedSize != cdr.compressedSize {
+		mismatches = append(mismatches, Mismatch{Field: "data descriptor compressed size", Local: fmt.Sprint(dd.compressedSize), Central: fmt.Sprint(cdr.compressedSize)})
+	}
+	if dd.uncompressedSize != cdr.uncompressedSize {
+		mismatches = append(mismatches, Mismatch{Field: "data descriptor uncompressed size", Local: fmt.Sprint(dd.uncompressedSize), Central: fmt.Sprint(cdr.uncompressedSize)})
+	}
+
+	return mismatches
+}
+
+// Audit cross-checks every entry's local header with the central
+// directory as VerifyHeaders does, and looks between entries for local
+// headers the central directory has no record of, which streaming
+// readers would take for entries when others wouldn't.
+func (r *Reader) Audit() ([]Mismatch, error) {
+	var mismatches []Mismatch
+	var entries []*Entry
+	for _, e := range r.entries {
+		if e.record == nil {
+			continue
+		}
+
+		m, err := e.VerifyHeaders()
+		if err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, m...)
+		entries = append(entries, e)
+	}
+
+	if len(entries) == 0 {
+		return mismatches, nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].headerOffset < entries[j].headerOffset
+	})
+
+	for i, e := range entries {
+		lfh, err := e.localHeader()
+		if err != nil {
+			return nil, err
+		}
+
+		start := e.headerOffset + int64(lfh.dataOffset) + int64(e.record.compressedSize)
+		end := r.cdStart
+		if i+1 < len(entries) {
+			end = entries[i+1].headerOffset
+		}
+		if end <= start {
+			continue
+		}
+
+		gap, err := readAt(e.r, start, end-start)
+		if err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, hiddenHeaders(gap, start)...)
+	}
+
+	return mismatches, nil
+}
+
+// CompareParses finds the entries both through the central directory
+// and by walking the local headers from the front, however r found
+// them, and returns where the two disagree: local headers the central
+// directory has no record of, records walking doesn't find a local
+// header for, and names that differ for the same local header. A
+// scanner that reads an archive one way can be shown different entries
+// from those an extractor that reads it the other way writes. Archives
+// without a central directory have nothing to compare.
+func (r *Reader) CompareParses() ([]Mismatch, error) {
+	cd, err := readCentralDirectory(r.src, r.size)
+	if err == ErrNoEndOfCentralDirectory {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := readAt(r.src, 0, r.size)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := parseLocalFileHeaders(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, len(cd.records))
+	central := map[int64]*Entry{}
+	for i, cdr := range cd.records {
+		entries[i] = r.centralEntry(r.src, cd, cdr)
+		central[entries[i].headerOffset] = entries[i]
+	}
+
+	var mismatches []Mismatch
+	walked := map[int64]bool{}
+	for _, lfh := range headers {
+		local := r.localEntry(r.src, lfh)
+		walked[local.headerOffset] = true
+		e, ok := central[local.headerOffset]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, Mismatch{Entry: local.Name, Offset: local.headerOffset, Field: "local header"})
+		case e.Name != local.Name:
+			mismatches = append(mismatches, Mismatch{Entry: e.Name, Offset: e.headerOffset, Field: "name", Local: local.Name, Central: e.Name})
+		}
+	}
+
+	for _, e := range entries {
+		if !walked[e.headerOffset] {
+			mismatches = append(mismatches, Mismatch{Entry: e.Name, Offset: e.headerOffset, Field: "central directory record"})
+		}
+	}
+
+	return mismatches, nil
+}
+
+// hiddenHeaders returns a Mismatch for each local header in gap, the
+// bytes at offset between entries.
+func hiddenHeaders(gap []byte, offset int64) []Mismatch {
+	var mismatches []Mismatch
+	signature := []byte("PK\x03\x04")
+	for i := 0; ; i += len(signature) {
+		j := bytes.Index(gap[i:], signature)
+		if j < 0 {
+			break
+		}
+		i += j
+
+		name, _, ok := localHeaderName(gap, i)
+		if !ok {
+			continue
+		}
+		mismatches = append(mismatches, Mismatch{
+			Entry:  name,
+			Offset: offset + int64(i),
+			Field:  "local header",
+		})
+	}
+
+	return mismatches
+}
@@ -0,0 +1,92 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestCompareParses(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, []byte("first\n"))
+		writeEntry(t, w, "b.txt", NoCompression, []byte("second\n"))
+	})
+	r := readArchive(t, bs)
+	b := lookupEntry(t, r, "b.txt")
+	aData, err := lookupEntry(t, r, "a.txt").DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := bytes.Index(bs, []byte("PK\x01\x02"))
+	cd2 := cd + 1 + bytes.Index(bs[cd+1:], []byte("PK\x01\x02"))
+	eocd := bytes.Index(bs, []byte("PK\x05\x06"))
+
+	tests := []struct {
+		name     string
+		damage   func(bs []byte) []byte
+		walked   []string
+		central  []string
+		mismatch Mismatch
+	}{
+		{"consistent", func(bs []byte) []byte { return bs }, []string{"a.txt", "b.txt"}, []string{"a.txt", "b.txt"}, Mismatch{}},
+		{"renamed in the central directory", func(bs []byte) []byte {
+			copy(bs[cd2+46:], "c.txt")
+			return bs
+		}, []string{"a.txt", "b.txt"}, []string{"a.txt", "c.txt"}, Mismatch{Entry: "c.txt", Offset: b.headerOffset, Field: "name", Local: "b.txt", Central: "c.txt"}},
+		{"local header without a record", func(bs []byte) []byte {
+			bs = append(bs[:cd2:cd2], bs[eocd:]...)
+			eocd := cd2
+			binary.LittleEndian.PutUint16(bs[eocd+8:], 1)
+			binary.LittleEndian.PutUint16(bs[eocd+10:], 1)
+			binary.LittleEndian.PutUint32(bs[eocd+12:], uint32(cd2-cd))
+			return bs
+		}, []string{"a.txt", "b.txt"}, []string{"a.txt"}, Mismatch{Entry: "b.txt", Offset: b.headerOffset, Field: "local header"}},
+		{"record without a local header", func(bs []byte) []byte {
+			// a.txt's local header claims b.txt as part of its data.
+			binary.LittleEndian.PutUint32(bs[18:], uint32(int64(cd)-aData))
+			return bs
+		}, []string{"a.txt"}, []string{"a.txt", "b.txt"}, Mismatch{Entry: "b.txt", Offset: b.headerOffset, Field: "central directory record"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			damaged := test.damage(append([]byte(nil), bs...))
+
+			for _, c := range []struct {
+				opts ParseOptions
+				want []string
+			}{
+				{ParseOptions{}, test.central},
+				{ParseOptions{LocalHeaders: true}, test.walked},
+			} {
+				r := readArchive(t, damaged, WithParseOptions(c.opts))
+				var names []string
+				for _, e := range r.Entries() {
+					names = append(names, e.Name)
+				}
+				if len(names) != len(c.want) {
+					t.Fatalf("%+v: got %v, want %v", c.opts, names, c.want)
+				}
+				for i := range names {
+					if names[i] != c.want[i] {
+						t.Fatalf("%+v: got %v, want %v", c.opts, names, c.want)
+					}
+				}
+
+				mismatches, err := r.CompareParses()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if test.mismatch == (Mismatch{}) {
+					if len(mismatches) != 0 {
+						t.Errorf("got %v", mismatches)
+					}
+					continue
+				}
+				if len(mismatches) != 1 || mismatches[0] != test.mismatch {
+					t.Errorf("got %+v, want %+v", mismatches, test.mismatch)
+				}
+			}
+		})
+	}
+}
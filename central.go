@@ -0,0 +1,477 @@
+package gozip
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	endOfCentralDirectoryLength  = 22
+	centralDirectoryRecordLength = 46
+	localFileHeaderLength        = 30
+	maxCommentLength             = 0xFFFF
+)
+
+var ErrNoEndOfCentralDirectory = fmt.Errorf("No end of central directory record found")
+
+type endOfCentralDirectory struct {
+	diskNumber             uint32
+	centralDirectoryDisk   uint32
+	diskEntries            uint64
+	entries                uint64
+	centralDirectorySize   uint64
+	centralDirectoryOffset uint64
+	comment                string
+}
+
+type centralDirectoryRecord struct {
+	versionMadeBy uint16
+	versionNeeded uint16
+	bitFlag       uint16
+	compression   Compression
+	lastModified  time.Time
+	// modifiedDate and modifiedTime are the MS-DOS date and time
+	// lastModified was read from.
+	modifiedDate      uint16
+	modifiedTime      uint16
+	crc32             uint32
+	compressedSize    uint64
+	uncompressedSize  uint64
+	diskNumberStart   uint32
+	internalAttrs     uint16
+	externalAttrs     uint32
+	localHeaderOffset uint64
+	fileName          string
+	extraField        []byte
+	// localExtraField is written in the local header instead of
+	// extraField if it isn't nil, for copies keeping the original's.
+	localExtraField []byte
+	comment         string
+	// zip64 is what the ZIP64 extra field gave, if anything.
+	zip64 *Zip64ExtraField
+}
+
+// findEndOfCentralDirectory scans backwards for the end of central
+// directory record. It sits at the very end of the archive unless the
+// archive has a comment, which can be up to 64KiB long.
+func findEndOfCentralDirectory(bs []byte) (int, error) {
+	last := len(bs) - endOfCentralDirectoryLength
+	first := last - maxCommentLength
+	if first < 0 {
+		first = 0
+	}
+
+	found := -1
+	for i := last; i >= first; i-- {
+		if !atEndOfCentralDirectory(bs, i) {
+			continue
+		}
+
+		// A comment length that accounts for exactly the rest of the
+		// buffer is the best evidence these four bytes are not just
+		// part of some entry's data or the comment itself. Failing
+		// that, take the last record whose comment at least fits, to
+		// tolerate junk appended after the archive.
+		commentLength, _, err := readUint16(bs, i+20)
+		if err != nil {
+			continue
+		}
+
+		end := i + endOfCentralDirectoryLength + int(commentLength)
+		if end == len(bs) {
+			return i, nil
+		}
+		if end < len(bs) && found == -1 {
+			found = i
+		}
+	}
+
+	if found == -1 {
+		return 0, ErrNoEndOfCentralDirectory
+	}
+
+	return found, nil
+}
+
+func parseEndOfCentralDirectory(bs []byte, start int) (*endOfCentralDirectory, error) {
+	// Skip the signature, findEndOfCentralDirectory already checked it.
+	i := start + 4
+
+	diskNumber, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	centralDirectoryDisk, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	diskEntries, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	centralDirectorySize, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	centralDirectoryOffset, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	commentLength, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, _, err := readString(bs, i, int(commentLength))
+	if err != nil {
+		return nil, err
+	}
+
+	return &endOfCentralDirectory{
+		diskNumber:             uint32(diskNumber),
+		centralDirectoryDisk:   uint32(centralDirectoryDisk),
+		diskEntries:            uint64(diskEntries),
+		entries:                uint64(entries),
+		centralDirectorySize:   uint64(centralDirectorySize),
+		centralDirectoryOffset: uint64(centralDirectoryOffset),
+		comment:                comment,
+	}, nil
+}
+
+func parseCentralDirectoryRecord(bs []byte, start int) (*centralDirectoryRecord, int, error) {
+	signature, i, err := readUint32(bs, start)
+	if err != nil {
+		return nil, 0, err
+	}
+	if signature != centralDirectorySignature {
+		return nil, 0, ErrNotZip
+	}
+
+	versionMadeBy, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	versionNeeded, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	bitFlag, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	compressionRaw, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lmTime, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lmDate, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	crc32, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	compressedSize, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	uncompressedSize, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fileNameLength, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	extraFieldLength, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	commentLength, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	diskNumberStart, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	internalAttrs, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	externalAttrs, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	localHeaderOffset, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fileName, i, err := readString(bs, i, int(fileNameLength))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	extraField, i, err := readBytes(bs, i, int(extraFieldLength))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	comment, i, err := readString(bs, i, int(commentLength))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cdr := &centralDirectoryRecord{
+		versionMadeBy:     versionMadeBy,
+		versionNeeded:     versionNeeded,
+		bitFlag:           bitFlag,
+		compression:       Compression(compressionRaw),
+		lastModified:      msdosTimeToGoTime(lmDate, lmTime),
+		modifiedDate:      lmDate,
+		modifiedTime:      lmTime,
+		crc32:             crc32,
+		compressedSize:    uint64(compressedSize),
+		uncompressedSize:  uint64(uncompressedSize),
+		diskNumberStart:   uint32(diskNumberStart),
+		internalAttrs:     internalAttrs,
+		externalAttrs:     externalAttrs,
+		localHeaderOffset: uint64(localHeaderOffset),
+		fileName:          fileName,
+		extraField:        extraField,
+		comment:           comment,
+	}
+
+	if err := applyZip64ExtraField(cdr); err != nil {
+		return nil, 0, err
+	}
+
+	return cdr, i, nil
+}
+
+// parseCentralDirectory parses entries records from bs, the central
+// directory, which starts at offset in the file.
+func parseCentralDirectory(bs []byte, offset int64, entries uint64) ([]*centralDirectoryRecord, error) {
+	// Don't trust the entry count to allocate more records than could
+	// fit in the buffer.
+	capacity := uint64(len(bs)) / centralDirectoryRecordLength
+	if entries < capacity {
+		capacity = entries
+	}
+
+	records := make([]*centralDirectoryRecord, 0, capacity)
+	i := 0
+	for uint64(len(records)) < entries {
+		cdr, next, err := parseCentralDirectoryRecord(bs, i)
+		if err != nil {
+			return nil, formatError(offset+int64(i), "central directory record", err)
+		}
+
+		records = append(records, cdr)
+		i = next
+	}
+
+	return records, nil
+}
+
+// localFileDataOffset returns where the data of the entry whose local
+// header starts at start begins. Only the header's name and extra field
+// lengths are needed for this, the rest is read from the central
+// directory.
+func localFileDataOffset(r io.ReaderAt, start int64) (int64, error) {
+	header, err := readAt(r, start, localFileHeaderLength)
+	if err != nil {
+		return 0, formatError(start, "local file header", err)
+	}
+
+	signature, _, err := readUint32(header, 0)
+	if err != nil {
+		return 0, err
+	}
+	if signature != localFileHeaderSignature {
+		return 0, formatError(start, "local file header", ErrNotZip)
+	}
+
+	fileNameLength, i, err := readUint16(header, 26)
+	if err != nil {
+		return 0, err
+	}
+
+	extraFieldLength, _, err := readUint16(header, i)
+	if err != nil {
+		return 0, err
+	}
+
+	return start + localFileHeaderLength + int64(fileNameLength) + int64(extraFieldLength), nil
+}
+
+// centralDirectory is an archive's end of central directory record,
+// ZIP64 or not, and the records of the central directory it points to.
+type centralDirectory struct {
+	eocd *endOfCentralDirectory
+	// zip64 is whether the archive has a ZIP64 end of central directory
+	// record.
+	zip64 bool
+	// base is where the archive starts in the file, which is after any
+	// data prepended to it. Offsets in the archive are relative to it.
+	base int64
+	// disks is where each volume starts when the archive is split,
+	// with offsets in the archive relative to the volume they are on.
+	disks []int64
+	// end is where the end of central directory records start, and
+	// eocdOffset where the last of them, the plain one, does.
+	end        int64
+	eocdOffset int64
+	records    []*centralDirectoryRecord
+}
+
+// start returns where the central directory starts in the file.
+func (cd *centralDirectory) start() int64 {
+	return cd.offset(cd.eocd.centralDirectoryDisk, cd.eocd.centralDirectoryOffset)
+}
+
+// offset returns where offset, on the volume disk, is in the file.
+func (cd *centralDirectory) offset(disk uint32, offset uint64) int64 {
+	if cd.disks == nil || int64(disk) >= int64(len(cd.disks)) {
+		return cd.base + int64(offset)
+	}
+
+	return cd.disks[disk] + int64(offset)
+}
+
+// readCentralDirectory finds and parses the central directory of the
+// archive of size bytes in r, failing with ErrNoEndOfCentralDirectory
+// if it has none.
+func readCentralDirectory(r io.ReaderAt, size int64) (*centralDirectory, error) {
+	cd, err := findCentralDirectory(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := readAt(r, cd.start(), int64(cd.eocd.centralDirectorySize))
+	if err != nil {
+		return nil, formatError(cd.start(), "central directory", err)
+	}
+
+	cd.records, err = parseCentralDirectory(bs, cd.start(), cd.eocd.entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return cd, nil
+}
+
+// findCentralDirectory is readCentralDirectory short of reading the
+// records.
+func findCentralDirectory(r io.ReaderAt, size int64) (*centralDirectory, error) {
+	if size == 0 {
+		return nil, ErrEmptyFile
+	}
+
+	tailStart, i, tail, err := findEndOfCentralDirectoryIn(r, size)
+	if err != nil {
+		return nil, err
+	}
+	eocdOffset := tailStart + int64(i)
+
+	eocd, err := parseEndOfCentralDirectory(tail, i)
+	if err != nil {
+		return nil, formatError(eocdOffset, "end of central directory record", err)
+	}
+
+	// The central directory ends where the record after it starts: the
+	// ZIP64 end of central directory record if there is one.
+	cd := &centralDirectory{eocd: eocd, end: eocdOffset, eocdOffset: eocdOffset}
+	zip64EOCD, zip64EOCDOffset, err := findZip64EndOfCentralDirectory(r, eocdOffset)
+	if err != nil {
+		return nil, formatError(eocdOffset-zip64EndOfCentralDirectoryLocatorLength, "ZIP64 end of central directory locator", err)
+	}
+	if zip64EOCD != nil {
+		zip64EOCD.comment = eocd.comment
+		cd.eocd = zip64EOCD
+		cd.zip64 = true
+		cd.end = zip64EOCDOffset
+	}
+	eocd = cd.eocd
+
+	// The last volume of a split archive alone is often smaller than its
+	// central directory, so tell it apart before checking the sizes.
+	v, split := r.(*volumes)
+	if !split && eocd.diskNumber != 0 {
+		return nil, ErrSplitArchive
+	}
+
+	if eocd.centralDirectorySize > uint64(cd.end) || eocd.centralDirectoryOffset > uint64(cd.end) {
+		return nil, formatError(cd.end, "end of central directory record", ErrOverranBuffer)
+	}
+
+	if split {
+		if uint64(eocd.diskNumber)+1 != uint64(len(v.starts)) || eocd.centralDirectoryDisk > eocd.diskNumber {
+			return nil, ErrMissingVolume
+		}
+		cd.disks = v.starts
+	} else {
+		// Offsets in the central directory are relative to the start
+		// of the archive, which is not the start of r when data has
+		// been prepended to it.
+		cd.base = cd.end - int64(eocd.centralDirectorySize) - int64(eocd.centralDirectoryOffset)
+		if cd.base < 0 {
+			return nil, formatError(cd.end, "end of central directory record", ErrOverranBuffer)
+		}
+	}
+
+	return cd, nil
+}
+
+// findEndOfCentralDirectoryIn reads the tail of the archive of size
+// bytes in r that the end of central directory record has to be in, and
+// returns where the tail starts, the record's index in it, and the tail.
+func findEndOfCentralDirectoryIn(r io.ReaderAt, size int64) (int64, int, []byte, error) {
+	tailStart := size - endOfCentralDirectoryLength - maxCommentLength
+	if tailStart < 0 {
+		tailStart = 0
+	}
+
+	tail, err := readAt(r, tailStart, size-tailStart)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	i, err := findEndOfCentralDirectory(tail)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return tailStart, i, tail, nil
+}
@@ -0,0 +1,64 @@
+// Command gozip-sfx is the stub gozip sfx puts in front of an archive to
+// make it extract itself. Run, it extracts the archive appended to it
+// into the current directory, or the one given with -d.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/eatonphil/gozip"
+)
+
+func main() {
+	dir := flag.String("d", ".", "directory to extract into")
+	list := flag.Bool("l", false, "list the files instead of extracting them")
+	flag.Parse()
+
+	self, err := os.Executable()
+	if err == nil {
+		err = run(self, *dir, *list)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// errNoArchive is what run fails with for a stub with nothing
+// appended.
+var errNoArchive = fmt.Errorf("no archive appended to it")
+
+// run extracts the archive appended to the stub at self into dir, or
+// only lists it.
+func run(self, dir string, list bool) error {
+	// Without an end of central directory record, Open falls back to
+	// walking local headers from the start of the file, which a stub
+	// doesn't start with, so either error means nothing was appended.
+	r, err := gozip.Open(self)
+	if errors.Is(err, gozip.ErrNoEndOfCentralDirectory) || errors.Is(err, gozip.ErrNotZip) {
+		return fmt.Errorf("%s has %w", self, errNoArchive)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if len(r.Entries()) == 0 {
+		return fmt.Errorf("%s has %w", self, errNoArchive)
+	}
+
+	for _, e := range r.Entries() {
+		fmt.Println(e.Name)
+		if list {
+			continue
+		}
+		if err := e.Extract(dir); err != nil {
+			return fmt.Errorf("%s: %v", e.Name, err)
+		}
+	}
+
+	return nil
+}
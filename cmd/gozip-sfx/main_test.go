@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eatonphil/gozip"
+)
+
+func TestRun(t *testing.T) {
+	stub := []byte("\x7fELF stub that is not an archive")
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   error
+	}{
+		{"stub only", nil, errNoArchive},
+		{"empty archive", map[string]string{}, errNoArchive},
+		{"archive", map[string]string{"a.txt": "first\n"}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			buf.Write(stub)
+			if test.files != nil {
+				w := gozip.NewWriter(&buf)
+				w.SetOffset(int64(len(stub)))
+				for name, contents := range test.files {
+					if err := w.WriteEntry(&gozip.Entry{Name: name, Method: gozip.DeflateCompression}, []byte(contents)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dir := t.TempDir()
+			self := filepath.Join(dir, "sfx")
+			if err := os.WriteFile(self, buf.Bytes(), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			out := filepath.Join(dir, "out")
+			err := run(self, out, false)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+
+			for name, contents := range test.files {
+				got, err := os.ReadFile(filepath.Join(out, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != contents {
+					t.Errorf("%s: got %q, want %q", name, got, contents)
+				}
+			}
+		})
+	}
+}
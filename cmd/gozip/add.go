@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/eatonphil/gozip"
+)
+
+// add writes the files and directories under paths into the archive at
+// out, creating it if there isn't one, after the entries already there.
+// Entries with the same names are replaced. With update, only files new
+// to the archive or modified since their entry are written.
+func add(out string, paths []string, opts createOptions, update bool) error {
+	f, err := os.OpenFile(out, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := gozip.NewWriter(f)
+	if info.Size() > 0 {
+		if update {
+			r, err := gozip.NewReader(f, info.Size())
+			if err != nil {
+				return err
+			}
+
+			modified := map[string]time.Time{}
+			for _, e := range r.Entries() {
+				modified[e.Name] = e.Modified
+			}
+			opts.skip = func(name string, info os.FileInfo) bool {
+				m, ok := modified[name]
+				// MS-DOS times are to 2 seconds.
+				return ok && (info.IsDir() || !info.ModTime().Truncate(2*time.Second).After(m))
+			}
+		}
+
+		w, err = gozip.Append(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writePaths(w, paths, opts); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func runAdd(args []string) error {
+	out, paths, opts, err := parseCreateArgs("add", args)
+	if err != nil {
+		return err
+	}
+
+	return add(out, paths, opts, false)
+}
+
+func runUpdate(args []string) error {
+	out, paths, opts, err := parseCreateArgs("update", args)
+	if err != nil {
+		return err
+	}
+
+	return add(out, paths, opts, true)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/eatonphil/gozip"
+)
+
+// alignFlag is --zipalign, which aligns to 4 bytes when given without
+// a value as zipalign does by default.
+type alignFlag int
+
+func (a *alignFlag) String() string {
+	return strconv.Itoa(int(*a))
+}
+
+func (a *alignFlag) Set(s string) error {
+	if s == "true" {
+		*a = 4
+		return nil
+	}
+	if s == "false" {
+		*a = 0
+		return nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 0xFFFF {
+		return fmt.Errorf("invalid alignment %q", s)
+	}
+
+	*a = alignFlag(n)
+	return nil
+}
+
+func (a *alignFlag) IsBoolFlag() bool {
+	return true
+}
+
+// checkAlignment reports on stdout each stored entry of r whose data
+// isn't a multiple of n bytes into the file, and resources.arsc if it
+// isn't stored, and reports whether there were none.
+func checkAlignment(r *gozip.Reader, n int) (bool, error) {
+	ok := true
+	for _, e := range r.Entries() {
+		if e.Name == "resources.arsc" && e.Method != gozip.NoCompression {
+			fmt.Printf("%s: compressed, not stored\n", e.Name)
+			ok = false
+		}
+		if e.Method != gozip.NoCompression || e.IsEncrypted() {
+			continue
+		}
+
+		offset, err := e.DataOffset()
+		if err != nil {
+			return false, err
+		}
+		if offset%int64(n) != 0 {
+			fmt.Printf("%s: data at %d, not a multiple of %d\n", e.Name, offset, n)
+			ok = false
+		}
+	}
+
+	return ok, nil
+}
+
+// align copies every entry of r to a new archive at out without
+// recompressing it, with stored entries aligned to n bytes.
+func align(r *gozip.Reader, out string, n int) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := gozip.NewWriter(f)
+	if err := w.SetAlignment(n); err != nil {
+		return err
+	}
+	if err := w.SetComment(r.Comment()); err != nil {
+		return err
+	}
+
+	for _, e := range r.Entries() {
+		if err := w.CopyRaw(e); err != nil {
+			return fmt.Errorf("%s: %s", e.Name, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func runAlign(args []string) error {
+	fs := newFlagSet("align")
+	n := fs.Int("alignment", 4, "what to align stored entries to, 4 or 4096 for shared libraries")
+	check := fs.Bool("check", false, "only check the archive is aligned")
+	args = parseArgs(fs, args)
+	if (*check && len(args) != 1) || (!*check && len(args) != 2) {
+		usage()
+	}
+	if *n < 1 || *n > 0xFFFF {
+		return fmt.Errorf("--alignment must be from 1 to 65535, not %d", *n)
+	}
+
+	r, err := gozip.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if !*check {
+		return align(r, args[1], *n)
+	}
+
+	ok, err := checkAlignment(r, *n)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/eatonphil/gozip"
+)
+
+func audit(r *gozip.Reader) (bool, error) {
+	mismatches, err := r.Audit()
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range mismatches {
+		fmt.Printf("%q at %d: %s\n", m.Entry, m.Offset, m)
+	}
+
+	return len(mismatches) == 0, nil
+}
+
+func runAudit(args []string) error {
+	fs := newFlagSet("audit")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	r, err := gozip.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ok, err := audit(r)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errFailed
+	}
+
+	return nil
+}
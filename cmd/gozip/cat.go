@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eatonphil/gozip"
+)
+
+// catEntries streams the named entries' contents to stdout in the order
+// given, decompressing only those entries.
+func catEntries(r *gozip.Reader, names []string) error {
+	for _, name := range names {
+		e, ok := r.Lookup(name)
+		if !ok {
+			return fmt.Errorf("no entry named %q", name)
+		}
+		if e.IsDir() {
+			return fmt.Errorf("%q is a directory", name)
+		}
+
+		if _, err := e.WriteTo(os.Stdout); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+func runCat(args []string) error {
+	fs := newFlagSet("cat")
+	af := addArchiveFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		usage()
+	}
+
+	r, err := openArchive(fs.Arg(0), af)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return catEntries(r, fs.Args()[1:])
+}
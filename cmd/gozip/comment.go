@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/eatonphil/gozip"
+)
+
+func runComment(args []string) error {
+	fs := newFlagSet("comment")
+	fs.Parse(args)
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		usage()
+	}
+
+	// Without the text, print the comment there is.
+	if fs.NArg() == 1 {
+		r, err := gozip.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		fmt.Println(r.Comment())
+		return nil
+	}
+
+	return gozip.SetArchiveComment(fs.Arg(0), fs.Arg(1))
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/eatonphil/gozip"
+)
+
+// tarFormat reports whether path is named as a tarball, and whether as
+// a gzipped one.
+func tarFormat(path string) (isTar, gzipped bool) {
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return true, true
+	case strings.HasSuffix(path, ".tar"):
+		return true, false
+	}
+
+	return false, false
+}
+
+// zipToTar writes every entry of r to out as a tarball, gzipped if
+// out's name says so. Each entry is decompressed straight into the
+// tarball, with its mode, owner and times, and symlinks stay symlinks.
+func zipToTar(r *gozip.Reader, out string) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if _, gzipped := tarFormat(out); gzipped {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	for _, e := range r.Entries() {
+		if err := writeTarEntry(tw, e); err != nil {
+			return fmt.Errorf("%s: %s", e.Name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+func writeTarEntry(tw *tar.Writer, e *gozip.Entry) error {
+	mode := e.Mode()
+	hdr := &tar.Header{
+		Name:       e.Name,
+		Mode:       int64(mode.Perm()),
+		ModTime:    e.Modified,
+		AccessTime: e.Accessed,
+		Format:     tar.FormatPAX,
+	}
+	if mode&fs.ModeSetuid != 0 {
+		hdr.Mode |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		hdr.Mode |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		hdr.Mode |= 01000
+	}
+	if o, ok := e.UnixOwner(); ok {
+		hdr.Uid, hdr.Gid = int(o.UID), int(o.GID)
+	}
+
+	switch {
+	case e.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		return tw.WriteHeader(hdr)
+	case e.IsSymlink():
+		target, err := e.Linkname()
+		if err != nil {
+			return err
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = target
+		return tw.WriteHeader(hdr)
+	}
+
+	hdr.Typeflag = tar.TypeReg
+	hdr.Size = int64(e.UncompressedSize)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := e.WriteTo(tw)
+	return err
+}
+
+// tarToZip writes every file, directory and symlink in the tarball at
+// in, gzipped or not, to out as a zip archive, compressing each with
+// method straight from the tarball. Tarballs can hold what zip can't,
+// hard links and devices among them, and those are left out with a
+// warning.
+func tarToZip(in, out string, method gozip.Compression, level int) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	br := bufio.NewReader(src)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := gozip.NewWriter(f)
+	if err := w.SetLevel(level); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := writeZipEntry(w, tr, hdr, method); err != nil {
+			return fmt.Errorf("%s: %s", hdr.Name, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeZipEntry(w *gozip.Writer, tr *tar.Reader, hdr *tar.Header, method gozip.Compression) error {
+	e := &gozip.Entry{
+		Name:     strings.TrimPrefix(hdr.Name, "./"),
+		Modified: hdr.ModTime,
+		Method:   method,
+	}
+	if e.Name == "" || e.Name == "/" {
+		return nil
+	}
+	e.SetMode(hdr.FileInfo().Mode())
+	owner := gozip.UnixOwner{UID: uint32(hdr.Uid), GID: uint32(hdr.Gid)}
+	extra, err := gozip.EncodeExtraFields(owner.ExtraField())
+	if err != nil {
+		return err
+	}
+	e.Extra = extra
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if !strings.HasSuffix(e.Name, "/") {
+			e.Name += "/"
+		}
+		return w.WriteEntry(e, nil)
+	case tar.TypeSymlink:
+		return w.WriteEntry(e, []byte(hdr.Linkname))
+	case tar.TypeReg, tar.TypeRegA:
+		ew, err := w.CreateEntry(e)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(ew, tr)
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "gozip: skipping %s, which zip can't hold\n", hdr.Name)
+	return nil
+}
+
+func runConvert(args []string) error {
+	fs := newFlagSet("convert")
+	af := addArchiveFlags(fs)
+	method := fs.String("method", "deflate", "compression method for a zip archive: store, deflate or zstd")
+	level := fs.Int("level", 6, "deflate level for a zip archive, from 0 to 9")
+	args = parseArgs(fs, args)
+	if len(args) != 2 {
+		usage()
+	}
+	in, out := args[0], args[1]
+
+	if inTar, _ := tarFormat(in); inTar {
+		m, ok := methods[*method]
+		if !ok {
+			return fmt.Errorf("unknown compression method %q", *method)
+		}
+		if *level < 0 || *level > 9 {
+			return fmt.Errorf("--level must be from 0 to 9, not %d", *level)
+		}
+		if *level == 0 && m == gozip.DeflateCompression {
+			m = gozip.NoCompression
+		}
+		return tarToZip(in, out, m, *level)
+	}
+
+	if outTar, _ := tarFormat(out); !outTar {
+		return fmt.Errorf("one of %s and %s must be a .tar, .tar.gz or .tgz", in, out)
+	}
+
+	r, err := openArchive(in, af)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return zipToTar(r, out)
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eatonphil/gozip"
+)
+
+// archiveName turns a path on disk into an entry name: slash separated
+// and relative, without leading .. components.
+func archiveName(path string) string {
+	name := filepath.ToSlash(filepath.Clean(path))
+	name = strings.TrimPrefix(name, filepath.VolumeName(path))
+	name = strings.TrimLeft(name, "/")
+	for strings.HasPrefix(name, "../") {
+		name = name[len("../"):]
+	}
+
+	return name
+}
+
+// methods are the compression methods create can write, by name.
+var methods = map[string]gozip.Compression{
+	"store":   gozip.NoCompression,
+	"deflate": gozip.DeflateCompression,
+	"zstd":    gozip.ZstdCompression,
+}
+
+type createOptions struct {
+	password     string
+	legacyCrypto bool
+	method       gozip.Compression
+	level        int
+	// jobs is how many files are compressed at once.
+	jobs int
+	// storeSuffixes are the name suffixes of files to store rather than
+	// compress, since they are compressed already.
+	storeSuffixes []string
+	// splitSize, if set, is the most each volume of the archive can
+	// hold.
+	splitSize int64
+	// reproducible, if set, is when every entry is modified, and has
+	// them written as gozip.Writer.SetReproducible does, sorted by name.
+	reproducible *time.Time
+	// align is what stored entries' data is aligned to, for Android.
+	align int
+	// profile, if set, is the format, epub or odf, whose mimetype entry
+	// comes first.
+	profile string
+	rep     *reporter
+	// skip, if set, reports whether the entry name for the file info
+	// describes can be left out.
+	skip func(name string, info os.FileInfo) bool
+}
+
+// entryName returns the name of the entry for the file at path, which
+// info describes, or false if it gets none. Symlinks are stored as
+// links, with the path they point to as their contents, rather than
+// followed. Directories get entries of their own so empty ones are
+// kept. Other special files are left out.
+func entryName(path string, info os.FileInfo) (string, bool) {
+	name := archiveName(path)
+	switch {
+	case info.IsDir():
+		if name == "." || name == "" {
+			return "", false
+		}
+		return name + "/", true
+	case info.Mode().IsRegular(), info.Mode()&os.ModeSymlink != 0:
+		return name, true
+	}
+
+	return "", false
+}
+
+// compressPath compresses the file at path, which info describes, for
+// w as the entry name. Regular files are streamed from disk rather than
+// read into memory, so only what they compress to is held, and that
+// only up to a point.
+func compressPath(w *gozip.Writer, path, name string, info os.FileInfo, opts createOptions) (*gozip.Compressed, error) {
+	e := &gozip.Entry{
+		Name:     name,
+		Modified: info.ModTime(),
+		Method:   opts.method,
+	}
+	// Android maps resources.arsc straight from the package, so it has
+	// to be stored, and aligned.
+	if hasSuffix(name, opts.storeSuffixes) || (opts.align > 0 && name == "resources.arsc") {
+		e.Method = gozip.NoCompression
+	}
+	e.SetMode(info.Mode())
+	if o, ok := owner(info); ok {
+		var err error
+		e.Extra, err = gozip.EncodeExtraFields(o.ExtraField())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case info.Mode().IsRegular():
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return w.CompressReader(e, f)
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return w.Compress(e, []byte(filepath.ToSlash(target)))
+	}
+
+	return w.Compress(e, nil)
+}
+
+// walked is a file or directory walkPaths found.
+type walked struct {
+	path, name string
+	info       os.FileInfo
+}
+
+// walkPaths calls fn with each file and directory under paths that gets
+// an entry, in the order they are walked or, when the archive is to be
+// reproducible, sorted by name once they all have been.
+func walkPaths(paths []string, opts createOptions, fn func(walked) error) error {
+	var found []walked
+	for _, path := range paths {
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			name, ok := entryName(path, info)
+			if !ok || (opts.skip != nil && opts.skip(name, info)) {
+				return nil
+			}
+
+			if opts.reproducible != nil {
+				found = append(found, walked{path, name, info})
+				return nil
+			}
+			return fn(walked{path, name, info})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].name < found[j].name
+	})
+	for _, f := range found {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compressed is what compressing a file came to.
+type compressed struct {
+	name string
+	c    *gozip.Compressed
+	err  error
+}
+
+// errStopped stops the walk in writePaths once writing has failed.
+var errStopped = fmt.Errorf("stopped")
+
+// writePaths writes the files and directories under paths to w. Up to
+// opts.jobs files are compressed at once while they are written in the
+// order they are walked, each waiting in pending for those before it.
+func writePaths(w *gozip.Writer, paths []string, opts createOptions) error {
+	if opts.password != "" {
+		encryption := gozip.AESEncryption
+		if opts.legacyCrypto {
+			encryption = gozip.ZipCryptoEncryption
+		}
+		w.SetEncryption(encryption, opts.password)
+	}
+	if err := w.SetLevel(opts.level); err != nil {
+		return err
+	}
+	if progress := opts.rep.progress(); progress != nil {
+		w.SetProgress(progress)
+	}
+	if opts.reproducible != nil {
+		w.SetReproducible(*opts.reproducible)
+	}
+	if err := w.SetAlignment(opts.align); err != nil {
+		return err
+	}
+	defer opts.rep.done()
+
+	pending := make(chan chan compressed, opts.jobs)
+	workers := make(chan struct{}, opts.jobs)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(pending)
+		queue := func(result chan compressed) bool {
+			select {
+			case pending <- result:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		err := walkPaths(paths, opts, func(f walked) error {
+			select {
+			case workers <- struct{}{}:
+			case <-done:
+				return errStopped
+			}
+			result := make(chan compressed, 1)
+			if !queue(result) {
+				return errStopped
+			}
+
+			go func() {
+				defer func() { <-workers }()
+				c, err := compressPath(w, f.path, f.name, f.info, opts)
+				result <- compressed{f.name, c, err}
+			}()
+			return nil
+		})
+		if err != nil && err != errStopped {
+			result := make(chan compressed, 1)
+			result <- compressed{err: err}
+			queue(result)
+		}
+	}()
+
+	// Once writing has failed, what is still pending is only waited for
+	// to remove any temporary files it was compressed to.
+	var err error
+	for result := range pending {
+		r := <-result
+		switch {
+		case err != nil:
+			if r.c != nil {
+				r.c.Close()
+			}
+		case r.err != nil:
+			err = r.err
+			close(done)
+		default:
+			opts.rep.file("adding", r.name)
+			if err = w.WriteCompressed(r.c); err != nil {
+				close(done)
+			}
+		}
+	}
+
+	return err
+}
+
+// profiles are the formats --profile packages for, with the media type
+// their mimetype entry has to hold, or "" if it varies by document.
+var profiles = map[string]string{
+	"epub": "application/epub+zip",
+	"odf":  "",
+}
+
+// writeMimetype writes the mimetype entry an archive in the format
+// profile starts with, from the file among paths that would be entered
+// as mimetype or, for EPUB, the one media type it can have. The file is
+// then left out of the rest of the archive.
+func writeMimetype(w *gozip.Writer, paths []string, opts *createOptions) error {
+	var path string
+	for _, p := range paths {
+		if archiveName(p) == "mimetype" {
+			path = p
+		} else if candidate := filepath.Join(p, "mimetype"); archiveName(candidate) == "mimetype" {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+			}
+		}
+	}
+
+	mimetype := profiles[opts.profile]
+	if path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// Editors end files in a newline that validators won't accept.
+		found := strings.TrimSpace(string(contents))
+		if mimetype != "" && found != mimetype {
+			return fmt.Errorf("%s holds %q, not %q", path, found, mimetype)
+		}
+		mimetype = found
+	}
+	if mimetype == "" {
+		return fmt.Errorf("%s documents need a mimetype file", opts.profile)
+	}
+
+	skip := opts.skip
+	opts.skip = func(name string, info os.FileInfo) bool {
+		return name == "mimetype" || (skip != nil && skip(name, info))
+	}
+
+	return w.WriteMimetype(mimetype)
+}
+
+// create writes an archive of paths to out, or to stdout if out is -.
+func create(out string, paths []string, opts createOptions) error {
+	if opts.splitSize > 0 {
+		return createSplit(out, paths, opts)
+	}
+
+	f := os.Stdout
+	if out == "-" {
+		if isTerminal(os.Stdout) {
+			return fmt.Errorf("not writing an archive to a terminal")
+		}
+		opts.rep.out = os.Stderr
+	} else {
+		var err error
+		f, err = os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+
+	w := gozip.NewWriter(f)
+	if opts.profile != "" {
+		if opts.reproducible != nil {
+			w.SetReproducible(*opts.reproducible)
+		}
+		if err := writeMimetype(w, paths, &opts); err != nil {
+			return err
+		}
+	}
+
+	if err := writePaths(w, paths, opts); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if out == "-" {
+		return nil
+	}
+
+	return f.Close()
+}
+
+// createSplit is create for an archive split into volumes.
+func createSplit(out string, paths []string, opts createOptions) error {
+	w, err := gozip.CreateSplit(out, opts.splitSize)
+	if err != nil {
+		return err
+	}
+
+	if err := writePaths(w, paths, opts); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// parseSize parses a size such as 100m, with an optional k, m, g or t
+// suffix for KiB, MiB, GiB or TiB, as zip -s takes them.
+func parseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	if i := strings.IndexAny(strings.ToLower(s), "kmgt"); i >= 0 && i == len(s)-1 {
+		multiplier = 1 << (10 * (strings.IndexByte("kmgt", strings.ToLower(s)[i]) + 1))
+		s = s[:i]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return n * multiplier, nil
+}
+
+// sourceDateEpoch returns the time in $SOURCE_DATE_EPOCH, the seconds
+// since the Unix epoch that reproducible builds date everything at, or
+// the earliest time an MS-DOS date can hold if it is unset.
+func sourceDateEpoch() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", epoch)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// hasSuffix reports whether name ends in one of suffixes, ignoring case
+// so .JPG is .jpg.
+func hasSuffix(name string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseCreateArgs parses the flags of the commands that write entries
+// from disk and returns the archive and the paths to write.
+func parseCreateArgs(name string, args []string) (string, []string, createOptions, error) {
+	fs := newFlagSet(name)
+	var opts createOptions
+	fs.StringVar(&opts.password, "password", "", "password to encrypt entries with, using AES-256")
+	fs.BoolVar(&opts.legacyCrypto, "legacy-crypto", false, "encrypt with the weak ZipCrypto cipher instead of AES")
+	method := fs.String("method", "deflate", "compression method: store, deflate or zstd")
+	fs.IntVar(&opts.level, "level", 6, "deflate level, from 0 to store to 9 for the smallest archive")
+	fs.IntVar(&opts.jobs, "jobs", runtime.GOMAXPROCS(0), "how many files to compress at once")
+	of := addOutputFlags(fs)
+	reproducible := fs.Bool("reproducible", false, "write the same archive from the same files every time, modified at $SOURCE_DATE_EPOCH or 1980-01-01")
+	align := alignFlag(0)
+	fs.Var(&align, "zipalign", "align stored entries to 4 bytes, or the given number, and store resources.arsc, for Android")
+	storeSuffixes := fs.String("store-suffixes", "", "comma-separated suffixes of files to store, such as .png,.jpg,.zip")
+	// Only create can write a split archive, since add and update
+	// rewrite one in place.
+	var splitSize string
+	if name == "create" {
+		fs.StringVar(&splitSize, "split-size", "", "split the archive into volumes of at most this size, such as 100m")
+		fs.StringVar(&opts.profile, "profile", "", "package as epub or odf, with the mimetype entry first and stored")
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || (opts.legacyCrypto && opts.password == "") {
+		usage()
+	}
+
+	var ok bool
+	opts.method, ok = methods[*method]
+	if !ok {
+		return "", nil, opts, fmt.Errorf("unknown compression method %q", *method)
+	}
+
+	if opts.level < 0 || opts.level > 9 {
+		return "", nil, opts, fmt.Errorf("--level must be from 0 to 9, not %d", opts.level)
+	}
+	// Level 0 stores, as it does for zip, rather than wrapping the
+	// data in deflate's stored blocks.
+	if opts.level == 0 && opts.method == gozip.DeflateCompression {
+		opts.method = gozip.NoCompression
+	}
+
+	var err error
+	opts.rep, err = of.reporter()
+	if err != nil {
+		return "", nil, opts, err
+	}
+
+	if opts.jobs < 1 {
+		return "", nil, opts, fmt.Errorf("--jobs must be at least 1, not %d", opts.jobs)
+	}
+
+	opts.align = int(align)
+
+	if *storeSuffixes != "" {
+		opts.storeSuffixes = strings.Split(*storeSuffixes, ",")
+	}
+
+	if *reproducible {
+		modified, err := sourceDateEpoch()
+		if err != nil {
+			return "", nil, opts, err
+		}
+		opts.reproducible = &modified
+	}
+
+	if _, ok := profiles[opts.profile]; opts.profile != "" && !ok {
+		return "", nil, opts, fmt.Errorf("--profile must be epub or odf, not %q", opts.profile)
+	}
+	if opts.profile != "" && splitSize != "" {
+		return "", nil, opts, fmt.Errorf("--profile can't be used with --split-size")
+	}
+	if fs.Arg(0) == "-" && splitSize != "" {
+		return "", nil, opts, fmt.Errorf("--split-size can't be used when writing to stdout")
+	}
+
+	if splitSize != "" {
+		opts.splitSize, err = parseSize(splitSize)
+		if err != nil {
+			return "", nil, opts, fmt.Errorf("--split-size: %v", err)
+		}
+	}
+
+	return fs.Arg(0), fs.Args()[1:], opts, nil
+}
+
+func runCreate(args []string) error {
+	out, paths, opts, err := parseCreateArgs("create", args)
+	if err != nil {
+		return err
+	}
+
+	return create(out, paths, opts)
+}
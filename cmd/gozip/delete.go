@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/eatonphil/gozip"
+)
+
+// deleteEntries rewrites the archive at path without the entries
+// matching patterns. Nothing is deleted if any pattern matches nothing,
+// since that is usually a typo.
+func deleteEntries(path string, patterns []string) error {
+	r, err := gozip.Open(path)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range patterns {
+		if len((&selection{include: []string{pattern}}).entries(r)) == 0 {
+			r.Close()
+			return fmt.Errorf("no entries match %q", pattern)
+		}
+	}
+	r.Close()
+
+	deleted, err := gozip.DeleteEntries(path, func(e *gozip.Entry) bool {
+		return matchAny(patterns, e.Name)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("deleted %d entries\n", deleted)
+	return nil
+}
+
+func runDelete(args []string) error {
+	fs := newFlagSet("delete")
+	args = parseArgs(fs, args)
+	if len(args) < 2 {
+		usage()
+	}
+
+	sel := selection{include: args[1:]}
+	if err := sel.check(); err != nil {
+		return err
+	}
+
+	return deleteEntries(args[0], sel.include)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/eatonphil/gozip"
+)
+
+// entriesByName maps the names of r's entries to the first entry with
+// each.
+func entriesByName(r *gozip.Reader) map[string]*gozip.Entry {
+	byName := map[string]*gozip.Entry{}
+	for _, e := range r.Entries() {
+		if _, ok := byName[e.Name]; !ok {
+			byName[e.Name] = e
+		}
+	}
+
+	return byName
+}
+
+// sameContents reports whether a and b decompress to the same bytes,
+// reading them side by side rather than into memory.
+func sameContents(a, b *gozip.Entry) (bool, error) {
+	ra, err := a.Open()
+	if err != nil {
+		return false, err
+	}
+	defer ra.Close()
+
+	rb, err := b.Open()
+	if err != nil {
+		return false, err
+	}
+	defer rb.Close()
+
+	bufA := make([]byte, 32<<10)
+	bufB := make([]byte, 32<<10)
+	for {
+		na, errA := io.ReadFull(ra, bufA)
+		nb, errB := io.ReadFull(rb, bufB)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return false, errA
+		}
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA != nil || errB != nil {
+			return errA != nil && errB != nil, nil
+		}
+	}
+}
+
+// changes describes how entry a became b, or is empty if it didn't
+// change. Sizes and CRC-32s are compared, and with content the
+// decompressed bytes too, which catches changes AES entries hide by not
+// recording a CRC-32.
+func changes(a, b *gozip.Entry, content bool) (string, error) {
+	switch {
+	case a.UncompressedSize != b.UncompressedSize:
+		return fmt.Sprintf("size %d -> %d", a.UncompressedSize, b.UncompressedSize), nil
+	case a.CRC32 != b.CRC32:
+		return fmt.Sprintf("crc32 %08x -> %08x", a.CRC32, b.CRC32), nil
+	case content && !a.IsDir():
+		same, err := sameContents(a, b)
+		if err != nil {
+			return "", err
+		}
+		if !same {
+			return "contents", nil
+		}
+	}
+
+	return "", nil
+}
+
+// diff prints the entries added to, removed from and changed between
+// archives a and b, one to a line prefixed with +, - or ~, and reports
+// whether there were any.
+func diff(a, b *gozip.Reader, content bool) (bool, error) {
+	before, after := entriesByName(a), entriesByName(b)
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	differ := false
+	for _, name := range names {
+		ea, inA := before[name]
+		eb, inB := after[name]
+		switch {
+		case !inA:
+			fmt.Printf("+ %s\n", name)
+		case !inB:
+			fmt.Printf("- %s\n", name)
+		default:
+			change, err := changes(ea, eb, content)
+			if err != nil {
+				return false, fmt.Errorf("%s: %s", name, err)
+			}
+			if change == "" {
+				continue
+			}
+			fmt.Printf("~ %s (%s)\n", name, change)
+		}
+		differ = true
+	}
+
+	return differ, nil
+}
+
+func runDiff(args []string) error {
+	fs := newFlagSet("diff")
+	content := fs.Bool("content", false, "compare the contents of entries whose size and CRC-32 match")
+	af := addArchiveFlags(fs)
+	args = parseArgs(fs, args)
+	if len(args) != 2 {
+		usage()
+	}
+
+	a, err := openArchive(args[0], af)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	b, err := openArchive(args[1], af)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	differ, err := diff(a, b, *content)
+	if err != nil {
+		return err
+	}
+
+	// Exit non-zero if the archives differ, as diff does.
+	if differ {
+		return errFailed
+	}
+
+	return nil
+}
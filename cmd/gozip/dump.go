@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eatonphil/gozip"
+)
+
+// cat writes every entry's contents to stdout back to back, byte for
+// byte, with nothing around them, failing on the first entry whose
+// contents don't match its CRC-32.
+func cat(r *gozip.Reader) error {
+	for _, e := range r.Entries() {
+		if e.IsDir() {
+			continue
+		}
+
+		if _, err := e.WriteTo(os.Stdout); err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func runDump(args []string) error {
+	fs := newFlagSet("gozip")
+	binarySafe := fs.Bool("binary-safe", false, "write raw entry contents only")
+	af := addArchiveFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+	// Without --binary-safe this lists, decompressing nothing.
+	af.countOnly = !*binarySafe
+
+	r, err := openArchive(fs.Arg(0), af)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if *binarySafe {
+		return cat(r)
+	}
+
+	list(r.Entries())
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/eatonphil/gozip"
+)
+
+// testArchive returns an archive of the files in contents, deflated.
+func testArchive(t *testing.T, contents map[string]string, names ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gozip.NewWriter(&buf)
+	for _, name := range names {
+		e := &gozip.Entry{Name: name, Method: gozip.DeflateCompression}
+		if err := w.WriteEntry(e, []byte(contents[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// captureStdout returns what fn writes to stdout.
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = stdout }()
+	fn()
+
+	out, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestCat(t *testing.T) {
+	contents := map[string]string{"a.txt": "first\n", "b.txt": "second\n"}
+
+	tests := []struct {
+		name    string
+		corrupt bool
+	}{
+		{"intact", false},
+		{"corrupt CRC", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := testArchive(t, contents, "a.txt", "b.txt")
+			if test.corrupt {
+				// Flip the CRC-32 of b.txt's central directory record.
+				cd := bytes.LastIndex(bs, []byte("PK\x01\x02"))
+				bs[cd+16] ^= 0xFF
+			}
+			r, err := gozip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var catErr error
+			out := captureStdout(t, func() { catErr = cat(r) })
+
+			if string(out) != "first\nsecond\n" {
+				t.Errorf("got %q", out)
+			}
+			if test.corrupt != errors.Is(catErr, gozip.ErrChecksum) {
+				t.Errorf("got error %v", catErr)
+			}
+		})
+	}
+}
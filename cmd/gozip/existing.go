@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/eatonphil/gozip"
+)
+
+// existingPolicy is what extract does when a file is already where an
+// entry would go.
+type existingPolicy int
+
+const (
+	// askExisting prompts when stdin is a terminal and fails otherwise.
+	askExisting existingPolicy = iota
+	overwriteExisting
+	skipExisting
+	// freshenExisting replaces the file only if the entry is newer, so
+	// a file modified at the same time as the entry is kept.
+	freshenExisting
+	// renameExisting extracts the entry under a numbered name instead.
+	renameExisting
+)
+
+// existingFlags are the flags that pick a policy, each on its own.
+type existingFlags struct {
+	overwrite, skip, freshen, rename bool
+}
+
+func (f *existingFlags) policy() (existingPolicy, error) {
+	policy := askExisting
+	count := 0
+	for _, flag := range []struct {
+		set    bool
+		policy existingPolicy
+	}{
+		{f.overwrite, overwriteExisting},
+		{f.skip, skipExisting},
+		{f.freshen, freshenExisting},
+		{f.rename, renameExisting},
+	} {
+		if flag.set {
+			policy = flag.policy
+			count++
+		}
+	}
+
+	if count > 1 {
+		return 0, fmt.Errorf("only one of --overwrite, --skip-existing, --freshen (or --keep-newer) and --rename can be given")
+	}
+
+	return policy, nil
+}
+
+// existing decides what to do about files in the way of entries, and
+// remembers answers to the prompt that apply to all of them.
+type existing struct {
+	policy existingPolicy
+	stdin  *bufio.Reader
+}
+
+// isTerminal reports whether f is a terminal rather than a file, pipe
+// or the null device, which is a character device too.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// resolve returns the name to extract e as under dir, given that name
+// is taken by the file info describes, or false to skip e.
+func (x *existing) resolve(e *gozip.Entry, dir, name string, info os.FileInfo) (string, bool, error) {
+	policy := x.policy
+	if policy == askExisting {
+		if !isTerminal(os.Stdin) {
+			return "", false, fmt.Errorf("%s already exists; pass --overwrite, --skip-existing, --freshen, --keep-newer or --rename", name)
+		}
+
+		var err error
+		policy, err = x.ask(name)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	switch policy {
+	case skipExisting:
+		return "", false, nil
+	case freshenExisting:
+		return name, e.Modified.After(info.ModTime()), nil
+	case renameExisting:
+		return availableName(e, dir, name)
+	}
+
+	return name, true, nil
+}
+
+// ask prompts for what to do about name, as unzip does.
+func (x *existing) ask(name string) (existingPolicy, error) {
+	if x.stdin == nil {
+		x.stdin = bufio.NewReader(os.Stdin)
+	}
+
+	for {
+		fmt.Fprintf(os.Stderr, "replace %s? [y]es, [n]o, [A]ll, [N]one, [r]ename: ", name)
+		answer, err := x.stdin.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+
+		switch strings.TrimSpace(answer) {
+		case "y":
+			return overwriteExisting, nil
+		case "n":
+			return skipExisting, nil
+		case "A":
+			x.policy = overwriteExisting
+			return overwriteExisting, nil
+		case "N":
+			x.policy = skipExisting
+			return skipExisting, nil
+		case "r":
+			return renameExisting, nil
+		}
+	}
+}
+
+// availableName returns name with the lowest numeric suffix, before its
+// extension, that nothing under dir has yet: a.txt becomes a-1.txt.
+func availableName(e *gozip.Entry, dir, name string) (string, bool, error) {
+	ext := path.Ext(name)
+	if ext == name || strings.HasSuffix(name, "/"+ext) {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		p, err := e.ExtractPathAs(dir, candidate)
+		if err != nil {
+			return "", false, err
+		}
+
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
+			return candidate, true, nil
+		} else if err != nil {
+			return "", false, err
+		}
+	}
+}
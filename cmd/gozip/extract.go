@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"runtime"
+	"sync"
+
+	"github.com/eatonphil/gozip"
+)
+
+type extractOptions struct {
+	dir string
+	// junkPaths extracts every file straight into dir, without the
+	// directories in its name.
+	junkPaths bool
+	existing  existingPolicy
+	// jobs is how many files are written at once.
+	jobs int
+	rep  *reporter
+	// manifest, if set, records what became of each entry.
+	manifest *manifest
+}
+
+// extractor extracts files with up to jobs of them being written at
+// once, and keeps the first error one of them fails with.
+type extractor struct {
+	ctx      context.Context
+	rep      *reporter
+	manifest *manifest
+	workers  chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	err     error
+	blocked bool
+}
+
+func newExtractor(ctx context.Context, jobs int, rep *reporter, m *manifest) *extractor {
+	return &extractor{ctx: ctx, rep: rep, manifest: m, workers: make(chan struct{}, jobs)}
+}
+
+// report records err, from extracting e as name, and reports whether to
+// go on.
+// Entries that would escape the directory are skipped and reported, and
+// the rest extracted anyway.
+func (x *extractor) report(e *gozip.Entry, name string, err error) bool {
+	x.manifest.add(e, name, err)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	switch {
+	case err == gozip.ErrUnsafePath:
+		x.rep.warn("gozip: blocked %q: %s\n", e.Name, err)
+		x.blocked = true
+	case err != nil && x.err == nil:
+		x.err = err
+	}
+
+	return x.err == nil
+}
+
+// ok reports whether nothing has failed yet.
+func (x *extractor) ok() bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.err == nil
+}
+
+// extract starts extracting e as name in the background once a worker
+// is free.
+func (x *extractor) extract(e *gozip.Entry, dir, name string) {
+	x.workers <- struct{}{}
+	x.wg.Add(1)
+	go func() {
+		defer x.wg.Done()
+		defer func() { <-x.workers }()
+		x.report(e, name, e.ExtractAsContext(x.ctx, dir, name))
+	}()
+}
+
+// wait waits for the files being extracted and returns what went wrong.
+func (x *extractor) wait() error {
+	x.wg.Wait()
+	return x.err
+}
+
+// extract extracts entries until ctx is done, which leaves no partly
+// written files behind.
+func extract(ctx context.Context, entries []*gozip.Entry, opts extractOptions) error {
+	dir := opts.dir
+
+	// Directories are created as they come, and files are written by
+	// workers meanwhile. Two entries extracted to the same place are
+	// done one after the other, by waiting for the workers whenever a
+	// file was already claimed. Symlinks are created last, once nothing
+	// else is being written, as unzip does, so no file can be written
+	// through one before it has been checked.
+	x := newExtractor(ctx, opts.jobs, opts.rep, opts.manifest)
+	defer opts.rep.done()
+	if opts.rep.bar != nil {
+		for _, e := range entries {
+			opts.rep.bar.total += e.UncompressedSize
+		}
+	}
+
+	ex := &existing{policy: opts.existing}
+	claimed := map[string]bool{}
+	var symlinks []*gozip.Entry
+	var symlinkNames []string
+	for _, e := range entries {
+		if !x.ok() {
+			break
+		}
+
+		name := e.Name
+		if opts.junkPaths {
+			if e.IsDir() {
+				opts.manifest.skip(e, "directories are left out with -j")
+				continue
+			}
+			name = path.Base(name)
+		}
+
+		if e.IsDir() {
+			opts.rep.file("creating", name)
+			if !x.report(e, name, e.ExtractAsContext(ctx, dir, name)) {
+				break
+			}
+			continue
+		}
+
+		p, err := e.ExtractPathAs(dir, name)
+		if err != nil {
+			if !x.report(e, name, err) {
+				break
+			}
+			continue
+		}
+		if claimed[p] {
+			if x.wait() != nil {
+				break
+			}
+			claimed = map[string]bool{}
+		}
+
+		// Directories are merged into ones already there, but
+		// anything else in the way is up to the policy.
+		if info, err := os.Lstat(p); err == nil && !info.IsDir() {
+			var ok bool
+			name, ok, err = ex.resolve(e, dir, name, info)
+			if err != nil {
+				x.report(e, e.Name, err)
+				break
+			}
+			if !ok {
+				reason := "already exists"
+				if ex.policy == freshenExisting {
+					reason = "not newer than the file already there"
+				}
+				opts.manifest.skip(e, reason)
+				continue
+			}
+			if p, err = e.ExtractPathAs(dir, name); err != nil {
+				if !x.report(e, name, err) {
+					break
+				}
+				continue
+			}
+		}
+		claimed[p] = true
+
+		if e.IsSymlink() {
+			symlinks = append(symlinks, e)
+			symlinkNames = append(symlinkNames, name)
+			continue
+		}
+		opts.rep.file("extracting", name)
+		x.extract(e, dir, name)
+	}
+
+	if err := x.wait(); err != nil {
+		return err
+	}
+	for i, e := range symlinks {
+		opts.rep.file("linking", symlinkNames[i])
+		if !x.report(e, symlinkNames[i], e.ExtractAsContext(ctx, dir, symlinkNames[i])) {
+			return x.err
+		}
+	}
+
+	// Writing files into a directory updates its modification time and
+	// needs it writable, so restore directory times and permissions
+	// once everything has been written, deepest first.
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if !e.IsDir() || opts.junkPaths {
+			continue
+		}
+
+		path, err := e.ExtractPath(dir)
+		if err == gozip.ErrUnsafePath {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(path, e.Mode().Perm()); err != nil {
+			return err
+		}
+
+		atime := e.Accessed
+		if atime.IsZero() {
+			atime = e.Modified
+		}
+		if err := os.Chtimes(path, atime, e.Modified); err != nil {
+			return err
+		}
+	}
+
+	if x.blocked {
+		return errFailed
+	}
+
+	return nil
+}
+
+func runExtract(args []string) error {
+	fs := newFlagSet("extract")
+	af := addArchiveFlags(fs)
+	symlinks := fs.String("symlinks", "on", "recreate symlinks, or write them as files holding the target: on or off")
+	var eo extractOptions
+	fs.StringVar(&eo.dir, "d", ".", "directory to extract into")
+	fs.BoolVar(&eo.junkPaths, "j", false, "extract files straight into the directory, leaving out their paths")
+	fs.BoolVar(&eo.junkPaths, "junk-paths", false, "same as -j")
+	fs.IntVar(&eo.jobs, "jobs", runtime.GOMAXPROCS(0), "how many files to write at once")
+	manifestPath := fs.String("manifest", "", "write what became of each entry to this file as JSON")
+	var ef existingFlags
+	fs.BoolVar(&ef.overwrite, "overwrite", false, "replace files that already exist")
+	fs.BoolVar(&ef.skip, "skip-existing", false, "leave files that already exist alone")
+	fs.BoolVar(&ef.freshen, "freshen", false, "replace files that already exist only with newer entries")
+	fs.BoolVar(&ef.freshen, "keep-newer", false, "same as --freshen")
+	fs.BoolVar(&ef.rename, "rename", false, "extract entries whose files already exist under numbered names")
+	of := addOutputFlags(fs)
+	var sel selection
+	fs.Var(&sel.exclude, "exclude", "skip entries matching this pattern, which can be repeated")
+	args = parseArgs(fs, args)
+	if len(args) < 1 {
+		usage()
+	}
+	sel.include = args[1:]
+	if err := sel.check(); err != nil {
+		return err
+	}
+
+	policy, err := ef.policy()
+	if err != nil {
+		return err
+	}
+	eo.existing = policy
+	if eo.jobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1, not %d", eo.jobs)
+	}
+	eo.rep, err = of.reporter()
+	if err != nil {
+		return err
+	}
+
+	var opts []gozip.Option
+	switch *symlinks {
+	case "on":
+	case "off":
+		opts = append(opts, gozip.WithoutSymlinks())
+	default:
+		return fmt.Errorf("--symlinks must be on or off, not %q", *symlinks)
+	}
+	if progress := eo.rep.progress(); progress != nil {
+		opts = append(opts, gozip.WithProgress(progress))
+	}
+
+	r, err := openArchive(args[0], af, opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Interrupting stops extraction cleanly rather than leaving a file
+	// half written.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	entries := sel.entries(r)
+	if *manifestPath != "" {
+		eo.manifest = newManifest()
+	}
+	err = extract(ctx, entries, eo)
+	if eo.manifest != nil {
+		if merr := eo.manifest.write(*manifestPath, entries); err == nil {
+			err = merr
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("interrupted")
+	}
+
+	return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eatonphil/gozip"
+)
+
+func TestExtractManifest(t *testing.T) {
+	contents := map[string]string{"a.txt": "first\n", "b.txt": "second\n", "../evil": "evil\n"}
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.zip")
+	if err := os.WriteFile(archive, testArchive(t, contents, "a.txt", "b.txt", "../evil"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, "b.txt"), []byte("already here\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifestPath := filepath.Join(dir, "manifest.json")
+	err := runExtract([]string{"-q", "--skip-existing", "--manifest", manifestPath, "-d", out, archive})
+	if err != errFailed {
+		t.Fatalf("got %v, want the blocked entry to fail the extraction", err)
+	}
+
+	bs, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []manifestEntry
+	if err := json.Unmarshal(bs, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name, path, skipped string
+		failed              bool
+	}{
+		{"a.txt", "a.txt", "", false},
+		{"b.txt", "", "already exists", false},
+		{"../evil", "", "", true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Name != w.name || g.Path != w.path || g.Skipped != w.skipped || (g.Error != "") != w.failed {
+			t.Errorf("got %+v, want %+v", g, w)
+		}
+		if g.Size != uint64(len(contents[w.name])) {
+			t.Errorf("%s: got size %d, want %d", w.name, g.Size, len(contents[w.name]))
+		}
+	}
+}
+
+func TestExtractKeepNewer(t *testing.T) {
+	contents := map[string]string{"newer.txt": "entry\n", "older.txt": "entry\n", "equal.txt": "entry\n", "missing.txt": "entry\n"}
+	names := []string{"newer.txt", "older.txt", "equal.txt", "missing.txt"}
+	bs := testArchive(t, contents, names...)
+	r, err := gozip.NewReader(bytes.NewReader(bs), int64(len(bs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := map[string]time.Time{}
+	for _, e := range r.Entries() {
+		modified[e.Name] = e.Modified
+	}
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.zip")
+	if err := os.WriteFile(archive, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// What's on disk, and when it was modified relative to the entry.
+	tests := []struct {
+		name     string
+		onDisk   time.Duration
+		replaced bool
+	}{
+		{"newer.txt", time.Hour, false},
+		{"older.txt", -time.Hour, true},
+		{"equal.txt", 0, false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(out, test.name)
+		if err := os.WriteFile(path, []byte("on disk\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := modified[test.name].Add(test.onDisk)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runExtract([]string{"-q", "--keep-newer", "-d", out, archive}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests = append(tests, struct {
+		name     string
+		onDisk   time.Duration
+		replaced bool
+	}{"missing.txt", 0, true})
+	for _, test := range tests {
+		got, err := os.ReadFile(filepath.Join(out, test.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "on disk\n"
+		if test.replaced {
+			want = contents[test.name]
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", test.name, got, want)
+		}
+	}
+
+	err = runExtract([]string{"-q", "--keep-newer", "--overwrite", "-d", out, archive})
+	if err == nil || !strings.Contains(err.Error(), "--keep-newer") {
+		t.Errorf("got %v for --keep-newer with --overwrite", err)
+	}
+}
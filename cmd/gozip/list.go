@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/eatonphil/gozip"
+)
+
+// method returns the name of the method the entry is compressed with,
+// looking past WinZip AES to the method under the encryption.
+func method(e *gozip.Entry) string {
+	if a, ok := e.AESExtraField(); ok {
+		return a.Method.String()
+	}
+
+	return e.Method.String()
+}
+
+// encryption names how the entry is encrypted, or is empty if it isn't.
+func encryption(e *gozip.Entry) string {
+	if !e.IsEncrypted() {
+		return ""
+	}
+	if a, ok := e.AESExtraField(); ok {
+		return fmt.Sprintf("AES-%d", a.KeyBits)
+	}
+
+	return "ZipCrypto"
+}
+
+// ratio is how much smaller compressing made size bytes, as unzip
+// reports it.
+func ratio(size, compressed uint64) string {
+	if size == 0 || compressed >= size {
+		return "0%"
+	}
+
+	return fmt.Sprintf("%d%%", (size-compressed)*100/size)
+}
+
+// list prints a line per entry with its sizes, method, CRC-32 and
+// modification time, like unzip -v.
+func list(entries []*gozip.Entry) {
+	fmt.Printf("%10s  %-9s  %10s  %4s  %-16s  %-8s  %s\n", "Length", "Method", "Size", "Cmpr", "Date", "CRC-32", "Name")
+	fmt.Printf("%10s  %-9s  %10s  %4s  %-16s  %-8s  %s\n", "----------", "---------", "----------", "----", "----------------", "--------", "----")
+
+	var size, compressed uint64
+	for _, e := range entries {
+		name := e.Name
+		if e.IsEncrypted() {
+			name += " *"
+		}
+
+		fmt.Printf("%10d  %-9s  %10d  %4s  %-16s  %08x  %s\n",
+			e.UncompressedSize, method(e), e.CompressedSize, ratio(e.UncompressedSize, e.CompressedSize),
+			e.Modified.Format("2006-01-02 15:04"), e.CRC32, name)
+		size += e.UncompressedSize
+		compressed += e.CompressedSize
+	}
+
+	fmt.Printf("%10s  %-9s  %10s  %4s  %-16s  %-8s  %s\n", "----------", "", "----------", "----", "", "", "----")
+	fmt.Printf("%10d  %-9s  %10d  %4s  %-16s  %-8s  %d entries\n", size, "", compressed, ratio(size, compressed), "", "", len(entries))
+}
+
+// hosts are the names of the systems in the upper byte of version made
+// by that archivers commonly record.
+var hosts = map[uint16]string{
+	0:  "MS-DOS",
+	3:  "Unix",
+	10: "Windows NTFS",
+	14: "VFAT",
+	19: "macOS",
+}
+
+func version(v uint16) string {
+	return fmt.Sprintf("%d.%d", v&0xFF/10, v&0xFF%10)
+}
+
+// listVerbose prints everything the central directory says about each
+// entry, like zipinfo -v, and about the archive: the methods its
+// entries use, the version a reader needs for all of them, and its
+// comment.
+func listVerbose(r *gozip.Reader, entries []*gozip.Entry) {
+	field := func(name string, format string, args ...interface{}) {
+		fmt.Printf("  %-18s %s\n", name+":", fmt.Sprintf(format, args...))
+	}
+
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		fmt.Println(e.Name)
+		field("Method", "%s", method(e))
+		if enc := encryption(e); enc != "" {
+			field("Encryption", "%s", enc)
+		}
+		field("Size", "%d", e.UncompressedSize)
+		field("Compressed", "%d (%s smaller)", e.CompressedSize, ratio(e.UncompressedSize, e.CompressedSize))
+		field("CRC-32", "%08x", e.CRC32)
+		field("Modified", "%s", e.Modified)
+		if !e.Accessed.IsZero() {
+			field("Accessed", "%s", e.Accessed)
+		}
+		if !e.Created.IsZero() {
+			field("Created", "%s", e.Created)
+		}
+		field("Mode", "%s", e.Mode())
+		if o, ok := e.UnixOwner(); ok {
+			field("Owner", "%d:%d", o.UID, o.GID)
+		}
+
+		host, ok := hosts[e.CreatorVersion>>8]
+		if !ok {
+			host = fmt.Sprintf("system %d", e.CreatorVersion>>8)
+		}
+		field("Made by", "%s, version %s", host, version(e.CreatorVersion))
+		field("Needs version", "%s", version(e.ReaderVersion))
+		field("Flags", "0x%04x", e.Flags)
+		field("Attributes", "0x%08x", e.ExternalAttrs)
+
+		if fields, err := e.ExtraFields(); err == nil && len(fields) > 0 {
+			var ids []string
+			for _, f := range fields {
+				ids = append(ids, fmt.Sprintf("0x%04x (%d bytes)", f.ID, len(f.Data)))
+			}
+			field("Extra fields", "%s", strings.Join(ids, ", "))
+		}
+		if e.Comment != "" {
+			field("Comment", "%s", e.Comment)
+		}
+	}
+
+	if counts := methodCounts(r); counts != "" {
+		fmt.Printf("\nMethods: %s\n", counts)
+	}
+	if v := r.MaxVersionNeeded(); v != 0 {
+		fmt.Printf("Needs version: %s\n", version(v))
+	}
+	if comment := r.Comment(); comment != "" {
+		fmt.Printf("\nArchive comment:\n%s\n", comment)
+	}
+}
+
+// methodCounts summarizes how many of r's entries use each method, such
+// as "store: 12, deflate: 340, zstd: 5", in order of method number.
+func methodCounts(r *gozip.Reader) string {
+	counts := r.MethodCounts()
+	methods := make([]gozip.Compression, 0, len(counts))
+	for m := range counts {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i] < methods[j] })
+
+	parts := make([]string, len(methods))
+	for i, m := range methods {
+		parts[i] = fmt.Sprintf("%s: %d", m, counts[m])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// jsonEntry is what list --json prints for each entry.
+type jsonEntry struct {
+	Name           string      `json:"name"`
+	Comment        string      `json:"comment,omitempty"`
+	Method         string      `json:"method"`
+	Encryption     string      `json:"encryption,omitempty"`
+	Size           uint64      `json:"size"`
+	CompressedSize uint64      `json:"compressed_size"`
+	CRC32          uint32      `json:"crc32"`
+	Modified       time.Time   `json:"modified"`
+	Accessed       *time.Time  `json:"accessed,omitempty"`
+	Created        *time.Time  `json:"created,omitempty"`
+	Mode           string      `json:"mode"`
+	Dir            bool        `json:"dir"`
+	Symlink        bool        `json:"symlink"`
+	UID            *uint32     `json:"uid,omitempty"`
+	GID            *uint32     `json:"gid,omitempty"`
+	CreatorVersion uint16      `json:"creator_version"`
+	ReaderVersion  uint16      `json:"reader_version"`
+	Flags          uint16      `json:"flags"`
+	ExternalAttrs  uint32      `json:"external_attrs"`
+	ExtraFields    []jsonExtra `json:"extra_fields,omitempty"`
+}
+
+type jsonExtra struct {
+	ID   uint16 `json:"id"`
+	Size int    `json:"size"`
+}
+
+func newJSONEntry(e *gozip.Entry) jsonEntry {
+	j := jsonEntry{
+		Name:           e.Name,
+		Comment:        e.Comment,
+		Method:         method(e),
+		Encryption:     encryption(e),
+		Size:           e.UncompressedSize,
+		CompressedSize: e.CompressedSize,
+		CRC32:          e.CRC32,
+		Modified:       e.Modified,
+		Mode:           e.Mode().String(),
+		Dir:            e.IsDir(),
+		Symlink:        e.IsSymlink(),
+		CreatorVersion: e.CreatorVersion,
+		ReaderVersion:  e.ReaderVersion,
+		Flags:          e.Flags,
+		ExternalAttrs:  e.ExternalAttrs,
+	}
+	if !e.Accessed.IsZero() {
+		j.Accessed = &e.Accessed
+	}
+	if !e.Created.IsZero() {
+		j.Created = &e.Created
+	}
+	if o, ok := e.UnixOwner(); ok {
+		j.UID, j.GID = &o.UID, &o.GID
+	}
+	if fields, err := e.ExtraFields(); err == nil {
+		for _, f := range fields {
+			j.ExtraFields = append(j.ExtraFields, jsonExtra{f.ID, len(f.Data)})
+		}
+	}
+
+	return j
+}
+
+// listJSON prints a JSON object per entry, one to a line, as NDJSON:
+// each is written as soon as it's encoded, so nothing is held for the
+// whole listing and readers can take it a line at a time.
+func listJSON(entries []*gozip.Entry) error {
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range entries {
+		if err := enc.Encode(newJSONEntry(e)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatEscapes turns the escapes people type in --format, which shells
+// pass through as is, into the characters they mean.
+var formatEscapes = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n")
+
+// listFormat prints each entry, a *gozip.Entry, through the template
+// format followed by a newline.
+func listFormat(entries []*gozip.Entry, format string) error {
+	tmpl, err := template.New("format").Parse(formatEscapes.Replace(format))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := tmpl.Execute(os.Stdout, e); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// sortEntries returns entries in the order --sort asks for: as the
+// central directory lists them, by name, or by where their local
+// headers are in the file, which is how their data is laid out.
+func sortEntries(entries []*gozip.Entry, by string) ([]*gozip.Entry, error) {
+	var less func(a, b *gozip.Entry) bool
+	switch by {
+	case "":
+		return entries, nil
+	case "name":
+		less = func(a, b *gozip.Entry) bool { return a.Name < b.Name }
+	case "offset":
+		less = func(a, b *gozip.Entry) bool { return a.HeaderOffset() < b.HeaderOffset() }
+	default:
+		return nil, fmt.Errorf("--sort must be name or offset, not %q", by)
+	}
+
+	sorted := append([]*gozip.Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted, nil
+}
+
+func runList(args []string) error {
+	fs := newFlagSet("list")
+	verbose := fs.Bool("v", false, "print everything about each entry")
+	asJSON := fs.Bool("json", false, "print a JSON object per entry, one to a line (NDJSON)")
+	format := fs.String("format", "", "print each entry through a Go template, such as '{{.Name}}\\t{{.CRC32}}'")
+	sortBy := fs.String("sort", "", "order entries by name or offset instead of as the central directory lists them")
+	af := addArchiveFlags(fs)
+	af.countOnly = true
+	var sel selection
+	fs.Var(&sel.exclude, "exclude", "skip entries matching this pattern, which can be repeated")
+	args = parseArgs(fs, args)
+	if len(args) < 1 {
+		usage()
+	}
+	sel.include = args[1:]
+	if err := sel.check(); err != nil {
+		return err
+	}
+
+	r, err := openArchive(args[0], af)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	entries, err := sortEntries(sel.entries(r), *sortBy)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *asJSON:
+		return listJSON(entries)
+	case *format != "":
+		return listFormat(entries, *format)
+	case *verbose:
+		listVerbose(r, entries)
+	default:
+		list(entries)
+	}
+
+	return nil
+}
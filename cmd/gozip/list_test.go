@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// reverseCentralDirectory returns the archive bs, which has no comment,
+// with its central directory records in reverse order, so they no
+// longer follow the entries' data.
+func reverseCentralDirectory(t *testing.T, bs []byte) []byte {
+	t.Helper()
+
+	start := bytes.Index(bs, []byte("PK\x01\x02"))
+	end := len(bs) - 22
+	var records [][]byte
+	for i := start; i < end; {
+		n := 46 + int(binary.LittleEndian.Uint16(bs[i+28:])) +
+			int(binary.LittleEndian.Uint16(bs[i+30:])) +
+			int(binary.LittleEndian.Uint16(bs[i+32:]))
+		records = append(records, bs[i:i+n])
+		i += n
+	}
+
+	out := append([]byte(nil), bs[:start]...)
+	for i := len(records) - 1; i >= 0; i-- {
+		out = append(out, records[i]...)
+	}
+	return append(out, bs[end:]...)
+}
+
+func TestListSort(t *testing.T) {
+	contents := map[string]string{"b.txt": "b\n", "c.txt": "c\n", "a.txt": "a\n"}
+	bs := reverseCentralDirectory(t, testArchive(t, contents, "b.txt", "c.txt", "a.txt"))
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(path, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		sort string
+		want string
+	}{
+		{"central directory", "", "a.txt c.txt b.txt"},
+		{"name", "name", "a.txt b.txt c.txt"},
+		{"offset", "offset", "b.txt c.txt a.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var err error
+			out := captureStdout(t, func() {
+				err = runList([]string{"--sort=" + test.sort, "--format={{.Name}}", path})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(strings.Fields(string(out)), " "); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	if err := runList([]string{"--sort=size", path}); err == nil {
+		t.Error("--sort=size didn't fail")
+	}
+}
+
+func TestListVerboseMethods(t *testing.T) {
+	contents := map[string]string{"a.txt": "a\n", "b.txt": "b\n"}
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(path, testArchive(t, contents, "a.txt", "b.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	out := captureStdout(t, func() { err = runList([]string{"-v", path}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Too small to gain from deflating, both are stored.
+	for _, want := range []string{"Methods: store: 2\n", "Needs version: 1.0\n"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("no %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	contents := map[string]string{"a.txt": "a\n", "b.go": "b\n", "vendor/c.txt": "c\n"}
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(path, testArchive(t, contents, "a.txt", "b.go", "vendor/c.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"all", nil, []string{"a.txt", "b.go", "vendor/c.txt"}},
+		{"pattern", []string{"**/*.txt"}, []string{"a.txt", "vendor/c.txt"}},
+		{"exclude", []string{"--exclude", "vendor/**"}, []string{"a.txt", "b.go"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var err error
+			out := captureStdout(t, func() {
+				err = runList(append([]string{"--json", path}, test.args...))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every line is an object of its own.
+			var got []string
+			scanner := bufio.NewScanner(bytes.NewReader(out))
+			for scanner.Scan() {
+				var e jsonEntry
+				if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+					t.Fatalf("line %q: %v", scanner.Text(), err)
+				}
+				if e.Size != uint64(len(contents[e.Name])) {
+					t.Errorf("%s: got size %d, want %d", e.Name, e.Size, len(contents[e.Name]))
+				}
+				got = append(got, e.Name)
+			}
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/eatonphil/gozip"
+)
+
+// errFailed is returned by commands that have already reported what
+// went wrong and only need to exit non-zero.
+var errFailed = fmt.Errorf("failed")
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands map[string]command
+
+// commandOrder is the order commands are listed in by usage.
+var commandOrder []string
+
+func init() {
+	// Commands are registered here rather than in commands'
+	// initializer since they refer back to it through usage.
+	commands = map[string]command{
+		"list":        {"list [-v | --json | --format=template] [--sort=name|offset] [--limits=off] [--salvage | --strict] [--duplicates=error|first|last|all] [--encoding=cp437|cp932|...] archive.zip [patterns...] [--exclude pattern]", runList},
+		"cat":         {"cat [--password pw] [--limits=off] [--salvage | --strict] [--duplicates=error|first|last|all] [--encoding=cp437|cp932|...] archive.zip names...", runCat},
+		"create":      {"create [--method store|deflate|zstd] [--level 0-9] [--store-suffixes .png,.jpg,...] [--split-size 100m | --profile epub|odf] [--reproducible] [--zipalign[=4]] [--jobs n] [-v | -q] [--password pw [--legacy-crypto]] archive.zip paths...", runCreate},
+		"add":         {"add [--method store|deflate|zstd] [--level 0-9] [--store-suffixes .png,.jpg,...] [--reproducible] [--zipalign[=4]] [--jobs n] [-v | -q] [--password pw [--legacy-crypto]] archive.zip paths...", runAdd},
+		"update":      {"update [--method store|deflate|zstd] [--level 0-9] [--store-suffixes .png,.jpg,...] [--reproducible] [--zipalign[=4]] [--jobs n] [-v | -q] [--password pw [--legacy-crypto]] archive.zip paths...", runUpdate},
+		"extract":     {"extract [--password pw] [--limits=off] [--salvage | --strict] [--symlinks=off] [--duplicates=error|first|last|all] [--encoding=cp437|cp932|...] archive.zip [patterns...] [--exclude pattern] [-d dir] [-j] [--jobs n] [--manifest out.json] [-v | -q] [--overwrite|--skip-existing|--freshen|--keep-newer|--rename]", runExtract},
+		"test":        {"test [--password pw] [--limits=off] [--salvage | --strict] [--duplicates=error|first|last|all] [--encoding=cp437|cp932|...] archive.zip", runTest},
+		"check-names": {"check-names archive.zip", runCheckNames},
+		"audit":       {"audit archive.zip", runAudit},
+		"delete":      {"delete archive.zip names...", runDelete},
+		"rename":      {"rename archive.zip old/path new/path", runRename},
+		"merge":       {"merge [--conflict first-wins|last-wins|error] out.zip archives...", runMerge},
+		"diff":        {"diff [--content] [--password pw] [--limits=off] [--salvage | --strict] [--duplicates=error|first|last|all] [--encoding=cp437|cp932|...] a.zip b.zip", runDiff},
+		"repair":      {"repair damaged.zip out.zip", runRepair},
+		"repair-crc":  {"repair-crc [--password pw] [--limits=off] in.zip out.zip", runRepairCRC},
+		"align":       {"align [--alignment 4] archive.apk out.apk | align --check [--alignment 4] archive.apk", runAlign},
+		"convert":     {"convert [--method store|deflate|zstd] [--level 0-9] [--password pw] archive.zip archive.tar.gz | archive.tar.gz archive.zip", runConvert},
+		"sfx":         {"sfx [--stub gozip-sfx] out archive.zip", runSFX},
+		"comment":     {"comment archive.zip [text]", runComment},
+	}
+	commandOrder = []string{"list", "cat", "create", "add", "update", "delete", "rename", "merge", "diff", "convert", "extract", "test", "check-names", "audit", "repair", "repair-crc", "align", "sfx", "comment"}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  gozip [--binary-safe] [--password pw] [--limits=off] [--salvage | --strict] [--duplicates=error|first|last|all] [--encoding=cp437|cp932|...] archive.zip")
+	for _, name := range commandOrder {
+		fmt.Fprintln(os.Stderr, "  gozip "+commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Commands that take --limits also take --max-entries n, default %d, and\n", defaultLimits.MaxEntries)
+	fmt.Fprintf(os.Stderr, "--max-total-size, default %dg, to change those limits.\n", defaultLimits.MaxTotalSize>>30)
+	fmt.Fprintln(os.Stderr, "Commands that take --strict also take --follow-central-directory=false to find")
+	fmt.Fprintln(os.Stderr, "entries by walking local headers, reporting where the central directory disagrees.")
+	os.Exit(2)
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = usage
+	return fs
+}
+
+// defaultLimits keep a zip bomb from filling the disk or memory unless
+// --limits=off is passed.
+var defaultLimits = gozip.Limits{
+	MaxEntries:   1000000,
+	MaxEntrySize: 8 << 30,
+	MaxTotalSize: 32 << 30,
+	// Well above what zstd gets on runs of zeros, so only what no
+	// archiver writes is refused.
+	MaxRatio: 100000,
+}
+
+// archiveFlags are the flags shared by commands that read entries'
+// contents.
+type archiveFlags struct {
+	password   string
+	limits     string
+	duplicates string
+	encoding   string
+	salvage    bool
+	strict     bool
+	// followCD is false to find entries by walking the local headers.
+	followCD bool
+	// maxEntries and maxTotalSize replace the default limits when set.
+	maxEntries   string
+	maxTotalSize string
+	// countOnly limits only how many entries there are, for commands
+	// that decompress nothing and so needn't mind the sizes entries
+	// claim.
+	countOnly bool
+}
+
+// duplicatePolicies are the values --duplicates takes.
+var duplicatePolicies = map[string]gozip.DuplicatePolicy{
+	"error": gozip.RejectDuplicates,
+	"first": gozip.KeepFirstDuplicate,
+	"last":  gozip.KeepLastDuplicate,
+	"all":   gozip.KeepDuplicates,
+}
+
+func addArchiveFlags(fs *flag.FlagSet) *archiveFlags {
+	var af archiveFlags
+	fs.StringVar(&af.password, "password", "", "password to decrypt entries with")
+	fs.StringVar(&af.limits, "limits", "on", "size limits against zip bombs: on or off")
+	fs.StringVar(&af.maxEntries, "max-entries", "", "most entries an archive may have")
+	fs.StringVar(&af.maxTotalSize, "max-total-size", "", "most the entries may decompress to in all, such as 32g")
+	fs.StringVar(&af.duplicates, "duplicates", "error", "entries with the same name: error, first, last or all")
+	fs.StringVar(&af.encoding, "encoding", "", "codepage of names not flagged as UTF-8, such as cp437 or cp932")
+	fs.BoolVar(&af.salvage, "salvage", false, "scan for entries if the central directory is missing or damaged")
+	fs.BoolVar(&af.strict, "strict", false, "reject archives that break the zip specification in any way")
+	fs.BoolVar(&af.followCD, "follow-central-directory", true, "find entries through the central directory rather than by walking local headers")
+	return &af
+}
+
+// archiveLimits returns the limits af sets: the defaults unless
+// --limits=off, with --max-entries and --max-total-size in place of
+// theirs.
+func (af *archiveFlags) archiveLimits() (gozip.Limits, error) {
+	var limits gozip.Limits
+	switch af.limits {
+	case "on":
+		limits = defaultLimits
+	case "off":
+	default:
+		return limits, fmt.Errorf("--limits must be on or off, not %q", af.limits)
+	}
+
+	if af.maxEntries != "" {
+		n, err := strconv.Atoi(af.maxEntries)
+		if err != nil || n <= 0 {
+			return limits, fmt.Errorf("--max-entries: invalid count %q", af.maxEntries)
+		}
+		limits.MaxEntries = n
+	}
+	if af.maxTotalSize != "" {
+		n, err := parseSize(af.maxTotalSize)
+		if err != nil {
+			return limits, fmt.Errorf("--max-total-size: %v", err)
+		}
+		limits.MaxTotalSize = uint64(n)
+	}
+
+	if af.countOnly {
+		limits = gozip.Limits{MaxEntries: limits.MaxEntries}
+	}
+	return limits, nil
+}
+
+// limitFlags are the flags that raise each of gozip.Limits.
+var limitFlags = map[string]string{
+	"MaxEntries":   "--max-entries",
+	"MaxTotalSize": "--max-total-size",
+}
+
+// openArchive opens the archive at path, or on stdin if path is -, with
+// the options af and the command's own opts make.
+func openArchive(path string, af *archiveFlags, opts ...gozip.Option) (*gozip.Reader, error) {
+	if af.password != "" {
+		opts = append(opts, gozip.WithPassword(af.password))
+	}
+
+	limits, err := af.archiveLimits()
+	if err != nil {
+		return nil, err
+	}
+	if limits != (gozip.Limits{}) {
+		opts = append(opts, gozip.WithLimits(limits))
+	}
+
+	policy, ok := duplicatePolicies[af.duplicates]
+	if !ok {
+		return nil, fmt.Errorf("--duplicates must be error, first, last or all, not %q", af.duplicates)
+	}
+	opts = append(opts, gozip.WithDuplicates(policy))
+
+	if af.salvage && af.strict {
+		return nil, fmt.Errorf("only one of --salvage and --strict can be given")
+	}
+	if !af.followCD && (af.salvage || af.strict) {
+		return nil, fmt.Errorf("--follow-central-directory=false can't be given with --salvage or --strict")
+	}
+	if af.salvage {
+		opts = append(opts, gozip.WithSalvage())
+	}
+	if af.strict || !af.followCD {
+		opts = append(opts, gozip.WithParseOptions(gozip.ParseOptions{Strict: af.strict, LocalHeaders: !af.followCD}))
+	}
+
+	if af.encoding != "" {
+		enc, ok := gozip.LookupEncoding(af.encoding)
+		if !ok {
+			return nil, fmt.Errorf("unknown encoding %q", af.encoding)
+		}
+		opts = append(opts, gozip.WithEncoding(enc))
+	}
+
+	r, err := openSource(path, opts...)
+	if err != nil || af.followCD {
+		return r, err
+	}
+
+	// Say where the central directory disagrees with the local headers
+	// the entries were found by.
+	mismatches, err := r.CompareParses()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gozip: can't compare with the central directory: %s\n", err)
+	}
+	for _, m := range mismatches {
+		fmt.Fprintf(os.Stderr, "gozip: %q at %d: %s\n", m.Entry, m.Offset, m)
+	}
+	return r, nil
+}
+
+// openSource opens the archive at path: a file, stdin if path is -, a
+// URL or an object in S3 or Google Cloud Storage.
+func openSource(path string, opts ...gozip.Option) (*gozip.Reader, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return gozip.OpenURL(path, opts...)
+	}
+
+	if path == "-" {
+		r, err := openStdin()
+		if err != nil {
+			return nil, err
+		}
+		return gozip.NewReaderAt(r, opts...)
+	}
+
+	if r, ok, err := openObject(path); ok {
+		if err != nil {
+			return nil, err
+		}
+		return gozip.NewReaderAt(r, opts...)
+	}
+
+	return gozip.Open(path, opts...)
+}
+
+// openObject opens an s3://bucket/key or gs://bucket/object path, with
+// the credentials the AWS and Google tools read from the environment.
+// It reports whether path was one.
+func openObject(path string) (gozip.SizedReaderAt, bool, error) {
+	scheme := strings.SplitN(path, "://", 2)
+	if len(scheme) != 2 || (scheme[0] != "s3" && scheme[0] != "gs") {
+		return nil, false, nil
+	}
+	parts := strings.SplitN(scheme[1], "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, true, fmt.Errorf("%s is not %s://bucket/name", path, scheme[0])
+	}
+	bucket, key := parts[0], parts[1]
+
+	if scheme[0] == "gs" {
+		var token func() (string, error)
+		if t := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); t != "" {
+			token = func() (string, error) { return t, nil }
+		}
+		r, err := gozip.NewGCSReaderAt(http.DefaultClient, bucket, key, token)
+		return r, true, err
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	r, err := gozip.NewS3ReaderAt(http.DefaultClient, gozip.S3Object{
+		Bucket:          bucket,
+		Key:             key,
+		Region:          region,
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	})
+	return r, true, err
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+	}
+
+	run := runDump
+	if c, ok := commands[args[0]]; ok {
+		run = c.run
+		args = args[1:]
+	}
+
+	err := run(args)
+	removeSpooled()
+	if err == errFailed {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gozip:", err)
+		var le *gozip.LimitError
+		if errors.As(err, &le) {
+			if flag, ok := limitFlags[le.Limit]; ok {
+				fmt.Fprintf(os.Stderr, "gozip: raise the limit with %s, or lift them all with --limits=off\n", flag)
+			} else {
+				fmt.Fprintln(os.Stderr, "gozip: lift the limits with --limits=off")
+			}
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eatonphil/gozip"
+)
+
+func TestArchiveLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		af   archiveFlags
+		want gozip.Limits
+		err  bool
+	}{
+		{"defaults", archiveFlags{limits: "on"}, defaultLimits, false},
+		{"off", archiveFlags{limits: "off"}, gozip.Limits{}, false},
+		{"max entries", archiveFlags{limits: "on", maxEntries: "10"}, gozip.Limits{MaxEntries: 10, MaxEntrySize: defaultLimits.MaxEntrySize, MaxTotalSize: defaultLimits.MaxTotalSize, MaxRatio: defaultLimits.MaxRatio}, false},
+		{"max total size", archiveFlags{limits: "on", maxTotalSize: "2g"}, gozip.Limits{MaxEntries: defaultLimits.MaxEntries, MaxEntrySize: defaultLimits.MaxEntrySize, MaxTotalSize: 2 << 30, MaxRatio: defaultLimits.MaxRatio}, false},
+		{"only max entries", archiveFlags{limits: "off", maxEntries: "10"}, gozip.Limits{MaxEntries: 10}, false},
+		{"count only", archiveFlags{limits: "on", maxTotalSize: "2g", countOnly: true}, gozip.Limits{MaxEntries: defaultLimits.MaxEntries}, false},
+		{"bad count", archiveFlags{limits: "on", maxEntries: "many"}, gozip.Limits{}, true},
+		{"bad size", archiveFlags{limits: "on", maxTotalSize: "-1"}, gozip.Limits{}, true},
+		{"bad limits", archiveFlags{limits: "maybe"}, gozip.Limits{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.af.archiveLimits()
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v, want one: %t", err, test.err)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eatonphil/gozip"
+)
+
+// manifestEntry is what --manifest records for each entry: where it was
+// written under the directory, or why it wasn't.
+type manifestEntry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path,omitempty"`
+	Size     uint64    `json:"size"`
+	Mode     string    `json:"mode"`
+	Modified time.Time `json:"modified"`
+	CRC32    uint32    `json:"crc32"`
+	Skipped  string    `json:"skipped,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func newManifestEntry(res gozip.ExtractResult, skipped string) manifestEntry {
+	m := manifestEntry{
+		Name:     res.Name,
+		Path:     res.Path,
+		Size:     res.Size,
+		Mode:     res.Mode.String(),
+		Modified: res.Modified,
+		CRC32:    res.CRC32,
+		Skipped:  skipped,
+	}
+	if res.Err != nil {
+		m.Error = res.Err.Error()
+	}
+
+	return m
+}
+
+// manifest collects what became of the entries extract is given, from
+// the workers writing them too. A nil manifest collects nothing.
+type manifest struct {
+	mu      sync.Mutex
+	entries map[*gozip.Entry]manifestEntry
+}
+
+func newManifest() *manifest {
+	return &manifest{entries: map[*gozip.Entry]manifestEntry{}}
+}
+
+// add records extracting e as name, or failing to with err.
+func (m *manifest) add(e *gozip.Entry, name string, err error) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e] = newManifestEntry(e.Result(name, err), "")
+}
+
+// skip records leaving e out for reason.
+func (m *manifest) skip(e *gozip.Entry, reason string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e] = newManifestEntry(e.Result("", nil), reason)
+}
+
+// write writes the manifest of entries to path as a JSON array, in
+// their order. Entries extraction stopped before are recorded as
+// skipped.
+func (m *manifest) write(path string, entries []*gozip.Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]manifestEntry, len(entries))
+	for i, e := range entries {
+		me, ok := m.entries[e]
+		if !ok {
+			me = newManifestEntry(e.Result("", nil), "extraction stopped")
+		}
+		list[i] = me
+	}
+
+	bs, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(bs, '\n'), 0644)
+}
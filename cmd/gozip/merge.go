@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eatonphil/gozip"
+)
+
+// conflictPolicy is what merge does when archives have entries of the
+// same name.
+type conflictPolicy int
+
+const (
+	firstWins conflictPolicy = iota
+	lastWins
+	conflictError
+)
+
+// conflictPolicies are the values --conflict takes.
+var conflictPolicies = map[string]conflictPolicy{
+	"first-wins": firstWins,
+	"last-wins":  lastWins,
+	"error":      conflictError,
+}
+
+// merge writes the entries of the archives at paths to out, copied
+// without being decompressed, in the order their names first appear.
+// Directory entries are kept from the first archive that has them
+// whatever the policy, since there is nothing in them to conflict.
+func merge(out string, paths []string, policy conflictPolicy) error {
+	var names []string
+	winners := map[string]*gozip.Entry{}
+	for _, path := range paths {
+		r, err := gozip.Open(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		defer r.Close()
+
+		for _, e := range r.Entries() {
+			if _, ok := winners[e.Name]; !ok {
+				names = append(names, e.Name)
+				winners[e.Name] = e
+				continue
+			}
+
+			switch {
+			case e.IsDir():
+			case policy == lastWins:
+				winners[e.Name] = e
+			case policy == conflictError:
+				return fmt.Errorf("%s: %s is in an earlier archive too", path, e.Name)
+			}
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := gozip.NewWriter(f)
+	for _, name := range names {
+		if err := w.CopyRaw(winners[name]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func runMerge(args []string) error {
+	fs := newFlagSet("merge")
+	conflict := fs.String("conflict", "first-wins", "entries in more than one archive: first-wins, last-wins or error")
+	args = parseArgs(fs, args)
+	if len(args) < 2 {
+		usage()
+	}
+
+	policy, ok := conflictPolicies[*conflict]
+	if !ok {
+		return fmt.Errorf("--conflict must be first-wins, last-wins or error, not %q", *conflict)
+	}
+
+	return merge(args[0], args[1:], policy)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/eatonphil/gozip"
+)
+
+func checkNames(r *gozip.Reader) bool {
+	ok := true
+	for _, e := range r.Entries() {
+		for _, issue := range gozip.CheckName(e.Name) {
+			fmt.Printf("%q: %s\n", e.Name, issue)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func runCheckNames(args []string) error {
+	fs := newFlagSet("check-names")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	r, err := gozip.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if !checkNames(r) {
+		return errFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package main
+
+import (
+	"os"
+
+	"github.com/eatonphil/gozip"
+)
+
+// owner reports that files have no Unix owner on this system.
+func owner(info os.FileInfo) (*gozip.UnixOwner, bool) {
+	return nil, false
+}
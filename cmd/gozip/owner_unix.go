@@ -0,0 +1,21 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/eatonphil/gozip"
+)
+
+// owner returns the user and group that own the file info describes.
+func owner(info os.FileInfo) (*gozip.UnixOwner, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, false
+	}
+
+	return &gozip.UnixOwner{UID: uint32(st.Uid), GID: uint32(st.Gid)}, true
+}
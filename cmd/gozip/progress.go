@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eatonphil/gozip"
+)
+
+// outputFlags are the flags of commands that can take a while, saying
+// how much to tell about how they are going.
+type outputFlags struct {
+	verbose, quiet bool
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	var of outputFlags
+	fs.BoolVar(&of.verbose, "v", false, "print each file as it is done")
+	fs.BoolVar(&of.verbose, "verbose", false, "same as -v")
+	fs.BoolVar(&of.quiet, "q", false, "print nothing but errors")
+	fs.BoolVar(&of.quiet, "quiet", false, "same as -q")
+	return &of
+}
+
+func (of *outputFlags) reporter() (*reporter, error) {
+	if of.verbose && of.quiet {
+		return nil, fmt.Errorf("only one of -v and -q can be given")
+	}
+
+	rep := &reporter{verbose: of.verbose, out: os.Stdout}
+	if !of.verbose && !of.quiet && isTerminal(os.Stderr) {
+		rep.bar = &progressBar{}
+	}
+
+	return rep, nil
+}
+
+// reporter tells how an extraction or creation is going: a line per
+// file with -v, a progress bar when stderr is a terminal otherwise, and
+// nothing with -q.
+type reporter struct {
+	verbose bool
+	// out is where -v prints, which is stderr when the archive itself
+	// is going to stdout.
+	out io.Writer
+	bar *progressBar
+}
+
+// file reports that the file name is being done, as unzip and zip do.
+func (r *reporter) file(verb, name string) {
+	if r.verbose {
+		fmt.Fprintf(r.out, "%10s: %s\n", verb, name)
+	}
+}
+
+// warn prints a message to stderr, on a line of its own rather than
+// over the progress bar.
+func (r *reporter) warn(format string, args ...interface{}) {
+	r.bar.clear()
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// progress returns the function to give gozip progress to, or nil
+// without a bar.
+func (r *reporter) progress() func(gozip.Progress) {
+	if r.bar == nil {
+		return nil
+	}
+
+	return r.bar.update
+}
+
+// done clears the progress bar away.
+func (r *reporter) done() {
+	r.bar.clear()
+}
+
+// progressBar draws progress on one line of stderr it keeps redrawing,
+// at most ten times a second. A nil progressBar draws nothing.
+type progressBar struct {
+	// total, if set, is what to measure progress against instead of
+	// the size of the whole archive, when only some of it is read.
+	total uint64
+
+	mu    sync.Mutex
+	last  time.Time
+	drawn bool
+}
+
+const (
+	progressBarWidth = 30
+	progressNameMax  = 40
+)
+
+func (b *progressBar) update(p gozip.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.last) < 100*time.Millisecond {
+		return
+	}
+	b.last = now
+
+	if b.total != 0 {
+		p.Total = b.total
+	}
+
+	line := formatSize(p.Bytes)
+	if p.Total > 0 {
+		percent := uint64(100)
+		if p.Bytes < p.Total {
+			percent = p.Bytes * 100 / p.Total
+		}
+		filled := int(percent) * progressBarWidth / 100
+		line = fmt.Sprintf("[%s%s] %3d%% %s / %s", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+			percent, line, formatSize(p.Total))
+	}
+
+	// Long names would wrap and leave the line behind when redrawn, so
+	// keep their ends, which say the most.
+	name := []rune(p.Entry)
+	if len(name) > progressNameMax {
+		name = append([]rune("..."), name[len(name)-progressNameMax+3:]...)
+	}
+
+	fmt.Fprintf(os.Stderr, "\r\x1b[K%s  %s", line, string(name))
+	b.drawn = true
+}
+
+func (b *progressBar) clear() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.drawn {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		b.drawn = false
+	}
+}
+
+// formatSize formats n bytes for people, as 1.5 MB and the like.
+func formatSize(n uint64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
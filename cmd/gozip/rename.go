@@ -0,0 +1,13 @@
+package main
+
+import "github.com/eatonphil/gozip"
+
+func runRename(args []string) error {
+	fs := newFlagSet("rename")
+	args = parseArgs(fs, args)
+	if len(args) != 3 {
+		usage()
+	}
+
+	return gozip.RenameEntry(args[0], args[1], args[2])
+}
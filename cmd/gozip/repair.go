@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eatonphil/gozip"
+)
+
+// repair writes what can be salvaged from the damaged archive at path
+// to out, reporting each entry left out on stderr.
+func repair(out, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	found, err := gozip.Repair(f, in, info.Size())
+	if err != nil {
+		os.Remove(out)
+		return err
+	}
+
+	kept := 0
+	for _, s := range found {
+		if s.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s at %d: %v\n", s.Name, s.Offset, s.Err)
+			continue
+		}
+		kept++
+	}
+	fmt.Printf("salvaged %d of %d entries\n", kept, len(found))
+
+	return f.Close()
+}
+
+func runRepair(args []string) error {
+	fs := newFlagSet("repair")
+	args = parseArgs(fs, args)
+	if len(args) != 2 {
+		usage()
+	}
+
+	return repair(args[1], args[0])
+}
+
+// repairCRC writes the archive at path to out with every entry's CRC-32
+// replaced by that of its contents, reporting each it changes, and each
+// it had to copy as it was on stderr.
+func repairCRC(out, path string, af *archiveFlags) error {
+	r, err := openArchive(path, af)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fixes, err := gozip.RepairCRCs(f, r)
+	if err != nil {
+		os.Remove(out)
+		return err
+	}
+
+	fixed := 0
+	for _, fix := range fixes {
+		switch {
+		case fix.Err != nil:
+			fmt.Fprintf(os.Stderr, "%s: copied as it was: %v\n", fix.Name, fix.Err)
+		case fix.New != fix.Old:
+			fmt.Printf("%s: CRC-32 %08x, not %08x\n", fix.Name, fix.New, fix.Old)
+			fixed++
+		}
+	}
+	fmt.Printf("fixed %d of %d entries\n", fixed, len(fixes))
+
+	return f.Close()
+}
+
+func runRepairCRC(args []string) error {
+	fs := newFlagSet("repair-crc")
+	af := addArchiveFlags(fs)
+	args = parseArgs(fs, args)
+	if len(args) != 2 {
+		usage()
+	}
+
+	return repairCRC(args[1], args[0], af)
+}
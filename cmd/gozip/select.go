@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/eatonphil/gozip"
+)
+
+// parseArgs parses flags wherever they appear among args, as unzip
+// allows, and returns the other arguments. Everything after -- is an
+// argument.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		remaining := fs.Args()
+		if len(remaining) < len(args) && args[len(args)-len(remaining)-1] == "--" {
+			return append(rest, remaining...)
+		}
+		if len(remaining) == 0 {
+			return rest
+		}
+
+		rest = append(rest, remaining[0])
+		args = remaining[1:]
+	}
+}
+
+// patternsFlag collects the patterns a repeated flag is given.
+type patternsFlag []string
+
+func (p *patternsFlag) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternsFlag) Set(pattern string) error {
+	*p = append(*p, pattern)
+	return nil
+}
+
+// selection picks entries by name: those matching any include pattern,
+// or all if there are none, except those matching an exclude pattern.
+// Patterns are gozip.Match globs, so ** spans directories.
+type selection struct {
+	include []string
+	exclude patternsFlag
+}
+
+func (s *selection) check() error {
+	for _, pattern := range append(s.include, s.exclude...) {
+		if _, err := gozip.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q", pattern)
+		}
+	}
+
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := gozip.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *selection) matches(name string) bool {
+	if len(s.include) > 0 && !matchAny(s.include, name) {
+		return false
+	}
+
+	return !matchAny(s.exclude, name)
+}
+
+// entries returns r's entries that s selects.
+func (s *selection) entries(r *gozip.Reader) []*gozip.Entry {
+	var selected []*gozip.Entry
+	for _, e := range r.Entries() {
+		if s.matches(e.Name) {
+			selected = append(selected, e)
+		}
+	}
+
+	return selected
+}
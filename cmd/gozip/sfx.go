@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/eatonphil/gozip"
+)
+
+// defaultStub is the gozip-sfx installed next to gozip, as go install
+// ./cmd/... leaves it.
+func defaultStub() string {
+	self, err := os.Executable()
+	if err != nil {
+		return "gozip-sfx"
+	}
+
+	return filepath.Join(filepath.Dir(self), "gozip-sfx")
+}
+
+// sfx writes to out the stub followed by the entries of the archive at
+// path, copied without being decompressed, with offsets from the start
+// of out so both unzip and the stub can read it.
+func sfx(out, stub, path string) error {
+	r, err := gozip.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	s, err := os.Open(stub)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, s)
+	if err != nil {
+		return err
+	}
+
+	w := gozip.NewWriter(f)
+	w.SetOffset(n)
+	for _, e := range r.Entries() {
+		if err := w.CopyRaw(e); err != nil {
+			return fmt.Errorf("%s: %v", e.Name, err)
+		}
+	}
+	if err := w.SetComment(r.Comment()); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func runSFX(args []string) error {
+	fs := newFlagSet("sfx")
+	stub := fs.String("stub", defaultStub(), "executable to put in front of the archive")
+	args = parseArgs(fs, args)
+	if len(args) != 2 {
+		usage()
+	}
+
+	return sfx(args[0], *stub, args[1])
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/eatonphil/gozip"
+)
+
+// stdinMemoryLimit is how much of an archive piped to stdin is kept in
+// memory. Bigger ones are spooled to a temporary file.
+const stdinMemoryLimit = 64 << 20
+
+// spooled is the temporary file openStdin spooled stdin to, if it did.
+var spooled *os.File
+
+// openStdin returns the archive on stdin. Archives are read from their
+// end, so one redirected from a file is read in place, but one from a
+// pipe has to be read in whole first.
+func openStdin() (gozip.SizedReaderAt, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode().IsRegular() {
+		return io.NewSectionReader(os.Stdin, 0, info.Size()), nil
+	}
+
+	head, err := io.ReadAll(io.LimitReader(os.Stdin, stdinMemoryLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(head) <= stdinMemoryLimit {
+		return bytes.NewReader(head), nil
+	}
+
+	f, err := os.CreateTemp("", "gozip-stdin-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	spooled = f
+
+	if _, err := f.Write(head); err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(f, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NewSectionReader(f, 0, int64(len(head))+size), nil
+}
+
+// removeSpooled removes the file openStdin spooled stdin to.
+func removeSpooled() {
+	if spooled != nil {
+		spooled.Close()
+		os.Remove(spooled.Name())
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/eatonphil/gozip"
+)
+
+func test(r *gozip.Reader) bool {
+	ok := true
+	for _, e := range r.Entries() {
+		if e.IsDir() {
+			continue
+		}
+
+		if err := e.Verify(); err != nil {
+			fmt.Printf("%s: %s\n", e.Name, err)
+			ok = false
+			continue
+		}
+
+		fmt.Printf("%s: OK\n", e.Name)
+	}
+
+	return ok
+}
+
+func runTest(args []string) error {
+	fs := newFlagSet("test")
+	af := addArchiveFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	r, err := openArchive(fs.Arg(0), af)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if !test(r) {
+		return errFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package gozip
+
+import (
+	"os"
+	"unicode/utf8"
+)
+
+// Comment returns the archive's comment from its end of central
+// directory record. The record has no UTF-8 flag, so the comment is
+// decoded with the Reader's encoding if it has one, taken as UTF-8 if it
+// is valid UTF-8 and as CP437 otherwise.
+func (r *Reader) Comment() string {
+	raw := []byte(r.comment)
+	if r.encoding != nil {
+		return r.encoding(raw)
+	}
+	if utf8.Valid(raw) {
+		return r.comment
+	}
+
+	return encodings["cp437"](raw)
+}
+
+// SetComment sets the archive comment Close writes.
+func (w *Writer) SetComment(comment string) error {
+	if len(comment) > maxCommentLength {
+		return ErrTooLarge
+	}
+
+	w.comment = comment
+	return nil
+}
+
+// SetArchiveComment replaces the comment of the archive in the named
+// file, which only rewrites its end of central directory record.
+func SetArchiveComment(name, comment string) error {
+	if len(comment) > maxCommentLength {
+		return ErrTooLarge
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if size == 0 {
+		return ErrEmptyFile
+	}
+
+	tailStart, i, _, err := findEndOfCentralDirectoryIn(f, size)
+	if err != nil {
+		return err
+	}
+
+	// The comment length is the record's last field, right before the
+	// comment itself.
+	var b byteWriter
+	b.uint16(uint16(len(comment)))
+	b.WriteString(comment)
+	offset := tailStart + int64(i) + endOfCentralDirectoryLength - 2
+	if _, err := f.WriteAt(b.Bytes(), offset); err != nil {
+		return err
+	}
+
+	if err := f.Truncate(offset + int64(b.Len())); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,88 @@
+package gozip
+
+import (
+	"context"
+	"io"
+)
+
+// OpenContext is like Open, but reading fails with ctx's error once ctx
+// is done.
+func (e *Entry) OpenContext(ctx context.Context) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rc, err := e.Open()
+	if err != nil || ctx.Done() == nil {
+		return rc, err
+	}
+
+	return &contextReader{ReadCloser: rc, ctx: ctx}, nil
+}
+
+type contextReader struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cr.ReadCloser.Read(p)
+}
+
+// ExtractContext is like Extract, but stops with ctx's error once ctx
+// is done, removing the partly written file.
+func (e *Entry) ExtractContext(ctx context.Context, dir string) error {
+	return e.ExtractAsContext(ctx, dir, e.Name)
+}
+
+// WriteEntryContext is like WriteEntry, but fails with ctx's error if
+// ctx is done before the entry is written.
+func (w *Writer) WriteEntryContext(ctx context.Context, e *Entry, contents []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c, err := w.Compress(e, contents)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return w.WriteCompressed(c)
+}
+
+// CreateEntryContext is like CreateEntry, but writes fail with ctx's
+// error once ctx is done. The entry is left unfinished, so the archive
+// should be abandoned then.
+func (w *Writer) CreateEntryContext(ctx context.Context, e *Entry) (io.Writer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ew, err := w.CreateEntry(e)
+	if err != nil || ctx.Done() == nil {
+		return ew, err
+	}
+
+	return &contextWriter{w: ew, ctx: ctx}, nil
+}
+
+type contextWriter struct {
+	w   io.Writer
+	ctx context.Context
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cw.w.Write(p)
+}
@@ -0,0 +1,386 @@
+package gozip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Deflate64 is PKWARE's deflate with a 64KiB window, lengths up to
+// 65538 and two more distance codes. Go's compress/flate can't read it,
+// so it has its own inflater here.
+
+const deflate64WindowSize = 1 << 16
+
+var ErrInvalidDeflate64 = fmt.Errorf("Invalid Deflate64 data")
+
+var (
+	deflate64LengthBase = [...]uint16{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31,
+		35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 3,
+	}
+	deflate64LengthBits = [...]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2,
+		3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 16,
+	}
+	deflate64DistanceBase = [...]uint32{
+		1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193,
+		257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145,
+		8193, 12289, 16385, 24577, 32769, 49153,
+	}
+	deflate64DistanceBits = [...]uint8{
+		0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6,
+		7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13, 14, 14,
+	}
+
+	// The order code length code lengths are sent in.
+	deflate64CodeLengthOrder = [...]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+)
+
+// canonicalHuffman decodes a canonical Huffman code a bit at a time:
+// count is how many codes there are of each length and symbols lists
+// the symbols in code order. Deflate64's codes are up to 15 bits long,
+// implode's up to 16.
+type canonicalHuffman struct {
+	count   [17]int
+	symbols []uint16
+	// inverted codes are the complement of the canonical ones, which
+	// is how implode's Shannon-Fano codes are assigned.
+	inverted bool
+}
+
+func newCanonicalHuffman(lengths []uint8) (*canonicalHuffman, error) {
+	h := &canonicalHuffman{}
+	for _, l := range lengths {
+		h.count[l]++
+	}
+	h.count[0] = 0
+
+	// An over-subscribed code can't be decoded. Incomplete ones are
+	// allowed, a single distance code is.
+	left := 1
+	for l := 1; l < len(h.count); l++ {
+		left = left<<1 - h.count[l]
+		if left < 0 {
+			return nil, ErrInvalidDeflate64
+		}
+	}
+
+	var offsets [len(h.count)]int
+	for l := 1; l < len(h.count)-1; l++ {
+		offsets[l+1] = offsets[l] + h.count[l]
+	}
+
+	last := len(h.count) - 1
+	h.symbols = make([]uint16, offsets[last]+h.count[last])
+	for s, l := range lengths {
+		if l != 0 {
+			h.symbols[offsets[l]] = uint16(s)
+			offsets[l]++
+		}
+	}
+
+	return h, nil
+}
+
+// bitReader reads bits least significant first from a byte stream.
+// invalid is the error for bits that don't decode to a symbol.
+type bitReader struct {
+	r       *bufio.Reader
+	bits    uint32
+	nbits   uint
+	invalid error
+}
+
+func (br *bitReader) bit(n uint) (uint32, error) {
+	for br.nbits < n {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		br.bits |= uint32(b) << br.nbits
+		br.nbits += 8
+	}
+
+	v := br.bits & (1<<n - 1)
+	br.bits >>= n
+	br.nbits -= n
+	return v, nil
+}
+
+func (br *bitReader) decode(h *canonicalHuffman) (int, error) {
+	code, first, index := 0, 0, 0
+	for l := 1; l < len(h.count); l++ {
+		b, err := br.bit(1)
+		if err != nil {
+			return 0, err
+		}
+		if h.inverted {
+			b ^= 1
+		}
+
+		code |= int(b)
+		count := h.count[l]
+		if code-first < count {
+			return int(h.symbols[index+code-first]), nil
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+
+	return 0, br.invalid
+}
+
+type deflate64Reader struct {
+	bitReader
+	err error
+
+	// hist is the output so far, or at least the window's worth of
+	// it. out is where what hasn't been read yet starts.
+	hist []byte
+	out  int
+
+	final bool
+	// stored is how much of a stored block is left, lit and dist are
+	// the codes of a compressed one. All are zero between blocks.
+	stored int
+	lit    *canonicalHuffman
+	dist   *canonicalHuffman
+}
+
+func newDeflate64Reader(r io.Reader) io.ReadCloser {
+	return &deflate64Reader{bitReader: bitReader{r: bufio.NewReader(r), invalid: ErrInvalidDeflate64}}
+}
+
+func (d *deflate64Reader) Read(p []byte) (int, error) {
+	for d.out == len(d.hist) {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.step()
+	}
+
+	n := copy(p, d.hist[d.out:])
+	d.out += n
+	return n, nil
+}
+
+func (d *deflate64Reader) Close() error {
+	return nil
+}
+
+// step starts the next block or decodes some more of the current one.
+func (d *deflate64Reader) step() error {
+	// Only keep as much output as matches can refer back to.
+	if len(d.hist) > 2*deflate64WindowSize {
+		drop := len(d.hist) - deflate64WindowSize
+		d.hist = append(d.hist[:0], d.hist[drop:]...)
+		d.out -= drop
+	}
+
+	switch {
+	case d.stored > 0:
+		return d.copyStored()
+	case d.lit != nil:
+		return d.inflate()
+	case d.final:
+		return io.EOF
+	}
+
+	header, err := d.bit(3)
+	if err != nil {
+		return err
+	}
+	d.final = header&1 == 1
+
+	switch header >> 1 {
+	case 0:
+		d.bits, d.nbits = 0, 0
+		var b [4]byte
+		if _, err := io.ReadFull(d.r, b[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		length := int(b[0]) | int(b[1])<<8
+		if length != ^(int(b[2])|int(b[3])<<8)&0xFFFF {
+			return ErrInvalidDeflate64
+		}
+		d.stored = length
+		return nil
+	case 1:
+		return d.fixedCodes()
+	case 2:
+		return d.dynamicCodes()
+	default:
+		return ErrInvalidDeflate64
+	}
+}
+
+func (d *deflate64Reader) copyStored() error {
+	start := len(d.hist)
+	d.hist = append(d.hist, make([]byte, d.stored)...)
+	if _, err := io.ReadFull(d.r, d.hist[start:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	d.stored = 0
+	return nil
+}
+
+func (d *deflate64Reader) fixedCodes() error {
+	var lengths [288 + 32]uint8
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		case i < 288:
+			lengths[i] = 8
+		default:
+			lengths[i] = 5
+		}
+	}
+
+	return d.setCodes(lengths[:288], lengths[288:])
+}
+
+func (d *deflate64Reader) dynamicCodes() error {
+	var counts [3]uint32
+	for i, n := range []uint{5, 5, 4} {
+		v, err := d.bit(n)
+		if err != nil {
+			return err
+		}
+		counts[i] = v
+	}
+	nlit, ndist, nclen := int(counts[0])+257, int(counts[1])+1, int(counts[2])+4
+
+	var clens [19]uint8
+	for _, i := range deflate64CodeLengthOrder[:nclen] {
+		v, err := d.bit(3)
+		if err != nil {
+			return err
+		}
+		clens[i] = uint8(v)
+	}
+
+	clen, err := newCanonicalHuffman(clens[:])
+	if err != nil {
+		return err
+	}
+
+	lengths := make([]uint8, 0, nlit+ndist)
+	for len(lengths) < nlit+ndist {
+		sym, err := d.decode(clen)
+		if err != nil {
+			return err
+		}
+
+		if sym < 16 {
+			lengths = append(lengths, uint8(sym))
+			continue
+		}
+
+		var length uint8
+		var repeat uint32
+		switch sym {
+		case 16:
+			if len(lengths) == 0 {
+				return ErrInvalidDeflate64
+			}
+			length = lengths[len(lengths)-1]
+			repeat, err = d.bit(2)
+			repeat += 3
+		case 17:
+			repeat, err = d.bit(3)
+			repeat += 3
+		default:
+			repeat, err = d.bit(7)
+			repeat += 11
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(lengths)+int(repeat) > nlit+ndist {
+			return ErrInvalidDeflate64
+		}
+		for i := uint32(0); i < repeat; i++ {
+			lengths = append(lengths, length)
+		}
+	}
+
+	if lengths[256] == 0 {
+		return ErrInvalidDeflate64
+	}
+
+	return d.setCodes(lengths[:nlit], lengths[nlit:])
+}
+
+func (d *deflate64Reader) setCodes(lit, dist []uint8) error {
+	var err error
+	if d.lit, err = newCanonicalHuffman(lit); err != nil {
+		return err
+	}
+	if d.dist, err = newCanonicalHuffman(dist); err != nil {
+		d.lit = nil
+		return err
+	}
+
+	return nil
+}
+
+// inflate decodes symbols of the current compressed block until it
+// ends or a window's worth of output is ready.
+func (d *deflate64Reader) inflate() error {
+	for start := len(d.hist); len(d.hist)-start < deflate64WindowSize; {
+		sym, err := d.decode(d.lit)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case sym < 256:
+			d.hist = append(d.hist, byte(sym))
+			continue
+		case sym == 256:
+			d.lit, d.dist = nil, nil
+			return nil
+		}
+
+		sym -= 257
+		if sym >= len(deflate64LengthBase) {
+			return ErrInvalidDeflate64
+		}
+		extra, err := d.bit(uint(deflate64LengthBits[sym]))
+		if err != nil {
+			return err
+		}
+		length := int(deflate64LengthBase[sym]) + int(extra)
+
+		sym, err = d.decode(d.dist)
+		if err != nil {
+			return err
+		}
+		if sym >= len(deflate64DistanceBase) {
+			return ErrInvalidDeflate64
+		}
+		extra, err = d.bit(uint(deflate64DistanceBits[sym]))
+		if err != nil {
+			return err
+		}
+		distance := int(deflate64DistanceBase[sym]) + int(extra)
+		if distance > len(d.hist) {
+			return ErrInvalidDeflate64
+		}
+
+		from := len(d.hist) - distance
+		for i := 0; i < length; i++ {
+			d.hist = append(d.hist, d.hist[from+i])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package gozip
+
+import "fmt"
+
+var ErrEntryNotFound = fmt.Errorf("Entry not found")
+
+// Delete drops the entries named name from the central directory Close
+// writes, whether they were added to the Writer or found by Append, and
+// fails with ErrEntryNotFound if there are none. Their data stays in the
+// file; DeleteEntries rewrites an archive without it.
+func (w *Writer) Delete(name string) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if err := w.closeCurrent(); err != nil {
+		return err
+	}
+
+	kept := w.records[:0]
+	existing := w.existing
+	for i, cdr := range w.records {
+		if cdr.fileName == name {
+			if i < w.existing {
+				existing--
+			}
+			continue
+		}
+		kept = append(kept, cdr)
+	}
+
+	if len(kept) == len(w.records) {
+		return ErrEntryNotFound
+	}
+
+	w.existing = existing
+	w.records = kept
+	return nil
+}
+
+// DeleteEntries rewrites the archive in the named file without the
+// entries del reports true for, and returns how many there were. The
+// rest are copied as they are stored, without being decompressed, along
+// with the archive comment and anything prepended to the archive. The
+// file is left as it was if anything fails, and nothing is rewritten if
+// there is nothing to delete.
+func DeleteEntries(name string, del func(e *Entry) bool) (int, error) {
+	r, err := Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var kept []*Entry
+	for _, e := range r.entries {
+		if !del(e) {
+			kept = append(kept, e)
+		}
+	}
+
+	deleted := len(r.entries) - len(kept)
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	err = rewrite(name, r, func(w *Writer) error {
+		for _, e := range kept {
+			if err := w.CopyRaw(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
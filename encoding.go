@@ -0,0 +1,127 @@
+package gozip
+
+import (
+	"hash/crc32"
+	"strings"
+	"unicode/utf8"
+)
+
+// Names and comments not flagged as UTF-8 are in whatever codepage the
+// archiver's system used, which for the DOS and Windows tools that made
+// most of them is CP437.
+
+// Encoding decodes names and comments that aren't flagged as UTF-8.
+type Encoding func(b []byte) string
+
+// The upper halves of single byte codepages. The lower halves are
+// ASCII.
+var (
+	cp437High  = "ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜ¢£¥₧ƒáíóúñÑªº¿⌐¬½¼¡«»░▒▓│┤╡╢╖╕╣║╗╝╜╛┐└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀αßΓπΣσµτΦΘΩδ∞φε∩≡±≥≤⌠⌡÷≈°∙·√ⁿ²■\u00a0"
+	cp850High  = "ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜø£Ø×ƒáíóúñÑªº¿®¬½¼¡«»░▒▓│┤ÁÂÀ©╣║╗╝¢¥┐└┴┬├─┼ãÃ╚╔╩╦╠═╬¤ðÐÊËÈıÍÎÏ┘┌█▄¦Ì▀ÓßÔÒõÕµþÞÚÛÙýÝ¯´\u00ad±‗¾¶§÷¸°¨·¹³²■\u00a0"
+	cp866High  = "АБВГДЕЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯабвгдежзийклмноп░▒▓│┤╡╢╖╕╣║╗╝╜╛┐└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀рстуфхцчшщъыьэюяЁёЄєЇїЎў°∙·√№¤■\u00a0"
+	cp1252High = "€\u0081‚ƒ„…†‡ˆ‰Š‹Œ\u008dŽ\u008f\u0090‘’“”•–—˜™š›œ\u009džŸ\u00a0¡¢£¤¥¦§¨©ª«¬\u00ad®¯°±²³´µ¶·¸¹º»¼½¾¿ÀÁÂÃÄÅÆÇÈÉÊËÌÍÎÏÐÑÒÓÔÕÖ×ØÙÚÛÜÝÞßàáâãäåæçèéêëìíîïðñòóôõö÷øùúûüýþÿ"
+)
+
+func singleByteEncoding(high string) Encoding {
+	table := []rune(high)
+	return func(b []byte) string {
+		var sb strings.Builder
+		for _, c := range b {
+			if c < 0x80 {
+				sb.WriteByte(c)
+			} else {
+				sb.WriteRune(table[c-0x80])
+			}
+		}
+
+		return sb.String()
+	}
+}
+
+var encodings = map[string]Encoding{
+	"utf-8":  func(b []byte) string { return string(b) },
+	"cp437":  singleByteEncoding(cp437High),
+	"cp850":  singleByteEncoding(cp850High),
+	"cp866":  singleByteEncoding(cp866High),
+	"cp1252": singleByteEncoding(cp1252High),
+	"cp932":  decodeCP932,
+}
+
+// LookupEncoding returns the built-in encoding with the given name:
+// utf-8, cp437, cp850, cp866, cp1252 or cp932 (Shift JIS).
+func LookupEncoding(name string) (Encoding, bool) {
+	enc, ok := encodings[strings.ToLower(name)]
+	return enc, ok
+}
+
+// WithEncoding decodes names and comments that aren't flagged as UTF-8
+// with enc instead of guessing.
+func WithEncoding(enc Encoding) Option {
+	return func(r *Reader) {
+		r.encoding = enc
+	}
+}
+
+// Info-ZIP's extra fields for the UTF-8 name or comment of an entry
+// whose header has them in a codepage.
+const (
+	unicodePathExtraFieldID    = 0x7075
+	unicodeCommentExtraFieldID = 0x6375
+)
+
+// decodeName returns a name or comment as a string. Without the UTF-8
+// flag or an encoding to use, text from Unix and Mac hosts, as mode.go
+// lists them, is taken to be UTF-8 if it is valid UTF-8, and everything
+// else to be CP437.
+func decodeName(name []byte, flags, versionMadeBy uint16, enc Encoding) string {
+	if flags&utf8Flag != 0 {
+		return string(name)
+	}
+
+	if enc != nil {
+		return enc(name)
+	}
+
+	host := versionMadeBy >> 8
+	if (host == unixHost || host == macHost) && utf8.Valid(name) {
+		return string(name)
+	}
+
+	return encodings["cp437"](name)
+}
+
+// unicodeExtraField returns the UTF-8 text from a Unicode Path or
+// Comment extra field. The field records the CRC-32 of the header's
+// own bytes, raw, so one left behind by a tool that changed those
+// without knowing about it is ignored.
+func unicodeExtraField(extraField []byte, id uint16, raw string) (string, bool) {
+	data, ok := findExtraField(extraField, id)
+	if !ok || len(data) < 5 || data[0] != 1 {
+		return "", false
+	}
+
+	crc, i, err := readUint32(data, 1)
+	if err != nil || crc != crc32.ChecksumIEEE([]byte(raw)) {
+		return "", false
+	}
+
+	text := data[i:]
+	if !utf8.Valid(text) {
+		return "", false
+	}
+
+	return string(text), true
+}
+
+// decodeText decodes a name or comment from a header, preferring the
+// extra field with the given ID's UTF-8 version of it when the header's
+// isn't flagged UTF-8 already.
+func (r *Reader) decodeText(raw string, flags, versionMadeBy uint16, extraField []byte, id uint16) string {
+	if flags&utf8Flag == 0 {
+		if text, ok := unicodeExtraField(extraField, id, raw); ok {
+			return text
+		}
+	}
+
+	return decodeName([]byte(raw), flags, versionMadeBy, r.encoding)
+}
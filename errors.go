@@ -0,0 +1,90 @@
+package gozip
+
+import "fmt"
+
+// FormatError is how reading fails on an archive that isn't laid out
+// the way a zip file has to be. Err is the sentinel, such as
+// ErrOverranBuffer or ErrNotZip, that says what was wrong, and
+// errors.Is matches it.
+type FormatError struct {
+	// Offset is where in the file the structure that was wrong starts.
+	Offset int64
+	// Field is the structure, such as "central directory record".
+	Field string
+	Err   error
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("Invalid %s at offset %d: %v", e.Field, e.Offset, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// formatError wraps err in a FormatError for the structure field at
+// offset, unless it is an I/O error rather than a parsing one or is
+// already wrapped.
+func formatError(offset int64, field string, err error) error {
+	switch err {
+	case ErrOverranBuffer, ErrNotZip, ErrNoDataDescriptor, ErrInvalidZip64, ErrInvalidExtraField:
+		return &FormatError{Offset: offset, Field: field, Err: err}
+	}
+
+	return err
+}
+
+// ChecksumError is how reading an entry fails when its contents don't
+// match the CRC-32 stored in the archive. errors.Is matches it to
+// ErrChecksum.
+type ChecksumError struct {
+	Entry            string
+	Expected, Actual uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("Checksum mismatch in %s: expected %08x, got %08x", e.Entry, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum
+}
+
+// UnsupportedMethodError is how reading or writing an entry fails when
+// no compressor or decompressor is registered for its method. errors.Is
+// matches it to ErrUnsupportedCompression.
+type UnsupportedMethodError struct {
+	Method Compression
+}
+
+func (e *UnsupportedMethodError) Error() string {
+	return fmt.Sprintf("Unsupported compression method: %v", e.Method)
+}
+
+func (e *UnsupportedMethodError) Is(target error) bool {
+	return target == ErrUnsupportedCompression
+}
+
+// LimitError is how reading fails when an archive goes over one of the
+// Limits WithLimits sets. errors.Is matches it to ErrLimitExceeded.
+type LimitError struct {
+	// Limit is the field of Limits that was exceeded, such as
+	// "MaxEntrySize".
+	Limit string
+	// Entry is the entry that went over it, or empty for limits on the
+	// whole archive.
+	Entry      string
+	Value, Max uint64
+}
+
+func (e *LimitError) Error() string {
+	if e.Entry == "" {
+		return fmt.Sprintf("Archive exceeds %s: %d is over %d", e.Limit, e.Value, e.Max)
+	}
+
+	return fmt.Sprintf("%s exceeds %s: %d is over %d", e.Entry, e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
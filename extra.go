@@ -0,0 +1,334 @@
+package gozip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// An extra field is a run of records, each a 2 byte ID and 2 byte
+// length followed by that much data. These are the IDs of the records
+// with accessors here, alongside zip64ExtraFieldID, aesExtraFieldID and
+// the Unicode Path and Comment IDs.
+const (
+	ntfsExtraFieldID              = 0x000a
+	pkwareUnixExtraFieldID        = 0x000d
+	extendedTimestampExtraFieldID = 0x5455
+	infoZIPUnixExtraFieldID       = 0x7875
+)
+
+var ErrInvalidExtraField = fmt.Errorf("Invalid extra field")
+
+// ExtraField is a single record of an entry's extra field.
+type ExtraField struct {
+	ID   uint16
+	Data []byte
+}
+
+// ParseExtraFields splits an extra field into its records.
+func ParseExtraFields(extraField []byte) ([]ExtraField, error) {
+	var fields []ExtraField
+	for i := 0; i < len(extraField); {
+		id, next, err := readUint16(extraField, i)
+		if err != nil {
+			return nil, ErrInvalidExtraField
+		}
+
+		size, next, err := readUint16(extraField, next)
+		if err != nil {
+			return nil, ErrInvalidExtraField
+		}
+
+		data, next, err := readBytes(extraField, next, int(size))
+		if err != nil {
+			return nil, ErrInvalidExtraField
+		}
+
+		fields = append(fields, ExtraField{ID: id, Data: data})
+		i = next
+	}
+
+	return fields, nil
+}
+
+// EncodeExtraFields joins records into an extra field, to set as an
+// Entry's Extra when writing it.
+func EncodeExtraFields(fields ...ExtraField) ([]byte, error) {
+	var b byteWriter
+	for _, f := range fields {
+		if len(f.Data) > 0xFFFF {
+			return nil, ErrTooLarge
+		}
+
+		b.uint16(f.ID)
+		b.uint16(uint16(len(f.Data)))
+		b.Write(f.Data)
+	}
+
+	if b.Len() > 0xFFFF {
+		return nil, ErrTooLarge
+	}
+
+	return b.Bytes(), nil
+}
+
+// ExtraFields returns the records of the entry's extra field.
+func (e *Entry) ExtraFields() ([]ExtraField, error) {
+	return ParseExtraFields(e.Extra)
+}
+
+// LocalExtra reads the extra field of the entry's local header, which
+// needn't be the same as Extra, the central directory's. The
+// specification makes the central directory authoritative, and it is
+// what the Reader goes by: only it has the ZIP64 local header offset,
+// and only its ZIP64 record leaves out the sizes that fit in 32 bits.
+// Local headers carry what only matters to streaming readers, such as
+// alignment padding, and some archivers give them more of the
+// timestamp and ownership fields than the central directory.
+func (e *Entry) LocalExtra() ([]byte, error) {
+	lfh, err := e.localHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	return lfh.extraField, nil
+}
+
+// Zip64ExtraField holds the 64-bit values of the central directory
+// fields that didn't fit. Only those that didn't are set.
+type Zip64ExtraField struct {
+	UncompressedSize  uint64
+	CompressedSize    uint64
+	LocalHeaderOffset uint64
+	DiskNumberStart   uint32
+}
+
+// Zip64ExtraField returns the values the entry's ZIP64 record gave its
+// central directory record, if it needed one.
+func (e *Entry) Zip64ExtraField() (*Zip64ExtraField, bool) {
+	if e.zip64 == nil {
+		return nil, false
+	}
+
+	z := *e.zip64
+	return &z, true
+}
+
+// ExtendedTimestamp is the extended timestamp extra field, 0x5455, of
+// Unix times in seconds. Times that aren't in it are zero. The central
+// directory's copy only ever has the modification time.
+type ExtendedTimestamp struct {
+	Modified time.Time
+	Accessed time.Time
+	Created  time.Time
+}
+
+// ExtendedTimestamp returns the entry's extended timestamp extra field.
+func (e *Entry) ExtendedTimestamp() (*ExtendedTimestamp, bool) {
+	data, ok := findExtraField(e.Extra, extendedTimestampExtraFieldID)
+	if !ok || len(data) < 1 {
+		return nil, false
+	}
+
+	flags, data := data[0], data[1:]
+	var ts ExtendedTimestamp
+	for bit, t := range []*time.Time{&ts.Modified, &ts.Accessed, &ts.Created} {
+		if flags&(1<<bit) == 0 || len(data) < 4 {
+			continue
+		}
+
+		*t = time.Unix(int64(int32(binary.LittleEndian.Uint32(data))), 0).UTC()
+		data = data[4:]
+	}
+
+	return &ts, true
+}
+
+// ExtraField encodes the times that aren't zero.
+func (ts *ExtendedTimestamp) ExtraField() ExtraField {
+	var flags byte
+	var times byteWriter
+	for bit, t := range []time.Time{ts.Modified, ts.Accessed, ts.Created} {
+		if !t.IsZero() {
+			flags |= 1 << bit
+			times.uint32(uint32(int32(t.Unix())))
+		}
+	}
+
+	return ExtraField{ID: extendedTimestampExtraFieldID, Data: append([]byte{flags}, times.Bytes()...)}
+}
+
+// setPreciseTimes replaces the entry's MS-DOS modification time, which
+// is to 2 seconds in whatever the archiver's time zone was, with the one
+// from the NTFS or extended timestamp extra field if there is one, and
+// sets the access and creation times from them too.
+func (e *Entry) setPreciseTimes() {
+	if nt, ok := e.NTFSTimes(); ok {
+		e.Modified, e.Accessed, e.Created = nt.Modified.Local(), nt.Accessed.Local(), nt.Created.Local()
+		return
+	}
+
+	if ts, ok := e.ExtendedTimestamp(); ok && !ts.Modified.IsZero() {
+		e.Modified = ts.Modified.Local()
+		if !ts.Accessed.IsZero() {
+			e.Accessed = ts.Accessed.Local()
+		}
+		if !ts.Created.IsZero() {
+			e.Created = ts.Created.Local()
+		}
+	}
+}
+
+// NTFSTimes is the NTFS extra field, 0x000a, of times to 100ns. Zero
+// times are stored as 0.
+type NTFSTimes struct {
+	Modified time.Time
+	Accessed time.Time
+	Created  time.Time
+}
+
+// ntfsEpoch is the start of NTFS time, in Unix seconds.
+const ntfsEpoch = -11644473600
+
+func ntfsTime(t uint64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(t/1e7)+ntfsEpoch, int64(t%1e7)*100).UTC()
+}
+
+func toNTFSTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.Unix()-ntfsEpoch)*1e7 + uint64(t.Nanosecond()/100)
+}
+
+// NTFSTimes returns the times from the entry's NTFS extra field.
+func (e *Entry) NTFSTimes() (*NTFSTimes, bool) {
+	data, ok := findExtraField(e.Extra, ntfsExtraFieldID)
+	if !ok || len(data) < 4 {
+		return nil, false
+	}
+
+	// Four reserved bytes then attributes, each a tag and size. Tag 1
+	// is the times.
+	for i := 4; i+4 <= len(data); {
+		tag := binary.LittleEndian.Uint16(data[i:])
+		size := int(binary.LittleEndian.Uint16(data[i+2:]))
+		i += 4
+		if i+size > len(data) {
+			break
+		}
+
+		if tag == 1 && size >= 24 {
+			return &NTFSTimes{
+				Modified: ntfsTime(binary.LittleEndian.Uint64(data[i:])),
+				Accessed: ntfsTime(binary.LittleEndian.Uint64(data[i+8:])),
+				Created:  ntfsTime(binary.LittleEndian.Uint64(data[i+16:])),
+			}, true
+		}
+		i += size
+	}
+
+	return nil, false
+}
+
+// ExtraField encodes the times.
+func (nt *NTFSTimes) ExtraField() ExtraField {
+	var b byteWriter
+	b.uint32(0)
+	b.uint16(1)
+	b.uint16(24)
+	for _, t := range []time.Time{nt.Modified, nt.Accessed, nt.Created} {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], toNTFSTime(t))
+		b.Write(buf[:])
+	}
+
+	return ExtraField{ID: ntfsExtraFieldID, Data: b.Bytes()}
+}
+
+// UnixOwner is the user and group IDs of an entry from Info-ZIP's Unix
+// extra field, 0x7875, or failing that PKWARE's, 0x000d.
+type UnixOwner struct {
+	UID uint32
+	GID uint32
+}
+
+// readUnixID reads a little endian ID of size bytes as Info-ZIP's Unix
+// extra field stores them.
+func readUnixID(data []byte, size int) (uint32, bool) {
+	if size > len(data) || size > 8 {
+		return 0, false
+	}
+
+	var buf [8]byte
+	copy(buf[:], data[:size])
+	id := binary.LittleEndian.Uint64(buf[:])
+	return uint32(id), id <= 0xFFFFFFFF
+}
+
+// UnixOwner returns the entry's Unix user and group IDs.
+func (e *Entry) UnixOwner() (*UnixOwner, bool) {
+	if data, ok := findExtraField(e.Extra, infoZIPUnixExtraFieldID); ok && len(data) >= 2 && data[0] == 1 {
+		uidSize := int(data[1])
+		uid, ok := readUnixID(data[2:], uidSize)
+		if ok && 2+uidSize < len(data) {
+			gidSize := int(data[2+uidSize])
+			if gid, ok := readUnixID(data[3+uidSize:], gidSize); ok {
+				return &UnixOwner{UID: uid, GID: gid}, true
+			}
+		}
+	}
+
+	// Access and modification times then 16-bit IDs.
+	if data, ok := findExtraField(e.Extra, pkwareUnixExtraFieldID); ok && len(data) >= 12 {
+		return &UnixOwner{
+			UID: uint32(binary.LittleEndian.Uint16(data[8:])),
+			GID: uint32(binary.LittleEndian.Uint16(data[10:])),
+		}, true
+	}
+
+	return nil, false
+}
+
+// ExtraField encodes the IDs as Info-ZIP's Unix extra field.
+func (o *UnixOwner) ExtraField() ExtraField {
+	var b byteWriter
+	b.WriteByte(1)
+	b.WriteByte(4)
+	b.uint32(o.UID)
+	b.WriteByte(4)
+	b.uint32(o.GID)
+	return ExtraField{ID: infoZIPUnixExtraFieldID, Data: b.Bytes()}
+}
+
+// AESExtraField is the WinZip AES extra field, 0x9901, of an encrypted
+// entry.
+type AESExtraField struct {
+	// Version is 1 for AE-1 or 2 for AE-2, which leaves the CRC-32
+	// out.
+	Version uint16
+	// KeyBits is 128, 192 or 256.
+	KeyBits int
+	// Method is what the entry's data is compressed with under the
+	// encryption.
+	Method Compression
+}
+
+// AESExtraField returns the entry's AES extra field.
+func (e *Entry) AESExtraField() (*AESExtraField, bool) {
+	a, err := parseAESExtraField(e.Extra)
+	if err != nil {
+		return nil, false
+	}
+
+	return &AESExtraField{
+		Version: a.version,
+		KeyBits: 8 * a.keyLength(),
+		Method:  a.compression,
+	}, true
+}
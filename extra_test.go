@@ -0,0 +1,107 @@
+package gozip
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLocalExtra(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		if err := w.SetAlignment(4); err != nil {
+			t.Fatal(err)
+		}
+		writeEntry(t, w, "a.txt", NoCompression, []byte("a"))
+	})
+
+	tests := []struct {
+		name string
+		bs   []byte
+		// aligned is whether Extra has the alignment padding, which
+		// only the local header does.
+		aligned bool
+	}{
+		{"central directory", bs, false},
+		{"local headers", bs[:bytes.Index(bs, []byte("PK\x01\x02"))], true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := lookupEntry(t, readArchive(t, test.bs), "a.txt")
+			if _, ok := findExtraField(e.Extra, alignmentExtraFieldID); ok != test.aligned {
+				t.Errorf("alignment in Extra is %t, want %t", ok, test.aligned)
+			}
+
+			local, err := e.LocalExtra()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := findExtraField(local, alignmentExtraFieldID); !ok {
+				t.Error("no alignment in LocalExtra")
+			}
+			if _, ok := findExtraField(local, extendedTimestampExtraFieldID); !ok {
+				t.Error("no timestamp in LocalExtra")
+			}
+		})
+	}
+}
+
+func TestLocalExtraZip64(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, []byte("a"))
+	})
+
+	// Only the central directory has the ZIP64 record forceZip64 adds.
+	e := lookupEntry(t, readArchive(t, forceZip64(t, bs, true)), "a.txt")
+	if _, ok := findExtraField(e.Extra, zip64ExtraFieldID); !ok {
+		t.Error("no ZIP64 record in Extra")
+	}
+
+	local, err := e.LocalExtra()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := findExtraField(local, zip64ExtraFieldID); ok {
+		t.Error("ZIP64 record in LocalExtra")
+	}
+}
+
+func TestSubsecondTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		modified time.Time
+		accessed time.Time
+		ntfs     bool
+	}{
+		{"whole seconds", testModified.Add(time.Second), time.Time{}, false},
+		{"subsecond", testModified.Add(1234567800), time.Time{}, true},
+		{"subsecond accessed too", testModified.Add(1234567800), testModified.Add(time.Hour + 100), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				e := &Entry{Name: "a.txt", Modified: test.modified, Accessed: test.accessed}
+				if err := w.WriteEntry(e, []byte("a")); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			for _, bs := range [][]byte{bs, bs[:bytes.Index(bs, []byte("PK\x01\x02"))]} {
+				e := lookupEntry(t, readArchive(t, bs), "a.txt")
+				if _, ok := e.NTFSTimes(); ok != test.ntfs {
+					t.Errorf("NTFS extra field is %t, want %t", ok, test.ntfs)
+				}
+				if !e.Modified.Equal(test.modified) {
+					t.Errorf("modified at %s, want %s", e.Modified, test.modified)
+				}
+				if !e.Accessed.Equal(test.accessed) {
+					t.Errorf("accessed at %s, want %s", e.Accessed, test.accessed)
+				}
+				if !e.Created.IsZero() {
+					t.Errorf("created at %s, want zero", e.Created)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,493 @@
+package gozip
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrChecksum       = fmt.Errorf("Checksum mismatch")
+	ErrUnsafePath     = fmt.Errorf("Entry path escapes the extraction directory")
+	ErrBufferTooSmall = fmt.Errorf("Buffer smaller than the entry's contents")
+	ErrEntryTooLong   = fmt.Errorf("Entry longer than its uncompressed size")
+)
+
+// IsDir reports whether the entry is a directory, which zip marks with
+// a trailing slash on the name, though some archivers only set the
+// directory attribute.
+func (e *Entry) IsDir() bool {
+	return strings.HasSuffix(e.Name, "/") || e.attrMode().IsDir()
+}
+
+// hasCRC32 reports whether the entry's CRC-32 can be checked. AE-2
+// encrypted entries leave it zero since their authentication code
+// already covers the data, and directories have no data.
+func (e *Entry) hasCRC32() bool {
+	if e.IsDir() {
+		return false
+	}
+
+	if e.Method != aesCompression {
+		return true
+	}
+
+	a, err := parseAESExtraField(e.Extra)
+	return err != nil || a.version != aesVersion2
+}
+
+// WriteTo writes the entry's decompressed contents to w and checks
+// them against the CRC-32 stored in the archive once they have all been
+// written, failing with a *ChecksumError only after w has had everything.
+func (e *Entry) WriteTo(w io.Writer) (int64, error) {
+	return e.writeTo(context.Background(), w)
+}
+
+func (e *Entry) writeTo(ctx context.Context, w io.Writer) (int64, error) {
+	rc, err := e.OpenContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(w, crc), rc)
+	if err != nil {
+		return n, err
+	}
+
+	if e.hasCRC32() && crc.Sum32() != e.CRC32 {
+		return n, &ChecksumError{Entry: e.Name, Expected: e.CRC32, Actual: crc.Sum32()}
+	}
+
+	return n, nil
+}
+
+// Verify decompresses the entry and checks its contents against the
+// CRC-32 stored in the archive.
+func (e *Entry) Verify() error {
+	_, err := e.WriteTo(ioutil.Discard)
+	return err
+}
+
+// ReadAll decompresses the entry's whole contents and checks them
+// against the CRC-32 stored in the archive.
+func (e *Entry) ReadAll() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := e.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ReadInto decompresses the entry's contents into buf, which must be at
+// least UncompressedSize bytes long, and checks them against the CRC-32
+// stored in the archive. It returns how many bytes it read. Stored and
+// deflated entries that aren't encrypted are read without allocating,
+// with deflate's decompressors reused from one call to the next, unless
+// the Reader has limits or reports progress; others are read through
+// Open. Contents longer than UncompressedSize fail with
+// ErrEntryTooLong.
+func (e *Entry) ReadInto(buf []byte) (int, error) {
+	if uint64(len(buf)) < e.UncompressedSize {
+		return 0, ErrBufferTooSmall
+	}
+	buf = buf[:e.UncompressedSize]
+
+	if e.Flags&encryptedFlag == 0 && e.limits == nil && e.progress == nil && !e.IsDir() {
+		switch e.Method {
+		case NoCompression:
+			return e.readStoredInto(buf)
+		case DeflateCompression:
+			return e.inflateInto(buf)
+		}
+	}
+
+	rc, err := e.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	n, err := io.ReadFull(rc, buf)
+	if err != nil {
+		return n, err
+	}
+
+	// Reading to the end checks a WinZip AES entry's authentication
+	// code, and that there is nothing more.
+	if extra, err := io.Copy(ioutil.Discard, rc); err != nil {
+		return n, err
+	} else if extra > 0 {
+		return n, ErrEntryTooLong
+	}
+
+	return n, e.checkCRC32(buf)
+}
+
+func (e *Entry) checkCRC32(contents []byte) error {
+	if crc := crc32.ChecksumIEEE(contents); e.hasCRC32() && crc != e.CRC32 {
+		return &ChecksumError{Entry: e.Name, Expected: e.CRC32, Actual: crc}
+	}
+
+	return nil
+}
+
+// readStoredInto is ReadInto for a stored entry, read straight from the
+// archive into buf.
+func (e *Entry) readStoredInto(buf []byte) (int, error) {
+	offset, err := e.DataOffset()
+	if err != nil {
+		return 0, err
+	}
+	if e.CompressedSize > e.UncompressedSize {
+		return 0, ErrEntryTooLong
+	}
+
+	n := 0
+	if e.CompressedSize == e.UncompressedSize {
+		n, err = e.r.ReadAt(buf, offset)
+	}
+	if n < len(buf) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	return n, e.checkCRC32(buf)
+}
+
+// inflater is what inflateInto decompresses with, kept in inflaters
+// between calls.
+type inflater struct {
+	data io.SectionReader
+	br   *bufio.Reader
+	fr   io.ReadCloser
+	// extra is room to look for contents past the end.
+	extra [1]byte
+}
+
+var inflaters = sync.Pool{New: func() interface{} { return new(inflater) }}
+
+// inflateInto is ReadInto for a deflated entry.
+func (e *Entry) inflateInto(buf []byte) (int, error) {
+	offset, err := e.DataOffset()
+	if err != nil {
+		return 0, err
+	}
+
+	f := inflaters.Get().(*inflater)
+	defer inflaters.Put(f)
+
+	f.data = *io.NewSectionReader(e.r, offset, int64(e.CompressedSize))
+	if f.br == nil {
+		f.br = bufio.NewReader(&f.data)
+		f.fr = flate.NewReader(f.br)
+	} else {
+		f.br.Reset(&f.data)
+		if err := f.fr.(flate.Resetter).Reset(f.br, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := io.ReadFull(f.fr, buf)
+	if err != nil {
+		return n, err
+	}
+	for {
+		m, err := f.fr.Read(f.extra[:])
+		if m > 0 {
+			return n, ErrEntryTooLong
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, e.checkCRC32(buf)
+}
+
+// ExtractPath returns where Extract writes the entry under dir. Names
+// that are absolute or have .. components, with either slash, would
+// escape dir and are refused with ErrUnsafePath, as are paths through
+// symlinks already on disk that lead out of dir.
+func (e *Entry) ExtractPath(dir string) (string, error) {
+	return e.ExtractPathAs(dir, e.Name)
+}
+
+// ExtractPathAs returns where ExtractAs writes the entry under dir as
+// name.
+func (e *Entry) ExtractPathAs(dir, name string) (string, error) {
+	if strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") || (len(name) >= 2 && name[1] == ':') {
+		return "", ErrUnsafePath
+	}
+
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := checkSymlinks(dir, path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// checkSymlinks walks from dir down to path and refuses any symlink on
+// the way that resolves outside dir, or to nothing yet, since writing
+// through it would then land outside dir.
+func checkSymlinks(dir, path string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(abs)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+
+	current := dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		target, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			target, err = filepath.Abs(target)
+		}
+		if os.IsNotExist(err) {
+			return ErrUnsafePath
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return ErrUnsafePath
+		}
+	}
+
+	return nil
+}
+
+// Extract writes the entry under dir, creating any parent directories
+// it needs, and sets its permissions and modification time to the ones
+// in the archive. A file whose contents fail verification is removed
+// again. Entries that would end up outside dir, or symlinks that would
+// lead out of it, are refused with ErrUnsafePath.
+func (e *Entry) Extract(dir string) error {
+	return e.ExtractAs(dir, e.Name)
+}
+
+// ExtractAs is like Extract but writes the entry under dir as name,
+// which is held to the same rules as entry names.
+func (e *Entry) ExtractAs(dir, name string) error {
+	return e.ExtractAsContext(context.Background(), dir, name)
+}
+
+// ExtractAsContext is ExtractAs with ExtractContext's cancellation.
+func (e *Entry) ExtractAsContext(ctx context.Context, dir, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := e.ExtractPathAs(dir, name)
+	if err != nil {
+		return err
+	}
+
+	perm := e.Mode().Perm()
+	if e.IsDir() {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+
+		// The directory has to stay writable for its entries to be
+		// extracted into it.
+		if err := os.Chmod(path, perm|0700); err != nil {
+			return err
+		}
+
+		return e.chtimes(path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Symlinks' times and permissions are left as they are made, since
+	// setting them would follow the link.
+	if e.IsSymlink() {
+		if !e.noSymlinks {
+			return e.extractSymlink(dir, path)
+		}
+		perm = 0644
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.writeTo(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	// OpenFile leaves an existing file's mode alone and applies the
+	// umask to a new one's.
+	if err := os.Chmod(path, perm); err != nil {
+		return err
+	}
+
+	return e.chtimes(path)
+}
+
+// chtimes sets path's modification time to the entry's, and its access
+// time too unless the archive recorded that separately.
+func (e *Entry) chtimes(path string) error {
+	atime := e.Accessed
+	if atime.IsZero() {
+		atime = e.Modified
+	}
+
+	return os.Chtimes(path, atime, e.Modified)
+}
+
+// ExtractResult is what became of an entry when an archive was
+// extracted, as a manifest of the extraction records it.
+type ExtractResult struct {
+	Name string
+	// Path is where the entry was written, or empty if it wasn't.
+	Path     string
+	Size     uint64
+	Mode     os.FileMode
+	Modified time.Time
+	CRC32    uint32
+	// Err is why the entry wasn't extracted, or nil if it was.
+	Err error
+}
+
+// Result returns the ExtractResult of extracting e to path, or of
+// failing to with err.
+func (e *Entry) Result(path string, err error) ExtractResult {
+	if err != nil {
+		path = ""
+	}
+
+	return ExtractResult{
+		Name:     e.Name,
+		Path:     path,
+		Size:     e.UncompressedSize,
+		Mode:     e.Mode(),
+		Modified: e.Modified,
+		CRC32:    e.CRC32,
+		Err:      err,
+	}
+}
+
+// ExtractAll extracts every entry under dir as Extract does and returns
+// what became of each, in the order Entries has them. Symlinks are made
+// last, so no file is written through one, and directories get their
+// times and permissions once everything in them is written. An entry
+// that fails, including one refused with ErrUnsafePath, is recorded
+// with its error and the rest are extracted anyway; the first such
+// error is returned too. Once ctx is done, the entries not yet
+// extracted are recorded with its error.
+func (r *Reader) ExtractAll(ctx context.Context, dir string) ([]ExtractResult, error) {
+	results := make([]ExtractResult, len(r.entries))
+	var firstErr error
+	extract := func(i int) {
+		e := r.entries[i]
+		path, err := e.ExtractPath(dir)
+		if err == nil {
+			err = e.ExtractAsContext(ctx, dir, e.Name)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		results[i] = e.Result(path, err)
+	}
+
+	for i, e := range r.entries {
+		if !e.IsSymlink() {
+			extract(i)
+		}
+	}
+	for i, e := range r.entries {
+		if e.IsSymlink() {
+			extract(i)
+		}
+	}
+
+	// Directories were left writable for their entries, and writing
+	// those updated their times. They're finished deepest first, so a
+	// directory is never locked before one inside it, wherever the
+	// archive puts their entries relative to each other and to their
+	// files.
+	var dirs []int
+	for i, e := range r.entries {
+		if results[i].Err == nil && e.IsDir() {
+			dirs = append(dirs, i)
+		}
+	}
+	depth := func(i int) int {
+		return strings.Count(strings.TrimSuffix(r.entries[i].Name, "/"), "/")
+	}
+	sort.SliceStable(dirs, func(a, b int) bool {
+		return depth(dirs[a]) > depth(dirs[b])
+	})
+	for _, i := range dirs {
+		e, res := r.entries[i], results[i]
+		err := os.Chmod(res.Path, e.Mode().Perm())
+		if err == nil {
+			err = e.chtimes(res.Path)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return results, firstErr
+}
@@ -0,0 +1,342 @@
+package gozip
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestExtractUnsafePaths(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"file.txt", nil},
+		{"dir/file.txt", nil},
+		{"dots..in..name", nil},
+		{"../evil", ErrUnsafePath},
+		{"dir/../../evil", ErrUnsafePath},
+		{"dir/..", ErrUnsafePath},
+		{`..\evil`, ErrUnsafePath},
+		{"/etc/evil", ErrUnsafePath},
+		{`\evil`, ErrUnsafePath},
+		{"C:evil", ErrUnsafePath},
+		// A symlink already in the directory that leads out of it.
+		{"outside/evil", ErrUnsafePath},
+		{"inside/file.txt", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				writeEntry(t, w, test.name, DeflateCompression, []byte("contents\n"))
+			})
+
+			root := t.TempDir()
+			dir := filepath.Join(root, "dir")
+			if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(root, filepath.Join(dir, "outside")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink("sub", filepath.Join(dir, "inside")); err != nil {
+				t.Fatal(err)
+			}
+
+			err := lookupEntry(t, readArchive(t, bs), test.name).Extract(dir)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err == nil {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(test.name))); err != nil {
+					t.Error(err)
+				}
+			}
+
+			// Nothing may have been written next to dir.
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("%d files outside the directory", len(entries)-1)
+			}
+		})
+	}
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		err    error
+	}{
+		{"sibling", "file.txt", nil},
+		{"subdirectory", "sub/file.txt", nil},
+		{"up and back in", "../dir/file.txt", nil},
+		{"absolute", "/etc/passwd", ErrUnsafePath},
+		{"out", "../outside", ErrUnsafePath},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				e := &Entry{Name: "link", Modified: testModified, Method: NoCompression}
+				e.SetMode(os.ModeSymlink | 0777)
+				if err := w.WriteEntry(e, []byte(test.target)); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			dir := filepath.Join(t.TempDir(), "dir")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			r := readArchive(t, bs)
+			err := lookupEntry(t, r, "link").Extract(dir)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+
+			got, err := os.Readlink(filepath.Join(dir, "link"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != filepath.FromSlash(test.target) {
+				t.Errorf("link points to %q, want %q", got, test.target)
+			}
+
+			// Without symlinks, the link is a file holding its target.
+			dir = t.TempDir()
+			r = readArchive(t, bs, WithoutSymlinks())
+			if err := lookupEntry(t, r, "link").Extract(dir); err != nil {
+				t.Fatal(err)
+			}
+			contents, err := os.ReadFile(filepath.Join(dir, "link"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(contents) != test.target {
+				t.Errorf("got %q, want %q", contents, test.target)
+			}
+		})
+	}
+}
+
+func TestExtractAll(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "dir/", NoCompression, nil)
+		writeEntry(t, w, "dir/a.txt", DeflateCompression, []byte("a\n"))
+		link := &Entry{Name: "link", Modified: testModified, Method: NoCompression}
+		link.SetMode(os.ModeSymlink | 0777)
+		if err := w.WriteEntry(link, []byte("dir/a.txt")); err != nil {
+			t.Fatal(err)
+		}
+		writeEntry(t, w, "../evil", DeflateCompression, []byte("evil\n"))
+	})
+
+	dir := t.TempDir()
+	results, err := readArchive(t, bs).ExtractAll(context.Background(), dir)
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("got %v, want %v", err, ErrUnsafePath)
+	}
+
+	want := []struct {
+		name string
+		err  error
+	}{
+		{"dir/", nil},
+		{"dir/a.txt", nil},
+		{"link", nil},
+		{"../evil", ErrUnsafePath},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		res := results[i]
+		if res.Name != w.name || !errors.Is(res.Err, w.err) {
+			t.Errorf("got %s: %v, want %s: %v", res.Name, res.Err, w.name, w.err)
+		}
+		if (res.Path == "") != (w.err != nil) {
+			t.Errorf("%s: got path %q", res.Name, res.Path)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(testModified) {
+		t.Errorf("directory modified at %s, want %s", info.ModTime(), testModified)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "dir/a.txt" {
+		t.Errorf("got link to %q, %v", target, err)
+	}
+}
+
+func TestExtractAllDirectoriesAfterFiles(t *testing.T) {
+	// Each directory's entry comes after the files in it, and a/'s after
+	// a/b/'s, so neither is known when its files are written.
+	parent := testModified.Add(-time.Hour)
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a/b/c/d.txt", DeflateCompression, []byte("deep\n"))
+		writeEntry(t, w, "a/e.txt", DeflateCompression, []byte("shallow\n"))
+		for _, d := range []struct {
+			name     string
+			mode     os.FileMode
+			modified time.Time
+		}{
+			{"a/b/", 0750, testModified},
+			{"a/", 0700, parent},
+		} {
+			e := &Entry{Name: d.name, Modified: d.modified, Method: NoCompression}
+			e.SetMode(os.ModeDir | d.mode)
+			if err := w.WriteEntry(e, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	dir := t.TempDir()
+	if _, err := readArchive(t, bs).ExtractAll(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		name     string
+		mode     os.FileMode
+		modified time.Time
+	}{
+		{"a", 0700, parent},
+		{"a/b", 0750, testModified},
+	} {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(want.name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want.mode {
+			t.Errorf("%s: got mode %v, want %v", want.name, info.Mode().Perm(), want.mode)
+		}
+		if !info.ModTime().Equal(want.modified) {
+			t.Errorf("%s: modified at %s, want %s", want.name, info.ModTime(), want.modified)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a", "b", "c", "d.txt")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadInto(t *testing.T) {
+	contents := bytes.Repeat([]byte("read into\n"), 1<<16)
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "stored.txt", NoCompression, contents)
+		writeEntry(t, w, "deflated.txt", DeflateCompression, contents)
+		writeEntry(t, w, "corrupt.txt", NoCompression, contents)
+		w.SetEncryption(AESEncryption, "secret")
+		writeEntry(t, w, "encrypted.txt", DeflateCompression, contents)
+	})
+	r := readArchive(t, bs)
+	corrupt := lookupEntry(t, r, "corrupt.txt")
+	data, err := corrupt.DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs[data] ^= 0xFF
+	r = readArchive(t, bs, WithPassword("secret"))
+
+	if _, err := lookupEntry(t, r, "stored.txt").ReadInto(make([]byte, len(contents)-1)); err != ErrBufferTooSmall {
+		t.Errorf("got %v with too small a buffer, want %v", err, ErrBufferTooSmall)
+	}
+
+	// The same buffer, with room to spare, is reused for every entry.
+	buf := make([]byte, len(contents)+100)
+	for _, name := range []string{"stored.txt", "deflated.txt", "encrypted.txt"} {
+		for i := range buf {
+			buf[i] = 0
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		n, err := lookupEntry(t, r, name).ReadInto(buf)
+		runtime.ReadMemStats(&after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], contents) {
+			t.Errorf("%s: contents differ", name)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(len(contents))/4 {
+			t.Errorf("%s: allocated %d bytes reading %d", name, allocated, len(contents))
+		}
+	}
+
+	var ce *ChecksumError
+	if _, err := lookupEntry(t, r, "corrupt.txt").ReadInto(buf); !errors.As(err, &ce) {
+		t.Errorf("got %v reading a corrupt entry, want a *ChecksumError", err)
+	}
+
+	// Stored and deflated entries are read without allocating.
+	for _, name := range []string{"stored.txt", "deflated.txt"} {
+		e := lookupEntry(t, r, name)
+		allocs := testing.AllocsPerRun(10, func() {
+			if _, err := e.ReadInto(buf); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%s: %v allocations reading into a buffer, want 0", name, allocs)
+		}
+	}
+
+	// Entries that decompress to more than they say they do.
+	short := append([]byte(nil), bs...)
+	for cd := 0; ; cd += 4 {
+		i := bytes.Index(short[cd:], []byte("PK\x01\x02"))
+		if i < 0 {
+			break
+		}
+		cd += i
+		binary.LittleEndian.PutUint32(short[cd+24:], uint32(len(contents)-1))
+	}
+	r = readArchive(t, short, WithPassword("secret"))
+	for _, name := range []string{"stored.txt", "deflated.txt", "encrypted.txt"} {
+		if _, err := lookupEntry(t, r, name).ReadInto(buf); err != ErrEntryTooLong {
+			t.Errorf("%s: got %v reading past the size, want %v", name, err, ErrEntryTooLong)
+		}
+	}
+}
+
+func BenchmarkReadInto(b *testing.B) {
+	contents := bytes.Repeat([]byte("read into\n"), 1<<16)
+	bs := writeArchive(b, func(w *Writer) {
+		writeEntry(b, w, "stored.txt", NoCompression, contents)
+		writeEntry(b, w, "deflated.txt", DeflateCompression, contents)
+	})
+	r := readArchive(b, bs)
+	buf := make([]byte, len(contents))
+
+	for _, name := range []string{"stored.txt", "deflated.txt"} {
+		e := lookupEntry(b, r, name)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(contents)))
+			for i := 0; i < b.N; i++ {
+				if _, err := e.ReadInto(buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package gozip
+
+// Flush pushes what has been written so far out to the underlying
+// writer: the current entry's compressed data, then the underlying
+// writer's own buffer if it has a Flush method, as bufio.Writer and
+// http.ResponseWriter do. A handler streaming an archive of entries
+// that are slow to generate can call it so the client gets them as
+// they come rather than when the response's buffer fills.
+func (w *Writer) Flush() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if ew := w.current; ew != nil {
+		if f, ok := ew.compressor.(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch f := w.w.w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,414 @@
+package gozip
+
+import (
+	"compress/flate"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fsNode is a file or directory in the tree the archive's entries make.
+// Directories that only appear as part of other entries' names have no
+// entry of their own.
+type fsNode struct {
+	name     string
+	entry    *Entry
+	dir      bool
+	children []*fsNode
+}
+
+// fsTree returns the archive's entries by cleaned path, with every
+// directory they are in. Entries whose names can't be fs paths, such as
+// ones with .. in them, are left out.
+func (r *Reader) fsTree() map[string]*fsNode {
+	r.fsOnce.Do(func() {
+		nodes := map[string]*fsNode{".": {name: ".", dir: true}}
+
+		// node returns the node for name, adding it and its parents
+		// as needed, or nil if a file is in the way.
+		var node func(name string, dir bool) *fsNode
+		node = func(name string, dir bool) *fsNode {
+			if n, ok := nodes[name]; ok {
+				return n
+			}
+
+			parent := node(path.Dir(name), true)
+			if parent == nil || !parent.dir {
+				return nil
+			}
+
+			n := &fsNode{name: name, dir: dir}
+			nodes[name] = n
+			parent.children = append(parent.children, n)
+			return n
+		}
+
+		for _, e := range r.entries {
+			name := strings.TrimSuffix(strings.TrimPrefix(e.Name, "./"), "/")
+			if name == "" || !fs.ValidPath(name) {
+				continue
+			}
+
+			// The first entry with a name wins, but a directory
+			// implied by an earlier name can still get its own.
+			n := node(name, e.IsDir())
+			if n != nil && n.entry == nil && n.dir == e.IsDir() {
+				n.entry = e
+			}
+		}
+
+		for _, n := range nodes {
+			sort.Slice(n.children, func(i, j int) bool {
+				return n.children[i].name < n.children[j].name
+			})
+		}
+		r.fsNodes = nodes
+	})
+
+	return r.fsNodes
+}
+
+func (r *Reader) lookup(op, name string) (*fsNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	n, ok := r.fsTree()[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return n, nil
+}
+
+// Open opens the named file or directory in the archive, making Reader
+// an fs.FS. Names are slash separated without a leading slash, and
+// directories are listed whether or not they have entries of their own.
+func (r *Reader) Open(name string) (fs.File, error) {
+	n, err := r.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.dir {
+		return &fsDir{node: n}, nil
+	}
+
+	return &fsFile{node: n}, nil
+}
+
+// Stat returns information about the named file or directory.
+func (r *Reader) Stat(name string) (fs.FileInfo, error) {
+	n, err := r.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo{n}, nil
+}
+
+// ReadDir returns the named directory's contents sorted by name.
+func (r *Reader) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := r.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries := make([]fs.DirEntry, len(n.children))
+	for i, c := range n.children {
+		entries[i] = fileInfo{c}
+	}
+
+	return entries, nil
+}
+
+// fileInfo describes a node as both an fs.FileInfo and an fs.DirEntry.
+type fileInfo struct {
+	node *fsNode
+}
+
+func (fi fileInfo) Name() string {
+	return path.Base(fi.node.name)
+}
+
+func (fi fileInfo) Size() int64 {
+	if fi.node.entry == nil || fi.node.dir {
+		return 0
+	}
+
+	return int64(fi.node.entry.UncompressedSize)
+}
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.node.entry == nil {
+		return fs.ModeDir | 0755
+	}
+
+	return fi.node.entry.Mode()
+}
+
+func (fi fileInfo) ModTime() time.Time {
+	if fi.node.entry == nil {
+		return time.Time{}
+	}
+
+	return fi.node.entry.Modified
+}
+
+func (fi fileInfo) IsDir() bool {
+	return fi.node.dir
+}
+
+// Sys returns the node's *Entry, which is nil for directories without
+// entries of their own.
+func (fi fileInfo) Sys() interface{} {
+	return fi.node.entry
+}
+
+func (fi fileInfo) Type() fs.FileMode {
+	return fi.Mode().Type()
+}
+
+func (fi fileInfo) Info() (fs.FileInfo, error) {
+	return fi, nil
+}
+
+// fsFile reads an entry's contents. Entries can only be decompressed
+// from the start so seeking backwards reopens the entry, and seeking
+// forwards discards what's skipped over, when it is next read.
+type fsFile struct {
+	node   *fsNode
+	rc     io.ReadCloser
+	read   int64
+	pos    int64
+	closed bool
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return fileInfo{f.node}, nil
+}
+
+func (f *fsFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: fs.ErrClosed}
+	}
+
+	if f.rc == nil || f.read > f.pos {
+		if f.rc != nil {
+			f.rc.Close()
+		}
+
+		rc, err := f.node.entry.Open()
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: err}
+		}
+		f.rc, f.read = newChecksumReader(f.node.entry, rc), 0
+	}
+
+	if f.read < f.pos {
+		n, err := io.CopyN(ioutil.Discard, f.rc, f.pos-f.read)
+		f.read += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.rc.Read(p)
+	f.read += int64(n)
+	f.pos += int64(n)
+	return n, err
+}
+
+// Seek sets where the next Read starts, which is what http.FileServer
+// needs to serve entries.
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.node.name, Err: fs.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(f.node.entry.UncompressedSize)
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.node.name, Err: fs.ErrInvalid}
+	}
+
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *fsFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.node.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+
+	if f.rc == nil {
+		return nil
+	}
+	return f.rc.Close()
+}
+
+// checksumReader checks an entry's contents against its CRC-32 once
+// they have all been read, failing with a *ChecksumError instead of
+// io.EOF if they don't match.
+type checksumReader struct {
+	io.ReadCloser
+	e   *Entry
+	crc hash.Hash32
+}
+
+func newChecksumReader(e *Entry, rc io.ReadCloser) io.ReadCloser {
+	if !e.hasCRC32() {
+		return rc
+	}
+
+	return &checksumReader{ReadCloser: rc, e: e, crc: crc32.NewIEEE()}
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.crc.Write(p[:n])
+	if err == io.EOF && cr.crc.Sum32() != cr.e.CRC32 {
+		return n, &ChecksumError{Entry: cr.e.Name, Expected: cr.e.CRC32, Actual: cr.crc.Sum32()}
+	}
+
+	return n, err
+}
+
+// fsDir lists a directory's contents.
+type fsDir struct {
+	node   *fsNode
+	offset int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return fileInfo{d.node}, nil
+}
+
+func (d *fsDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: fs.ErrInvalid}
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	children := d.node.children[d.offset:]
+	if n > 0 && len(children) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(children) {
+		children = children[:n]
+	}
+	d.offset += len(children)
+
+	entries := make([]fs.DirEntry, len(children))
+	for i, c := range children {
+		entries[i] = fileInfo{c}
+	}
+
+	return entries, nil
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+// FSOption configures WriteFS.
+type FSOption func(*fsOptions)
+
+type fsOptions struct {
+	method     Compression
+	level      int
+	encryption Encryption
+	password   string
+}
+
+// WithMethod sets the method WriteFS compresses files with. It is
+// deflate by default.
+func WithMethod(method Compression) FSOption {
+	return func(o *fsOptions) {
+		o.method = method
+	}
+}
+
+// WithLevel sets the level WriteFS deflates files at, as
+// Writer.SetLevel does.
+func WithLevel(level int) FSOption {
+	return func(o *fsOptions) {
+		o.level = level
+	}
+}
+
+// WithEncryption encrypts every file WriteFS writes with password.
+func WithEncryption(encryption Encryption, password string) FSOption {
+	return func(o *fsOptions) {
+		o.encryption = encryption
+		o.password = password
+	}
+}
+
+// WriteFS writes an archive of every regular file and directory in fsys
+// to w, named by its path in fsys, with its mode.
+func WriteFS(w io.Writer, fsys fs.FS, opts ...FSOption) error {
+	o := fsOptions{method: DeflateCompression, level: flate.DefaultCompression}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	zw := NewWriter(w)
+	if err := zw.SetLevel(o.level); err != nil {
+		return err
+	}
+	if o.encryption != NoEncryption {
+		zw.SetEncryption(o.encryption, o.password)
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name == "." || !(d.IsDir() || d.Type().IsRegular()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var contents []byte
+		if d.IsDir() {
+			name += "/"
+		} else {
+			contents, err = fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+		}
+
+		e := &Entry{
+			Name:     name,
+			Modified: info.ModTime(),
+			Method:   o.method,
+		}
+		e.SetMode(info.Mode())
+		return zw.WriteEntry(e, contents)
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
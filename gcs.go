@@ -0,0 +1,29 @@
+package gozip
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// NewGCSReaderAt returns an HTTPReaderAt for object in the Google Cloud
+// Storage bucket, so an archive there can be read without downloading
+// it. token, if not nil, returns the OAuth 2.0 access token each request
+// is made with, as gcloud auth print-access-token prints; without it
+// requests are anonymous, which is enough for public objects.
+func NewGCSReaderAt(client *http.Client, bucket, object string, token func() (string, error)) (*HTTPReaderAt, error) {
+	u := "https://storage.googleapis.com/" + url.PathEscape(bucket) + "/" + (&url.URL{Path: object}).EscapedPath()
+
+	var sign func(req *http.Request) error
+	if token != nil {
+		sign = func(req *http.Request) error {
+			t, err := token()
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+t)
+			return nil
+		}
+	}
+
+	return newHTTPReaderAt(client, u, sign)
+}
@@ -0,0 +1,135 @@
+package gozip
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether the slash-separated name matches pattern. It
+// is path.Match with two additions: ** as a whole path element matches
+// any number of elements, including none, and {a,b} matches either of
+// its comma-separated alternatives. A trailing slash on name, as
+// directory entries have, is ignored. The only error is
+// path.ErrBadPattern.
+func Match(pattern, name string) (bool, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	names := strings.Split(strings.TrimSuffix(name, "/"), "/")
+	matched := false
+	for _, p := range patterns {
+		ok, err := matchElements(strings.Split(p, "/"), names)
+		if err != nil {
+			return false, err
+		}
+		matched = matched || ok
+	}
+
+	return matched, nil
+}
+
+func matchElements(patterns, names []string) (bool, error) {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			patterns = patterns[1:]
+			if len(patterns) == 0 {
+				return true, nil
+			}
+
+			for i := range names {
+				if ok, err := matchElements(patterns, names[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+
+			// Check the rest of the pattern is well formed even though
+			// there is nothing left for it to match.
+			return matchElements(patterns, nil)
+		}
+
+		if len(names) == 0 {
+			for _, p := range patterns {
+				if _, err := path.Match(p, ""); err != nil {
+					return false, err
+				}
+			}
+			return false, nil
+		}
+
+		ok, err := path.Match(patterns[0], names[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+
+	return len(names) == 0, nil
+}
+
+// expandBraces returns the patterns {a,b} alternatives in pattern make.
+func expandBraces(pattern string) ([]string, error) {
+	start := -1
+	depth := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return nil, path.ErrBadPattern
+			}
+			if depth > 0 {
+				continue
+			}
+
+			var expanded []string
+			for _, alt := range splitAlternatives(pattern[start+1 : i]) {
+				more, err := expandBraces(pattern[:start] + alt + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, more...)
+			}
+			return expanded, nil
+		}
+	}
+
+	if depth != 0 {
+		return nil, path.ErrBadPattern
+	}
+
+	return []string{pattern}, nil
+}
+
+// splitAlternatives splits the inside of braces on the commas that
+// aren't inside nested braces.
+func splitAlternatives(s string) []string {
+	var alts []string
+	depth := 0
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+
+	return append(alts, s[last:])
+}
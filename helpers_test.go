@@ -0,0 +1,67 @@
+package gozip
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testModified is when the entries tests write were modified.
+var testModified = time.Date(2021, 11, 23, 22, 7, 0, 0, time.Local)
+
+// writeArchive returns the archive fn writes with a Writer.
+func writeArchive(t testing.TB, fn func(w *Writer)) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	fn(w)
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing writer: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+// writeEntry writes an entry of contents named name with method.
+func writeEntry(t testing.TB, w *Writer, name string, method Compression, contents []byte) {
+	t.Helper()
+
+	e := &Entry{Name: name, Modified: testModified, Method: method}
+	if err := w.WriteEntry(e, contents); err != nil {
+		t.Fatalf("writing %s: %s", name, err)
+	}
+}
+
+// readArchive parses bs, failing the test if it can't be.
+func readArchive(t testing.TB, bs []byte, opts ...Option) *Reader {
+	t.Helper()
+
+	r, err := NewReader(bytes.NewReader(bs), int64(len(bs)), opts...)
+	if err != nil {
+		t.Fatalf("reading archive: %s", err)
+	}
+
+	return r
+}
+
+// lookupEntry returns the entry named name in r.
+func lookupEntry(t testing.TB, r *Reader, name string) *Entry {
+	t.Helper()
+
+	e, ok := r.Lookup(name)
+	if !ok {
+		t.Fatalf("no entry named %q", name)
+	}
+
+	return e
+}
+
+// testRandom returns n bytes that don't compress, so flate reads its
+// input in more than one go.
+func testRandom(n int) []byte {
+	bs := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(bs)
+	return bs
+}
@@ -0,0 +1,141 @@
+package gozip
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var ErrRangeNotSupported = fmt.Errorf("Server does not support range requests")
+
+// HTTPError is a response to a range request that was neither the
+// range nor a redirect.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return "HTTP " + e.Status + " for " + e.URL
+}
+
+// HTTPReaderAt reads a file from an HTTP server with range requests, so
+// an archive can be listed and single entries read out of it without
+// downloading the rest. Reads are buffered as by NewBufferedReaderAt.
+type HTTPReaderAt struct {
+	*blockCache
+	client *http.Client
+	url    string
+	// sign, if set, authenticates each request.
+	sign func(req *http.Request) error
+}
+
+// NewHTTPReaderAt returns an HTTPReaderAt for the file at url, fetched
+// with client. The first request asks for the end of the file, where
+// the end of central directory record is, and the size of the whole
+// file with it. Servers that ignore ranges fail with
+// ErrRangeNotSupported, unless the file is small enough that the whole
+// of it is what was asked for anyway.
+func NewHTTPReaderAt(client *http.Client, url string) (*HTTPReaderAt, error) {
+	return newHTTPReaderAt(client, url, nil)
+}
+
+func newHTTPReaderAt(client *http.Client, url string, sign func(req *http.Request) error) (*HTTPReaderAt, error) {
+	h := &HTTPReaderAt{client: client, url: url, sign: sign}
+	h.blockCache = &blockCache{fetch: h.fetch}
+
+	tail := endOfCentralDirectoryLength + maxCommentLength
+	resp, err := h.get(fmt.Sprintf("bytes=-%d", tail))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var start, end int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &h.size); err != nil {
+			return nil, ErrRangeNotSupported
+		}
+		// Don't trust the range to be what was asked for, or to make
+		// sense at all, before allocating for it.
+		if start < 0 || start > end || end >= h.size || end-start+1 > int64(tail) {
+			return nil, ErrRangeNotSupported
+		}
+		h.blockOffset = start
+		h.block, err = readBody(resp, end-start+1)
+	case http.StatusOK:
+		if resp.ContentLength < 0 || resp.ContentLength > int64(tail) {
+			return nil, ErrRangeNotSupported
+		}
+		h.size = resp.ContentLength
+		h.block, err = readBody(resp, h.size)
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, ErrEmptyFile
+	default:
+		return nil, &HTTPError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *HTTPReaderAt) get(byteRange string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", byteRange)
+	if h.sign != nil {
+		if err := h.sign(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.client.Do(req)
+}
+
+func readBody(resp *http.Response, n int64) ([]byte, error) {
+	bs := make([]byte, n)
+	if _, err := io.ReadFull(resp.Body, bs); err != nil {
+		return nil, err
+	}
+
+	return bs, nil
+}
+
+// fetch asks the server for the n bytes at off.
+func (h *HTTPReaderAt) fetch(off, n int64) ([]byte, error) {
+	resp, err := h.get(fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil, ErrRangeNotSupported
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, &HTTPError{URL: h.url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	var start int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != off {
+		return nil, ErrRangeNotSupported
+	}
+
+	return readBody(resp, n)
+}
+
+// OpenURL opens and parses the archive at url, fetching only the parts
+// of it that are needed with range requests, using http.DefaultClient.
+func OpenURL(url string, opts ...Option) (*Reader, error) {
+	h, err := NewHTTPReaderAt(http.DefaultClient, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReader(h, h.Size(), opts...)
+}
@@ -0,0 +1,66 @@
+package gozip
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPReaderAtContentRange(t *testing.T) {
+	contents := []byte("over HTTP\n")
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, contents)
+	})
+
+	tests := []struct {
+		name string
+		// contentRange is what the server claims to send, or empty to
+		// send what was asked for.
+		contentRange string
+		err          error
+	}{
+		{"honest", "", nil},
+		{"inverted", "bytes 100-10/1000", ErrRangeNotSupported},
+		{"oversized", "bytes 0-999999999999/1000000000000", ErrRangeNotSupported},
+		{"past the end", "bytes 900-1000/1000", ErrRangeNotSupported},
+		{"negative", "bytes -10-10/1000", ErrRangeNotSupported},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if test.contentRange == "" {
+					http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(bs))
+					return
+				}
+				w.Header().Set("Content-Range", test.contentRange)
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(bs)
+			}))
+			defer srv.Close()
+
+			h, err := NewHTTPReaderAt(srv.Client(), srv.URL)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+
+			r, err := NewReader(h, h.Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := lookupEntry(t, r, "a.txt").ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, contents) {
+				t.Errorf("got %q, want %q", got, contents)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+//go:build go1.23
+
+package gozip
+
+import (
+	"bufio"
+	"io"
+	"iter"
+	"time"
+)
+
+// All returns an iterator over the reader's entries, in the order
+// Entries has them. The error is always nil; it is there so All ranges
+// the same as Scan.
+func (r *Reader) All() iter.Seq2[*Entry, error] {
+	return func(yield func(*Entry, error) bool) {
+		for _, e := range r.listedEntries() {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Since returns an iterator over the entries modified after t, in the
+// order Entries has them. Modified comes from the NTFS or extended
+// timestamp extra field if the entry has one, to the 100ns or second,
+// and otherwise from the MS-DOS time, which is to 2 seconds in the
+// archiver's time zone, taken to be the local one. An entry with only
+// an MS-DOS time modified in the second after t, or written in another
+// time zone, can be left out or let in wrongly.
+func (r *Reader) Since(t time.Time) iter.Seq[*Entry] {
+	return func(yield func(*Entry) bool) {
+		for _, e := range r.listedEntries() {
+			if e.Modified.After(t) && !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// Scan returns an iterator over the entries of the archive of size
+// bytes in r that reads its central directory a record at a time as it
+// is ranged over, rather than all of it up front as NewReader does, so
+// archives of millions of entries can be gone through, or stopped in,
+// without holding them all. Any error ends it. Since no entry sees the
+// others, overlapping and duplicate entries aren't checked for, and
+// only the limits on entries' sizes and their count and total so far
+// are. WithDuplicates, WithParseOptions, WithSalvage and WithProgress
+// are ignored, and archives without a central directory can't be
+// scanned.
+func Scan(r io.ReaderAt, size int64, opts ...Option) iter.Seq2[*Entry, error] {
+	return func(yield func(*Entry, error) bool) {
+		reader := &Reader{}
+		for _, opt := range opts {
+			opt(reader)
+		}
+
+		cd, err := findCentralDirectory(r, size)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		start := cd.start()
+		br := bufio.NewReaderSize(io.NewSectionReader(r, start, int64(cd.eocd.centralDirectorySize)), 64<<10)
+		offset := start
+		var t tally
+		for i := uint64(0); i < cd.eocd.entries; i++ {
+			cdr, n, err := readCentralDirectoryRecord(br)
+			if err != nil {
+				yield(nil, formatError(offset, "central directory record", err))
+				return
+			}
+			offset += int64(n)
+
+			e := reader.centralEntry(r, cd, cdr)
+			if err := reader.limits.checkEntry(&t, e); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// readCentralDirectoryRecord reads the next central directory record
+// from br and returns it and its length.
+func readCentralDirectoryRecord(br *bufio.Reader) (*centralDirectoryRecord, int, error) {
+	bs := make([]byte, centralDirectoryRecordLength)
+	if _, err := io.ReadFull(br, bs); err != nil {
+		return nil, 0, overran(err)
+	}
+
+	fileNameLength, _, _ := readUint16(bs, 28)
+	extraFieldLength, _, _ := readUint16(bs, 30)
+	commentLength, _, _ := readUint16(bs, 32)
+	rest := int(fileNameLength) + int(extraFieldLength) + int(commentLength)
+	bs = append(bs, make([]byte, rest)...)
+	if _, err := io.ReadFull(br, bs[centralDirectoryRecordLength:]); err != nil {
+		return nil, 0, overran(err)
+	}
+
+	cdr, _, err := parseCentralDirectoryRecord(bs, 0)
+	return cdr, len(bs), err
+}
+
+// overran returns ErrOverranBuffer for running out of central
+// directory, and other errors as they are.
+func overran(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrOverranBuffer
+	}
+
+	return err
+}
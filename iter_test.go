@@ -0,0 +1,54 @@
+//go:build go1.23
+
+package gozip
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSince(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		for _, e := range []*Entry{
+			{Name: "coarse.txt", Modified: testModified.Add(time.Second)},
+			{Name: "old.txt", Modified: testModified.Add(-time.Hour)},
+			{Name: "precise.txt", Modified: testModified.Add(time.Second)},
+			{Name: "new.txt", Modified: testModified.Add(time.Hour)},
+		} {
+			if err := w.WriteEntry(e, []byte(e.Name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	// Rename coarse.txt's extended timestamp in the central directory,
+	// leaving it only its MS-DOS time, which rounds down to an even
+	// second.
+	cd := bytes.Index(bs, []byte("PK\x01\x02"))
+	ut := bytes.Index(bs[cd:], []byte("UT\x05\x00"))
+	copy(bs[cd+ut:], "XX")
+
+	r := readArchive(t, bs)
+	if got := lookupEntry(t, r, "coarse.txt").Modified; !got.Equal(testModified) {
+		t.Fatalf("coarse.txt modified at %s, want %s", got, testModified)
+	}
+
+	var got []string
+	for e := range r.Since(testModified) {
+		got = append(got, e.Name)
+	}
+	want := []string{"precise.txt", "new.txt"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	for range r.Since(testModified.Add(time.Hour)) {
+		t.Error("got an entry modified after the newest")
+	}
+}
@@ -0,0 +1,577 @@
+package gozip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Shrink, Reduce and Implode are the methods PKZIP 1.x compressed with
+// before deflate replaced them. Nothing writes them any more but old
+// archives are full of them.
+
+var (
+	ErrInvalidShrink  = fmt.Errorf("Invalid shrunk data")
+	ErrInvalidReduce  = fmt.Errorf("Invalid reduced data")
+	ErrInvalidImplode = fmt.Errorf("Invalid imploded data")
+)
+
+const (
+	shrinkMaxBits   = 13
+	shrinkCodes     = 1 << shrinkMaxBits
+	shrinkControl   = 256
+	shrinkFree      = -1
+	reduceWindow    = 1 << 12
+	implodeWindow   = 1 << 13
+	implodeFlag8K   = 0x2
+	implodeFlag3SF  = 0x4
+	implodeLongCode = 63
+)
+
+// legacyDecompressor returns the decompressor for Reduce and Implode,
+// which, unlike every other method, depend on more of the entry than its
+// data: Reduce streams have no end so stop after the uncompressed size,
+// and Implode's parameters are in the flags.
+func (e *Entry) legacyDecompressor(method Compression) (Decompressor, bool) {
+	size := int64(e.UncompressedSize)
+	switch {
+	case method >= Reduce1Compression && method <= Reduce4Compression:
+		factor := uint(method-Reduce1Compression) + 1
+		return func(r io.Reader) io.ReadCloser {
+			return newUnreduceReader(r, factor, size)
+		}, true
+	case method == ImplodeCompression:
+		flags := e.Flags
+		return func(r io.Reader) io.ReadCloser {
+			return newExplodeReader(r, flags, size)
+		}, true
+	}
+
+	return nil, false
+}
+
+func isLegacyMethod(method Compression) bool {
+	return method >= Reduce1Compression && method <= ImplodeCompression
+}
+
+// unshrinkReader decodes Shrink, which is LZW with codes growing from 9
+// to 13 bits when the compressor says so and a partial clear that frees
+// only the codes no other code extends.
+type unshrinkReader struct {
+	br       bitReader
+	err      error
+	codeSize uint
+
+	// parent is the code each code extends by value, or shrinkFree.
+	// Literals have shrinkControl as their parent.
+	parent [shrinkCodes]int16
+	value  [shrinkCodes]byte
+
+	started bool
+	old     int
+	first   byte
+	// lastFree is where the search for the next free code starts from.
+	lastFree int
+
+	buf []byte
+	out int
+}
+
+func newUnshrinkReader(r io.Reader) io.ReadCloser {
+	u := &unshrinkReader{
+		br:       bitReader{r: bufio.NewReader(r)},
+		codeSize: 9,
+		lastFree: shrinkControl,
+	}
+	for c := range u.parent {
+		switch {
+		case c < shrinkControl:
+			u.parent[c] = shrinkControl
+			u.value[c] = byte(c)
+		case c > shrinkControl:
+			u.parent[c] = shrinkFree
+		}
+	}
+
+	return u
+}
+
+func (u *unshrinkReader) Read(p []byte) (int, error) {
+	for u.out == len(u.buf) {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.buf, u.out = u.buf[:0], 0
+		u.err = u.step()
+	}
+
+	n := copy(p, u.buf[u.out:])
+	u.out += n
+	return n, nil
+}
+
+func (u *unshrinkReader) Close() error {
+	return nil
+}
+
+// readCode reads the next code. The stream just stops, so running out
+// part way through a code is its end.
+func (u *unshrinkReader) readCode() (int, error) {
+	code, err := u.br.bit(u.codeSize)
+	if err == io.ErrUnexpectedEOF {
+		return 0, io.EOF
+	}
+
+	return int(code), err
+}
+
+func (u *unshrinkReader) step() error {
+	code, err := u.readCode()
+	if err != nil {
+		return err
+	}
+
+	if !u.started {
+		if code >= shrinkControl {
+			return ErrInvalidShrink
+		}
+		u.started = true
+		u.old, u.first = code, byte(code)
+		u.buf = append(u.buf, byte(code))
+		return nil
+	}
+
+	if code == shrinkControl {
+		code, err = u.readCode()
+		if err != nil {
+			return err
+		}
+
+		switch code {
+		case 1:
+			if u.codeSize == shrinkMaxBits {
+				return ErrInvalidShrink
+			}
+			u.codeSize++
+		case 2:
+			u.partialClear()
+		}
+		return nil
+	}
+
+	// A code that isn't defined yet is the one about to be: the
+	// previous string plus its own first byte.
+	current := code
+	if u.parent[code] == shrinkFree {
+		u.buf = append(u.buf, u.first)
+		code = u.old
+	}
+	for code != shrinkControl {
+		if u.parent[code] == shrinkFree || len(u.buf) > shrinkCodes {
+			return ErrInvalidShrink
+		}
+		u.buf = append(u.buf, u.value[code])
+		code = int(u.parent[code])
+	}
+
+	// The string was built backwards.
+	for i, j := 0, len(u.buf)-1; i < j; i, j = i+1, j-1 {
+		u.buf[i], u.buf[j] = u.buf[j], u.buf[i]
+	}
+	u.first = u.buf[0]
+
+	free := u.lastFree + 1
+	for free < shrinkCodes && u.parent[free] != shrinkFree {
+		free++
+	}
+	if free == shrinkCodes {
+		return ErrInvalidShrink
+	}
+	u.lastFree = free
+	u.parent[free] = int16(u.old)
+	u.value[free] = u.first
+	u.old = current
+
+	return nil
+}
+
+// partialClear frees every code that isn't the parent of another.
+func (u *unshrinkReader) partialClear() {
+	var parents [shrinkCodes]bool
+	for c := shrinkControl + 1; c < shrinkCodes; c++ {
+		if p := u.parent[c]; p > shrinkControl {
+			parents[p] = true
+		}
+	}
+
+	for c := shrinkControl + 1; c < shrinkCodes; c++ {
+		if !parents[c] {
+			u.parent[c] = shrinkFree
+		}
+	}
+	u.lastFree = shrinkControl
+}
+
+// unreduceReader decodes Reduce, which is a run and match encoding of
+// the data compressed again by predicting each byte from the one
+// before it. The compression factor, 1 to 4, is how many bits of a
+// match go to its distance rather than its length.
+type unreduceReader struct {
+	br     bitReader
+	err    error
+	factor uint
+
+	// followers holds, for each byte, the bytes most likely to follow
+	// it. last is the byte before the next.
+	followers [256][]byte
+	started   bool
+	last      byte
+
+	// state is where expanding is up to: 0 between bytes, 1 after the
+	// escape, 2 after a match's first byte, 3 after its length.
+	state  int
+	match  byte
+	length int
+
+	// hist starts with a window of zeros since matches can reach back
+	// before the start of the data.
+	hist []byte
+	out  int
+	left int64
+}
+
+func newUnreduceReader(r io.Reader, factor uint, size int64) io.ReadCloser {
+	return &unreduceReader{
+		br:     bitReader{r: bufio.NewReader(r)},
+		factor: factor,
+		hist:   make([]byte, reduceWindow),
+		out:    reduceWindow,
+		left:   size,
+	}
+}
+
+func (u *unreduceReader) Read(p []byte) (int, error) {
+	if u.left == 0 {
+		return 0, io.EOF
+	}
+
+	for u.out == len(u.hist) {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.err = u.step()
+	}
+
+	n := copy(p, u.hist[u.out:])
+	if int64(n) > u.left {
+		n = int(u.left)
+	}
+	u.out += n
+	u.left -= int64(n)
+	return n, nil
+}
+
+func (u *unreduceReader) Close() error {
+	return nil
+}
+
+func (u *unreduceReader) readFollowers() error {
+	for c := 255; c >= 0; c-- {
+		n, err := u.br.bit(6)
+		if err != nil {
+			return err
+		}
+		if n > 32 {
+			return ErrInvalidReduce
+		}
+
+		u.followers[c] = make([]byte, n)
+		for i := range u.followers[c] {
+			b, err := u.br.bit(8)
+			if err != nil {
+				return err
+			}
+			u.followers[c][i] = byte(b)
+		}
+	}
+
+	return nil
+}
+
+// next undoes the prediction for one byte.
+func (u *unreduceReader) next() (byte, error) {
+	followers := u.followers[u.last]
+	if len(followers) > 0 {
+		literal, err := u.br.bit(1)
+		if err != nil {
+			return 0, err
+		}
+
+		if literal == 0 {
+			width := uint(1)
+			for 1<<width < len(followers) {
+				width++
+			}
+			i, err := u.br.bit(width)
+			if err != nil {
+				return 0, err
+			}
+			if int(i) >= len(followers) {
+				return 0, ErrInvalidReduce
+			}
+			u.last = followers[i]
+			return u.last, nil
+		}
+	}
+
+	b, err := u.br.bit(8)
+	if err != nil {
+		return 0, err
+	}
+	u.last = byte(b)
+	return u.last, nil
+}
+
+// step expands bytes until there is some output.
+func (u *unreduceReader) step() error {
+	if !u.started {
+		if err := u.readFollowers(); err != nil {
+			return err
+		}
+		u.started = true
+	}
+
+	if len(u.hist) > 2*reduceWindow {
+		drop := len(u.hist) - reduceWindow
+		u.hist = append(u.hist[:0], u.hist[drop:]...)
+		u.out -= drop
+	}
+
+	const escape = 144
+	lengthMask := byte(0x7F) >> (u.factor - 1)
+	for start := len(u.hist); len(u.hist) == start; {
+		c, err := u.next()
+		if err != nil {
+			return err
+		}
+
+		switch u.state {
+		case 0:
+			if c == escape {
+				u.state = 1
+			} else {
+				u.hist = append(u.hist, c)
+			}
+		case 1:
+			if c == 0 {
+				u.hist = append(u.hist, escape)
+				u.state = 0
+				break
+			}
+			u.match = c
+			u.length = int(c & lengthMask)
+			if c&lengthMask == lengthMask {
+				u.state = 2
+			} else {
+				u.state = 3
+			}
+		case 2:
+			u.length += int(c)
+			u.state = 3
+		case 3:
+			distance := int(u.match>>(8-u.factor))<<8 + int(c) + 1
+			from := len(u.hist) - distance
+			for i := 0; i < u.length+3; i++ {
+				u.hist = append(u.hist, u.hist[from+i])
+			}
+			u.state = 0
+		}
+	}
+
+	return nil
+}
+
+// explodeReader decodes Implode: literals and matches against a 4KiB or
+// 8KiB window, coded with Shannon-Fano trees sent at the start of the
+// data.
+type explodeReader struct {
+	br  bitReader
+	err error
+
+	// literal is nil when literals are sent as plain bytes.
+	literal  *canonicalHuffman
+	length   *canonicalHuffman
+	distance *canonicalHuffman
+	started  bool
+	large    bool
+	minMatch int
+
+	// hist starts with a window of zeros since matches can reach back
+	// before the start of the data.
+	hist []byte
+	out  int
+	left int64
+}
+
+func newExplodeReader(r io.Reader, flags uint16, size int64) io.ReadCloser {
+	e := &explodeReader{
+		br:    bitReader{r: bufio.NewReader(r), invalid: ErrInvalidImplode},
+		large: flags&implodeFlag8K != 0,
+		hist:  make([]byte, implodeWindow),
+		out:   implodeWindow,
+		left:  size,
+	}
+	if flags&implodeFlag3SF != 0 {
+		e.literal = &canonicalHuffman{}
+	}
+
+	return e
+}
+
+func (e *explodeReader) Read(p []byte) (int, error) {
+	if e.left == 0 {
+		return 0, io.EOF
+	}
+
+	for e.out == len(e.hist) {
+		if e.err != nil {
+			return 0, e.err
+		}
+		e.err = e.step()
+	}
+
+	n := copy(p, e.hist[e.out:])
+	if int64(n) > e.left {
+		n = int(e.left)
+	}
+	e.out += n
+	e.left -= int64(n)
+	return n, nil
+}
+
+func (e *explodeReader) Close() error {
+	return nil
+}
+
+// readTree reads the bit lengths of a tree's n symbols. They're sent as
+// a count of bytes, each of which is a count of symbols in a row and
+// their bit length, both less one.
+func (e *explodeReader) readTree(n int) (*canonicalHuffman, error) {
+	count, err := e.br.bit(8)
+	if err != nil {
+		return nil, err
+	}
+
+	lengths := make([]uint8, 0, n)
+	for i := 0; i <= int(count); i++ {
+		b, err := e.br.bit(8)
+		if err != nil {
+			return nil, err
+		}
+
+		repeat := int(b>>4) + 1
+		if len(lengths)+repeat > n {
+			return nil, ErrInvalidImplode
+		}
+		for j := 0; j < repeat; j++ {
+			lengths = append(lengths, uint8(b&0xF)+1)
+		}
+	}
+	if len(lengths) != n {
+		return nil, ErrInvalidImplode
+	}
+
+	h, err := newCanonicalHuffman(lengths)
+	if err != nil {
+		return nil, ErrInvalidImplode
+	}
+	h.inverted = true
+	return h, nil
+}
+
+func (e *explodeReader) readTrees() error {
+	var err error
+	e.minMatch = 2
+	if e.literal != nil {
+		if e.literal, err = e.readTree(256); err != nil {
+			return err
+		}
+		e.minMatch = 3
+	}
+	if e.length, err = e.readTree(64); err != nil {
+		return err
+	}
+	e.distance, err = e.readTree(64)
+	return err
+}
+
+// step decodes a literal or a match.
+func (e *explodeReader) step() error {
+	if !e.started {
+		if err := e.readTrees(); err != nil {
+			return err
+		}
+		e.started = true
+	}
+
+	if len(e.hist) > 2*implodeWindow {
+		drop := len(e.hist) - implodeWindow
+		e.hist = append(e.hist[:0], e.hist[drop:]...)
+		e.out -= drop
+	}
+
+	literal, err := e.br.bit(1)
+	if err != nil {
+		return err
+	}
+
+	if literal == 1 {
+		var c int
+		if e.literal != nil {
+			c, err = e.br.decode(e.literal)
+		} else {
+			var b uint32
+			b, err = e.br.bit(8)
+			c = int(b)
+		}
+		if err != nil {
+			return err
+		}
+		e.hist = append(e.hist, byte(c))
+		return nil
+	}
+
+	low := uint(6)
+	if e.large {
+		low = 7
+	}
+	distance, err := e.br.bit(low)
+	if err != nil {
+		return err
+	}
+	high, err := e.br.decode(e.distance)
+	if err != nil {
+		return err
+	}
+	distance |= uint32(high) << low
+
+	length, err := e.br.decode(e.length)
+	if err != nil {
+		return err
+	}
+	if length == implodeLongCode {
+		extra, err := e.br.bit(8)
+		if err != nil {
+			return err
+		}
+		length += int(extra)
+	}
+	length += e.minMatch
+
+	from := len(e.hist) - int(distance) - 1
+	for i := 0; i < length; i++ {
+		e.hist = append(e.hist, e.hist[from+i])
+	}
+
+	return nil
+}
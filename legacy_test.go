@@ -0,0 +1,45 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestImplodeTrees(t *testing.T) {
+	// A tree of 64 bit lengths: 63 six-bit codes and one 16 bits long,
+	// as long as implode's codes get, which leaves the code incomplete
+	// but valid. Each byte is a count less one and a length less one.
+	long := []byte{4, 0xF5, 0xF5, 0xF5, 0xE5, 0x0F}
+	// 64 one-bit codes can't all be told apart.
+	oversubscribed := []byte{3, 0xF0, 0xF0, 0xF0, 0xF0}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		// The trees are read, and the data after them is missing.
+		{"16-bit code", append(append([]byte(nil), long...), long...), io.ErrUnexpectedEOF},
+		{"oversubscribed", append(append([]byte(nil), oversubscribed...), long...), ErrInvalidImplode},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Store the trees, then mark them imploded.
+			bs := writeArchive(t, func(w *Writer) {
+				writeEntry(t, w, "a.txt", NoCompression, test.data)
+			})
+			cd := bytes.Index(bs, []byte("PK\x01\x02"))
+			binary.LittleEndian.PutUint16(bs[8:], uint16(ImplodeCompression))
+			binary.LittleEndian.PutUint16(bs[cd+10:], uint16(ImplodeCompression))
+
+			e := lookupEntry(t, readArchive(t, bs), "a.txt")
+			if _, err := e.ReadAll(); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
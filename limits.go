@@ -0,0 +1,169 @@
+package gozip
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+var ErrLimitExceeded = fmt.Errorf("Archive exceeds limits")
+
+// ratioMinSize is how big an entry has to be before its compression
+// ratio is checked. Small runs of the same byte legitimately compress
+// far better than any sensible limit.
+const ratioMinSize = 1 << 20
+
+// Limits bound how much an archive can make a Reader decompress, to
+// defend against zip bombs. Zero fields aren't limited.
+type Limits struct {
+	// MaxEntries is the most entries the archive may have.
+	MaxEntries int
+	// MaxEntrySize is the most any one entry may decompress to.
+	MaxEntrySize uint64
+	// MaxTotalSize is the most everything read from the Reader's
+	// entries may add up to, counting an entry each time it's read.
+	MaxTotalSize uint64
+	// MaxRatio is the most an entry of a megabyte or more may
+	// decompress to per compressed byte. Deflate gets to about 1,000 on
+	// long runs of the same byte, and zstd to over 10,000.
+	MaxRatio uint64
+}
+
+// WithLimits checks the number of entries and the sizes they claim
+// against limits when the archive is opened, and the sizes they
+// actually decompress to as they are read, failing with a *LimitError.
+// Only what is read is held to MaxRatio, since archives can't be judged
+// bombs by the sizes they claim alone.
+func WithLimits(limits Limits) Option {
+	return func(r *Reader) {
+		r.limits = &limiter{Limits: limits}
+	}
+}
+
+// limiter enforces a Reader's limits. A nil limiter enforces nothing.
+type limiter struct {
+	Limits
+	total uint64
+}
+
+// checkSize checks that the entry name decompressing to size bytes is
+// within MaxEntrySize.
+func (l *limiter) checkSize(name string, size uint64) error {
+	if l.MaxEntrySize != 0 && size > l.MaxEntrySize {
+		return &LimitError{Limit: "MaxEntrySize", Entry: name, Value: size, Max: l.MaxEntrySize}
+	}
+
+	return nil
+}
+
+// checkRatio checks that the entry name decompressing to size bytes
+// from compressed bytes is within MaxRatio.
+func (l *limiter) checkRatio(name string, size, compressed uint64) error {
+	if l.MaxRatio == 0 || size <= ratioMinSize || size/l.MaxRatio <= compressed {
+		return nil
+	}
+
+	ratio := size
+	if compressed > 0 {
+		ratio = size / compressed
+	}
+	return &LimitError{Limit: "MaxRatio", Entry: name, Value: ratio, Max: l.MaxRatio}
+}
+
+// checkTotal checks that total bytes decompressed in all is within
+// MaxTotalSize.
+func (l *limiter) checkTotal(name string, total uint64) error {
+	if l.MaxTotalSize != 0 && total > l.MaxTotalSize {
+		return &LimitError{Limit: "MaxTotalSize", Entry: name, Value: total, Max: l.MaxTotalSize}
+	}
+
+	return nil
+}
+
+// checkEntries rejects an archive whose entries claim more than the
+// limits allow before anything is decompressed.
+func (l *limiter) checkEntries(entries []*Entry) error {
+	if l == nil {
+		return nil
+	}
+
+	if l.MaxEntries != 0 && len(entries) > l.MaxEntries {
+		return &LimitError{Limit: "MaxEntries", Value: uint64(len(entries)), Max: uint64(l.MaxEntries)}
+	}
+
+	var t tally
+	for _, e := range entries {
+		if err := l.checkEntry(&t, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tally is how many entries checkEntry has checked and their total
+// size.
+type tally struct {
+	entries int
+	total   uint64
+}
+
+// checkEntry checks e, and the entries t has counted so far with it,
+// against the limits, for entries read one at a time.
+func (l *limiter) checkEntry(t *tally, e *Entry) error {
+	if l == nil {
+		return nil
+	}
+
+	t.entries++
+	if l.MaxEntries != 0 && t.entries > l.MaxEntries {
+		return &LimitError{Limit: "MaxEntries", Value: uint64(t.entries), Max: uint64(l.MaxEntries)}
+	}
+
+	if err := l.checkSize(e.Name, e.UncompressedSize); err != nil {
+		return err
+	}
+
+	total := t.total + e.UncompressedSize
+	if total < t.total {
+		total = ^uint64(0)
+	}
+	t.total = total
+	return l.checkTotal(e.Name, t.total)
+}
+
+// reader wraps an entry's decompressed contents so reading more than
+// the limits allow fails, whatever sizes the archive claimed.
+func (l *limiter) reader(e *Entry, rc io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return rc
+	}
+
+	return &limitedReader{ReadCloser: rc, limiter: l, name: e.Name, compressed: e.CompressedSize}
+}
+
+type limitedReader struct {
+	io.ReadCloser
+	limiter    *limiter
+	name       string
+	compressed uint64
+	n          uint64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.ReadCloser.Read(p)
+	lr.n += uint64(n)
+	total := atomic.AddUint64(&lr.limiter.total, uint64(n))
+
+	if lerr := lr.limiter.checkSize(lr.name, lr.n); lerr != nil {
+		return n, lerr
+	}
+	if lerr := lr.limiter.checkRatio(lr.name, lr.n, lr.compressed); lerr != nil {
+		return n, lerr
+	}
+	if lerr := lr.limiter.checkTotal(lr.name, total); lerr != nil {
+		return n, lerr
+	}
+
+	return n, err
+}
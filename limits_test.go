@@ -0,0 +1,75 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	zeros := make([]byte, 4<<20)
+
+	tests := []struct {
+		name    string
+		limits  Limits
+		entries int
+		// understate has the central directory claim the entries are
+		// smaller than they are.
+		understate bool
+		// openLimit is the limit opening fails on, and readLimit the
+		// one reading the first entry does, if any.
+		openLimit, readLimit string
+	}{
+		{name: "within", limits: Limits{MaxEntries: 2, MaxEntrySize: 8 << 20, MaxTotalSize: 8 << 20}, entries: 2},
+		{name: "entries", limits: Limits{MaxEntries: 1}, entries: 2, openLimit: "MaxEntries"},
+		{name: "entry size", limits: Limits{MaxEntrySize: 1 << 20}, entries: 1, openLimit: "MaxEntrySize"},
+		{name: "total size", limits: Limits{MaxTotalSize: 6 << 20}, entries: 2, openLimit: "MaxTotalSize"},
+		{name: "ratio only when read", limits: Limits{MaxRatio: 10}, entries: 1, readLimit: "MaxRatio"},
+		{name: "understated entry size", limits: Limits{MaxEntrySize: 1 << 20}, entries: 1, understate: true, readLimit: "MaxEntrySize"},
+		{name: "understated total size", limits: Limits{MaxTotalSize: 1 << 20}, entries: 1, understate: true, readLimit: "MaxTotalSize"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				for i := 0; i < test.entries; i++ {
+					writeEntry(t, w, string(rune('a'+i)), DeflateCompression, zeros)
+				}
+			})
+			if test.understate {
+				cd := bytes.Index(bs, []byte("PK\x01\x02"))
+				binary.LittleEndian.PutUint32(bs[cd+24:], 1000)
+			}
+
+			r, err := NewReader(bytes.NewReader(bs), int64(len(bs)), WithLimits(test.limits))
+			if !isLimitError(t, err, test.openLimit) || err != nil {
+				return
+			}
+
+			_, err = r.Entries()[0].ReadAll()
+			isLimitError(t, err, test.readLimit)
+		})
+	}
+}
+
+// isLimitError checks that err is a *LimitError for limit, or nil if
+// limit is empty, and reports whether it is.
+func isLimitError(t *testing.T, err error, limit string) bool {
+	t.Helper()
+
+	if limit == "" {
+		if err != nil {
+			t.Errorf("got %v, want no error", err)
+			return false
+		}
+		return true
+	}
+
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != limit || !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got %v, want a %s LimitError", err, limit)
+		return false
+	}
+	return true
+}
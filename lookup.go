@@ -0,0 +1,31 @@
+package gozip
+
+import "io"
+
+// Lookup returns the entry named name, as Entries has it, or the first
+// if there are several. Names are indexed the first time it is called,
+// so looking one up costs the same however many entries there are.
+func (r *Reader) Lookup(name string) (*Entry, bool) {
+	r.byNameOnce.Do(func() {
+		r.byName = make(map[string]*Entry, len(r.entries))
+		for _, e := range r.entries {
+			if _, ok := r.byName[e.Name]; !ok {
+				r.byName[e.Name] = e
+			}
+		}
+	})
+
+	e, ok := r.byName[name]
+	return e, ok
+}
+
+// OpenName opens the entry named name as Entry.Open does, failing with
+// ErrEntryNotFound if there is none.
+func (r *Reader) OpenName(name string) (io.ReadCloser, error) {
+	e, ok := r.Lookup(name)
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+
+	return e.Open()
+}
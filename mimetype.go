@@ -0,0 +1,49 @@
+package gozip
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+var ErrMimetypeNotFirst = fmt.Errorf("Mimetype entry must be the first")
+
+// WriteMimetype adds the entry EPUB and OpenDocument files have to
+// start with, named mimetype and holding their media type, such as
+// application/epub+zip. It is written first, stored, unencrypted and
+// without an extra field, so the type can be read at a fixed offset
+// into the file.
+func (w *Writer) WriteMimetype(mimetype string) error {
+	if len(w.records) > 0 || w.current != nil {
+		return ErrMimetypeNotFirst
+	}
+
+	cdr, err := w.newRecord(&Entry{Name: "mimetype", Method: NoCompression})
+	if err != nil {
+		return err
+	}
+	cdr.extraField = nil
+	cdr.crc32 = crc32.ChecksumIEEE([]byte(mimetype))
+	cdr.compressedSize = uint64(len(mimetype))
+	cdr.uncompressedSize = uint64(len(mimetype))
+
+	if err := w.prepare(cdr); err != nil {
+		return err
+	}
+
+	// Padding the header to align the data would give it an extra field.
+	alignment := w.alignment
+	w.alignment = 0
+	err = w.writeLocalFileHeader(cdr)
+	w.alignment = alignment
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.w.Write([]byte(mimetype)); err != nil {
+		return err
+	}
+
+	w.records = append(w.records, cdr)
+	w.progress.add(cdr.fileName, cdr.uncompressedSize)
+	return nil
+}
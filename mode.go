@@ -0,0 +1,150 @@
+package gozip
+
+import (
+	"io/fs"
+)
+
+// Host systems, from the upper byte of version made by. FAT, NTFS and
+// VFAT archivers put MS-DOS attributes in the low byte of the external
+// attributes. Unix and Mac archivers put a Unix mode in the upper 16
+// bits instead, and write names in the system's own encoding, which
+// decodeName takes to be UTF-8.
+const (
+	fatHost  = 0
+	unixHost = 3
+	ntfsHost = 10
+	vfatHost = 14
+	macHost  = 19
+)
+
+// MS-DOS attributes.
+const (
+	msdosReadOnly = 0x01
+	msdosDir      = 0x10
+)
+
+// Unix file types and mode bits, as stat's st_mode has them.
+const (
+	unixIFMT   = 0xf000
+	unixIFSOCK = 0xc000
+	unixIFLNK  = 0xa000
+	unixIFREG  = 0x8000
+	unixIFBLK  = 0x6000
+	unixIFDIR  = 0x4000
+	unixIFCHR  = 0x2000
+	unixIFIFO  = 0x1000
+	unixISUID  = 0x800
+	unixISGID  = 0x400
+	unixISVTX  = 0x200
+)
+
+// Mode returns the entry's file mode from its external attributes,
+// which hold a Unix mode or MS-DOS attributes depending on the system
+// that made the archive. Entries that don't record permissions get
+// 0644, or 0755 for directories.
+func (e *Entry) Mode() fs.FileMode {
+	mode := e.attrMode()
+	if e.IsDir() {
+		mode |= fs.ModeDir
+	}
+
+	host := e.CreatorVersion >> 8
+	if mode.Perm() == 0 {
+		mode |= 0644
+		if mode.IsDir() {
+			mode |= 0755
+		}
+		if host != unixHost && host != macHost && e.ExternalAttrs&msdosReadOnly != 0 {
+			mode &^= 0222
+		}
+	}
+
+	return mode
+}
+
+// attrMode returns the mode the external attributes alone give.
+func (e *Entry) attrMode() fs.FileMode {
+	switch e.CreatorVersion >> 8 {
+	case unixHost, macHost:
+		return unixModeToFileMode(e.ExternalAttrs >> 16)
+	case fatHost, ntfsHost, vfatHost:
+		if e.ExternalAttrs&msdosDir != 0 {
+			return fs.ModeDir
+		}
+	}
+
+	return 0
+}
+
+// SetMode records mode in the entry's external attributes as a Unix
+// mode, along with the MS-DOS attributes that match it.
+func (e *Entry) SetMode(mode fs.FileMode) {
+	e.CreatorVersion = unixHost<<8 | 20
+	e.ExternalAttrs = fileModeToUnixMode(mode) << 16
+	if mode.IsDir() {
+		e.ExternalAttrs |= msdosDir
+	}
+	if mode&0200 == 0 {
+		e.ExternalAttrs |= msdosReadOnly
+	}
+}
+
+func unixModeToFileMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0777)
+	switch m & unixIFMT {
+	case unixIFBLK:
+		mode |= fs.ModeDevice
+	case unixIFCHR:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case unixIFDIR:
+		mode |= fs.ModeDir
+	case unixIFIFO:
+		mode |= fs.ModeNamedPipe
+	case unixIFLNK:
+		mode |= fs.ModeSymlink
+	case unixIFSOCK:
+		mode |= fs.ModeSocket
+	}
+	if m&unixISUID != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&unixISGID != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&unixISVTX != 0 {
+		mode |= fs.ModeSticky
+	}
+
+	return mode
+}
+
+func fileModeToUnixMode(mode fs.FileMode) uint32 {
+	var m uint32
+	switch mode & fs.ModeType {
+	default:
+		m = unixIFREG
+	case fs.ModeDir:
+		m = unixIFDIR
+	case fs.ModeSymlink:
+		m = unixIFLNK
+	case fs.ModeNamedPipe:
+		m = unixIFIFO
+	case fs.ModeSocket:
+		m = unixIFSOCK
+	case fs.ModeDevice:
+		m = unixIFBLK
+	case fs.ModeDevice | fs.ModeCharDevice:
+		m = unixIFCHR
+	}
+	if mode&fs.ModeSetuid != 0 {
+		m |= unixISUID
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= unixISGID
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= unixISVTX
+	}
+
+	return m | uint32(mode&0777)
+}
@@ -0,0 +1,53 @@
+package gozip
+
+import (
+	"fmt"
+	"strings"
+)
+
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// CheckName returns a description of every reason name would be
+// unsafe or unportable to create on disk.
+func CheckName(name string) []string {
+	var issues []string
+	if strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		issues = append(issues, "absolute path")
+	} else if len(name) >= 2 && name[1] == ':' {
+		issues = append(issues, "absolute path with drive letter")
+	}
+
+	if strings.Contains(name, "\\") {
+		issues = append(issues, "contains backslash")
+	}
+
+	for _, r := range name {
+		if r < 0x20 || r == 0x7F {
+			issues = append(issues, "contains NUL or control character")
+			break
+		}
+	}
+
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			issues = append(issues, "contains .. path component")
+		}
+
+		if part != "." && part != ".." && strings.TrimRight(part, ". ") != part {
+			issues = append(issues, fmt.Sprintf("component %q ends in dot or space", part))
+		}
+
+		base := strings.ToUpper(strings.SplitN(part, ".", 2)[0])
+		if windowsReservedNames[strings.TrimRight(base, " ")] {
+			issues = append(issues, fmt.Sprintf("component %q is a reserved Windows name", part))
+		}
+	}
+
+	return issues
+}
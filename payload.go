@@ -0,0 +1,38 @@
+package gozip
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// PayloadHash returns a SHA-256 hash of the archive's entries' names and
+// contents alone, so archives of the same files hash the same however
+// they were compressed, encrypted or ordered, and whatever their times,
+// attributes, comments and extra fields. Entries are taken in order of
+// their names' bytes, keeping the order of those with the same name.
+// For each, the hash is fed the length of its name as a little-endian
+// uint64, the name, and the SHA-256 of its decompressed contents, which
+// for a directory are empty and for a symlink are its target. Contents
+// are decompressed a piece at a time and checked against their CRC-32
+// on the way.
+func (r *Reader) PayloadHash() ([]byte, error) {
+	entries := append([]*Entry(nil), r.entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	h := sha256.New()
+	for _, e := range entries {
+		var length [8]byte
+		binary.LittleEndian.PutUint64(length[:], uint64(len(e.Name)))
+		h.Write(length[:])
+		h.Write([]byte(e.Name))
+
+		contents := sha256.New()
+		if _, err := e.WriteTo(contents); err != nil {
+			return nil, err
+		}
+		h.Write(contents.Sum(nil))
+	}
+
+	return h.Sum(nil), nil
+}
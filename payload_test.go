@@ -0,0 +1,84 @@
+package gozip
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPayloadHash(t *testing.T) {
+	hash := func(fn func(w *Writer)) []byte {
+		t.Helper()
+
+		h, err := readArchive(t, writeArchive(t, fn)).PayloadHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	contents := bytes.Repeat([]byte("contents\n"), 100)
+	original := hash(func(w *Writer) {
+		writeEntry(t, w, "a.txt", DeflateCompression, contents)
+		writeEntry(t, w, "b.txt", NoCompression, []byte("b"))
+	})
+
+	tests := []struct {
+		name  string
+		fn    func(w *Writer)
+		equal bool
+	}{
+		{"recompressed and reordered", func(w *Writer) {
+			writeEntry(t, w, "b.txt", DeflateCompression, []byte("b"))
+			writeEntry(t, w, "a.txt", ZstdCompression, contents)
+		}, true},
+		{"retimed", func(w *Writer) {
+			for name, data := range map[string][]byte{"a.txt": contents, "b.txt": []byte("b")} {
+				e := &Entry{Name: name, Modified: time.Now(), Method: DeflateCompression}
+				if err := w.WriteEntry(e, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}, true},
+		{"changed contents", func(w *Writer) {
+			writeEntry(t, w, "a.txt", DeflateCompression, contents)
+			writeEntry(t, w, "b.txt", NoCompression, []byte("B"))
+		}, false},
+		{"renamed", func(w *Writer) {
+			writeEntry(t, w, "a.txt", DeflateCompression, contents)
+			writeEntry(t, w, "c.txt", NoCompression, []byte("b"))
+		}, false},
+		// Moving a byte from the contents into the name changes the
+		// hash too.
+		{"name and contents shifted", func(w *Writer) {
+			writeEntry(t, w, "a.txt", DeflateCompression, contents)
+			writeEntry(t, w, "b.txtb", NoCompression, nil)
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := hash(test.fn); bytes.Equal(got, original) != test.equal {
+				t.Errorf("hashes equal is %t, want %t", !test.equal, test.equal)
+			}
+		})
+	}
+
+	// Encrypted entries hash as what they decrypt to, which takes the
+	// password.
+	bs := writeArchive(t, func(w *Writer) {
+		w.SetEncryption(AESEncryption, "secret")
+		writeEntry(t, w, "a.txt", DeflateCompression, contents)
+		writeEntry(t, w, "b.txt", NoCompression, []byte("b"))
+	})
+	if _, err := readArchive(t, bs).PayloadHash(); err == nil {
+		t.Fatal("hashed encrypted entries without the password")
+	}
+	h, err := readArchive(t, bs, WithPassword("secret")).PayloadHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(h, original) {
+		t.Error("decrypted hash differs")
+	}
+}
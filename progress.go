@@ -0,0 +1,90 @@
+package gozip
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Progress is how far through its entries' contents a Reader or Writer
+// has got.
+type Progress struct {
+	// Entry is the name of the entry being read or written.
+	Entry string
+	// Bytes is how many bytes of contents, uncompressed, have been read
+	// or written so far across all entries.
+	Bytes uint64
+	// Total is the uncompressed size of all of a Reader's entries. It is
+	// 0 for a Writer, which can't know.
+	Total uint64
+}
+
+// progress reports a Reader's or Writer's Progress to fn. A nil
+// progress reports nothing.
+type progress struct {
+	fn    func(Progress)
+	bytes uint64
+	total uint64
+}
+
+// WithProgress calls fn as entries are opened and as their contents are
+// read, including by Extract, Verify and WriteTo. It is called from
+// whichever goroutines are reading, so it must be safe to call from
+// several at once if entries are read in parallel.
+func WithProgress(fn func(Progress)) Option {
+	return func(r *Reader) {
+		r.progress = &progress{fn: fn}
+	}
+}
+
+// SetProgress calls fn as entries' contents are written.
+func (w *Writer) SetProgress(fn func(Progress)) {
+	w.progress = &progress{fn: fn}
+}
+
+// setProgress gives r's entries its progress, once it knows what they
+// add up to.
+func (r *Reader) setProgress() {
+	if r.progress == nil {
+		return
+	}
+
+	for _, e := range r.entries {
+		r.progress.total += e.UncompressedSize
+		e.progress = r.progress
+	}
+}
+
+// add reports n more bytes of the entry name.
+func (p *progress) add(name string, n uint64) {
+	if p == nil {
+		return
+	}
+
+	bytes := atomic.AddUint64(&p.bytes, n)
+	p.fn(Progress{Entry: name, Bytes: bytes, Total: p.total})
+}
+
+// reader wraps an entry's decompressed contents to report reading them.
+func (p *progress) reader(e *Entry, rc io.ReadCloser) io.ReadCloser {
+	if p == nil {
+		return rc
+	}
+
+	p.add(e.Name, 0)
+	return &progressReader{ReadCloser: rc, progress: p, name: e.Name}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	progress *progress
+	name     string
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.ReadCloser.Read(p)
+	if n > 0 {
+		pr.progress.add(pr.name, uint64(n))
+	}
+
+	return n, err
+}
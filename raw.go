@@ -0,0 +1,147 @@
+package gozip
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// rawRecord returns a copy of the central directory record e came from,
+// or one made from its fields if it was found by its local header.
+func (e *Entry) rawRecord() *centralDirectoryRecord {
+	if e.record != nil {
+		cdr := *e.record
+		return &cdr
+	}
+
+	lmDate, lmTime := goTimeToMsdosTime(e.Modified)
+	return &centralDirectoryRecord{
+		versionMadeBy:    e.CreatorVersion,
+		versionNeeded:    e.ReaderVersion,
+		bitFlag:          e.Flags,
+		compression:      e.Method,
+		lastModified:     e.Modified,
+		modifiedDate:     lmDate,
+		modifiedTime:     lmTime,
+		crc32:            e.CRC32,
+		compressedSize:   e.CompressedSize,
+		uncompressedSize: e.UncompressedSize,
+		externalAttrs:    e.ExternalAttrs,
+		fileName:         e.rawName,
+		extraField:       e.Extra,
+		zip64:            e.zip64,
+	}
+}
+
+// CopyRaw adds e, an entry read from another archive, with its
+// compressed data copied as it is rather than decompressed and
+// compressed again, so it costs no more than copying the bytes. Its
+// headers are copied as they are too, extra fields byte for byte, even
+// ones this package doesn't understand: changes to e's fields are
+// ignored, and encrypted entries stay encrypted without needing the
+// password. Only the alignment padding is redone if the Writer aligns
+// entries. It fails with ErrTooLarge on entries that were ZIP64 in
+// their archive.
+func (w *Writer) CopyRaw(e *Entry) error {
+	return w.copyRecord(e, e.rawRecord())
+}
+
+// copyRecord is CopyRaw with cdr, one of e's raw records, as the
+// entry's header.
+func (w *Writer) copyRecord(e *Entry, cdr *centralDirectoryRecord) error {
+	if cdr.zip64 != nil {
+		return ErrTooLarge
+	}
+
+	if _, err := e.DataOffset(); err != nil {
+		return err
+	}
+
+	local, err := e.LocalExtra()
+	if err != nil {
+		return err
+	}
+	// A renamed entry's local header loses its Info-ZIP UTF-8 name, as
+	// its central directory record has.
+	if cdr.fileName != e.rawName {
+		local = withoutExtraField(local, unicodePathExtraFieldID)
+	}
+	if w.aligns(cdr) {
+		local = withoutExtraField(local, alignmentExtraFieldID)
+	}
+	cdr.localExtraField = append([]byte{}, local...)
+
+	if err := w.prepare(cdr); err != nil {
+		return err
+	}
+	if err := w.writeLocalFileHeader(cdr); err != nil {
+		return err
+	}
+
+	data := io.NewSectionReader(e.r, e.dataOffset, int64(cdr.compressedSize))
+	n, err := io.Copy(w.w, data)
+	if err != nil {
+		return err
+	}
+	if n != int64(cdr.compressedSize) {
+		return ErrOverranBuffer
+	}
+
+	if cdr.bitFlag&dataDescriptorFlag != 0 {
+		if _, err := w.w.Write(dataDescriptorBytes(cdr)); err != nil {
+			return err
+		}
+	}
+
+	w.records = append(w.records, cdr)
+	w.progress.add(cdr.fileName, cdr.uncompressedSize)
+	return nil
+}
+
+// rewrite writes the archive in the named file, which r was opened
+// from, again with the entries add writes to w, along with its comment
+// and anything prepended to it. The new archive is written next to the
+// old one and renamed over it, so the file is left as it was if anything
+// fails.
+func rewrite(name string, r *Reader, add func(w *Writer) error) error {
+	if r.volumes != nil {
+		return ErrSplitArchive
+	}
+
+	info, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, io.NewSectionReader(r.f, 0, r.prefix)); err != nil {
+		return err
+	}
+
+	// Keep offsets relative to what they were relative to, the start of
+	// the archive or of the file.
+	w := NewWriter(tmp)
+	w.SetOffset(r.prefix - r.base)
+	w.comment = r.comment
+	if err := add(w); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
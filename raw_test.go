@@ -0,0 +1,91 @@
+package gozip
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCopyRawExtraFields(t *testing.T) {
+	contents := bytes.Repeat([]byte("copied\n"), 100)
+	unknown, err := EncodeExtraFields(ExtraField{ID: 0xCAFE, Data: []byte("not understood")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := writeArchive(t, func(w *Writer) {
+		if err := w.WriteEntry(&Entry{Name: "unknown.txt", Modified: testModified, Method: DeflateCompression, Extra: unknown}, contents); err != nil {
+			t.Fatal(err)
+		}
+		// Only the local header has the alignment padding.
+		w.SetAlignment(4096)
+		writeEntry(t, w, "aligned.txt", NoCompression, contents)
+		w.SetAlignment(0)
+		w.SetEncryption(AESEncryption, "secret")
+		writeEntry(t, w, "encrypted.txt", DeflateCompression, contents)
+	})
+	src := readArchive(t, bs)
+
+	copied := readArchive(t, writeArchive(t, func(w *Writer) {
+		for _, e := range src.Entries() {
+			if err := w.CopyRaw(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}), WithPassword("secret"))
+
+	for _, e := range src.Entries() {
+		c := lookupEntry(t, copied, e.Name)
+		if !bytes.Equal(c.Extra, e.Extra) {
+			t.Errorf("%s: central extra field %x, want %x", e.Name, c.Extra, e.Extra)
+		}
+
+		want, err := e.LocalExtra()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.LocalExtra()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: local extra field %x, want %x", e.Name, got, want)
+		}
+
+		data, err := c.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, contents) {
+			t.Errorf("%s: contents differ", e.Name)
+		}
+	}
+
+	// Aligning again replaces the padding rather than adding to it.
+	realigned := readArchive(t, writeArchive(t, func(w *Writer) {
+		w.SetAlignment(4)
+		if err := w.CopyRaw(lookupEntry(t, src, "aligned.txt")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	e := lookupEntry(t, realigned, "aligned.txt")
+	local, err := e.LocalExtra()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := ParseExtraFields(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var padding int
+	for _, f := range fields {
+		if f.ID == alignmentExtraFieldID {
+			padding++
+		}
+	}
+	if padding != 1 {
+		t.Errorf("got %d alignment fields, want 1", padding)
+	}
+	if offset, err := e.DataOffset(); err != nil || offset%4 != 0 {
+		t.Errorf("data at %d, %v", offset, err)
+	}
+}
@@ -0,0 +1,996 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compression is the method number an entry's data is compressed with.
+type Compression uint16
+
+const (
+	NoCompression        Compression = 0
+	ShrinkCompression    Compression = 1
+	Reduce1Compression   Compression = 2
+	Reduce2Compression   Compression = 3
+	Reduce3Compression   Compression = 4
+	Reduce4Compression   Compression = 5
+	ImplodeCompression   Compression = 6
+	DeflateCompression   Compression = 8
+	Deflate64Compression Compression = 9
+	Bzip2Compression     Compression = 12
+	LZMACompression      Compression = 14
+	ZstdCompression      Compression = 93
+	XZCompression        Compression = 95
+)
+
+var compressionNames = map[Compression]string{
+	NoCompression:        "store",
+	ShrinkCompression:    "shrink",
+	Reduce1Compression:   "reduce1",
+	Reduce2Compression:   "reduce2",
+	Reduce3Compression:   "reduce3",
+	Reduce4Compression:   "reduce4",
+	ImplodeCompression:   "implode",
+	DeflateCompression:   "deflate",
+	Deflate64Compression: "deflate64",
+	Bzip2Compression:     "bzip2",
+	LZMACompression:      "lzma",
+	ZstdCompression:      "zstd",
+	XZCompression:        "xz",
+	aesCompression:       "aes",
+}
+
+func (c Compression) String() string {
+	if name, ok := compressionNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("method %d", uint16(c))
+}
+
+type localFileHeader struct {
+	signature        uint32
+	version          uint16
+	bitFlag          uint16
+	compression      Compression
+	lastModified     time.Time
+	crc32            uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+	fileName         string
+	extraField       []byte
+	offset           int
+	dataOffset       int
+}
+
+var ErrOverranBuffer = fmt.Errorf("Overran buffer")
+
+func readUint32(bs []byte, offset int) (uint32, int, error) {
+	end := offset + 4
+	if offset < 0 || end > len(bs) {
+		return 0, 0, ErrOverranBuffer
+	}
+
+	return binary.LittleEndian.Uint32(bs[offset:end]), end, nil
+}
+
+func readUint64(bs []byte, offset int) (uint64, int, error) {
+	end := offset + 8
+	if offset < 0 || end > len(bs) {
+		return 0, 0, ErrOverranBuffer
+	}
+
+	return binary.LittleEndian.Uint64(bs[offset:end]), end, nil
+}
+
+func readUint16(bs []byte, offset int) (uint16, int, error) {
+	end := offset + 2
+	if offset < 0 || end > len(bs) {
+		return 0, 0, ErrOverranBuffer
+	}
+
+	return binary.LittleEndian.Uint16(bs[offset:end]), end, nil
+}
+
+func readBytes(bs []byte, offset int, n int) ([]byte, int, error) {
+	end := offset + n
+	if offset < 0 || end > len(bs) {
+		return nil, 0, ErrOverranBuffer
+	}
+
+	return bs[offset : offset+n], end, nil
+}
+
+func readString(bs []byte, offset int, n int) (string, int, error) {
+	read, end, err := readBytes(bs, offset, n)
+	return string(read), end, err
+}
+
+func msdosTimeToGoTime(d uint16, t uint16) time.Time {
+	seconds := int((t & 0x1F) * 2)
+	minutes := int((t >> 5) & 0x3F)
+	hours := int(t >> 11)
+
+	day := int(d & 0x1F)
+	month := time.Month((d >> 5) & 0x0F)
+	year := int((d>>9)&0x7F) + 1980
+	return time.Date(year, month, day, hours, minutes, seconds, 0, time.Local)
+}
+
+var ErrNotZip = fmt.Errorf("Not a zip file (no local file header or end of central directory record found)")
+var ErrEmptyFile = fmt.Errorf("Empty file")
+
+const dataDescriptorFlag = 0x8
+
+const (
+	localFileHeaderSignature       = 0x04034b50
+	dataDescriptorSignature        = 0x08074b50
+	centralDirectorySignature      = 0x02014b50
+	endOfCentralDirectorySignature = 0x06054b50
+)
+
+type dataDescriptor struct {
+	crc32            uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+}
+
+var ErrNoDataDescriptor = fmt.Errorf("No valid data descriptor found")
+
+func atEndOfCentralDirectory(bs []byte, offset int) bool {
+	signature, _, err := readUint32(bs, offset)
+	return err == nil && signature == endOfCentralDirectorySignature
+}
+
+func atRecordBoundary(bs []byte, offset int) bool {
+	if offset == len(bs) {
+		return true
+	}
+
+	signature, _, err := readUint32(bs, offset)
+	if err != nil {
+		return false
+	}
+
+	return signature == localFileHeaderSignature ||
+		signature == centralDirectorySignature ||
+		signature == endOfCentralDirectorySignature
+}
+
+func readDataDescriptorSize(bs []byte, offset int, zip64 bool) (uint64, int, error) {
+	if zip64 {
+		return readUint64(bs, offset)
+	}
+
+	size, i, err := readUint32(bs, offset)
+	return uint64(size), i, err
+}
+
+// hasZip64ExtraField reports whether a local header's extra field
+// contains a ZIP64 extended information record, which is what tells a
+// reader that the entry's data descriptor uses 8-byte sizes.
+func hasZip64ExtraField(extraField []byte) bool {
+	_, ok := findExtraField(extraField, zip64ExtraFieldID)
+	return ok
+}
+
+// parseDataDescriptor reads the data descriptor that starts at
+// offset. Its signature is optional and the CRC that follows it can be
+// any value, including the signature itself. Its sizes are 8 bytes
+// when the entry is ZIP64 but not every writer that emits 8-byte sizes
+// marks the local header. So all four forms are tried, 32-bit or 64-bit
+// first depending on zip64, unsignatured before signatured. A form is
+// only accepted if its sizes match what was actually read and it ends
+// at the next header or at the end of the buffer.
+func parseDataDescriptor(bs []byte, offset int, zip64 bool, compressedSize, uncompressedSize uint64) (*dataDescriptor, int, error) {
+	for _, wide := range []bool{zip64, !zip64} {
+		for _, signed := range []bool{false, true} {
+			i := offset
+			if signed {
+				signature, next, err := readUint32(bs, i)
+				if err != nil || signature != dataDescriptorSignature {
+					continue
+				}
+				i = next
+			}
+
+			crc32, i, err := readUint32(bs, i)
+			if err != nil {
+				continue
+			}
+
+			cs, i, err := readDataDescriptorSize(bs, i, wide)
+			if err != nil || cs != compressedSize {
+				continue
+			}
+
+			us, i, err := readDataDescriptorSize(bs, i, wide)
+			if err != nil || us != uncompressedSize {
+				continue
+			}
+
+			if !atRecordBoundary(bs, i) {
+				continue
+			}
+
+			return &dataDescriptor{crc32, cs, us}, i, nil
+		}
+	}
+
+	return nil, 0, ErrNoDataDescriptor
+}
+
+// skipDataDescriptorEntry finds the end of an entry whose sizes and
+// CRC were not known when its local header was written (bit 3 of the
+// flags) and so follow its data in a data descriptor. Compressed data
+// has to be decompressed to find where it ends, but nothing decompressed
+// is kept.
+func skipDataDescriptorEntry(bs []byte, start int, compression Compression, zip64 bool) (*dataDescriptor, int, error) {
+	if compression != NoCompression {
+		dcomp, err := decompressor(compression)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		br := bytes.NewReader(bs[start:])
+		r := dcomp(br)
+		defer r.Close()
+		uncompressedSize, err := io.Copy(ioutil.Discard, r)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// flate does not read past the end of its stream from an
+		// io.ByteReader so whatever is left belongs to the descriptor
+		// and beyond. A registered decompressor that reads ahead will
+		// fail to find a matching descriptor here.
+		end := len(bs) - br.Len()
+		return parseDataDescriptor(bs, end, zip64, uint64(end-start), uint64(uncompressedSize))
+	}
+
+	// Stored data has no end marker so look for the first descriptor
+	// that agrees with the bytes before it.
+	for end := start; end < len(bs); end++ {
+		size := uint64(end - start)
+		dd, i, err := parseDataDescriptor(bs, end, zip64, size, size)
+		if err != nil {
+			continue
+		}
+
+		if dd.crc32 == crc32.ChecksumIEEE(bs[start:end]) {
+			return dd, i, nil
+		}
+	}
+
+	return nil, 0, ErrNoDataDescriptor
+}
+
+func parseLocalFileHeader(bs []byte, start int) (*localFileHeader, int, error) {
+	lfh, i, err := parseLocalFileHeaderFields(bs, start)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Only headers are parsed here, entry data is skipped over and left
+	// for Entry.Open to decompress.
+	if lfh.bitFlag&dataDescriptorFlag != 0 {
+		dd, next, err := skipDataDescriptorEntry(bs, i, lfh.compression, hasZip64ExtraField(lfh.extraField))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		lfh.crc32 = dd.crc32
+		lfh.compressedSize = dd.compressedSize
+		lfh.uncompressedSize = dd.uncompressedSize
+		return lfh, next, nil
+	}
+
+	// Data is located by its compressed size alone. Aligning writers pad
+	// the extra field before it, and nothing guarantees the next header
+	// follows it directly.
+	_, i, err = readBytes(bs, i, int(lfh.compressedSize))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return lfh, i, nil
+}
+
+// parseLocalFileHeaderFields parses the local header at start, up to
+// where its data begins.
+func parseLocalFileHeaderFields(bs []byte, start int) (*localFileHeader, int, error) {
+	signature, i, err := readUint32(bs, start)
+	if signature != localFileHeaderSignature {
+		return nil, 0, ErrNotZip
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	version, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	bitFlag, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	compressionRaw, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+	compression := Compression(compressionRaw)
+
+	lmTime, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lmDate, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+	lastModified := msdosTimeToGoTime(lmDate, lmTime)
+
+	crc32, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	compressedSize32, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+	compressedSize := uint64(compressedSize32)
+
+	uncompressedSize32, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+	uncompressedSize := uint64(uncompressedSize32)
+
+	fileNameLength, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	extraFieldLength, i, err := readUint16(bs, i)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fileName, i, err := readString(bs, i, int(fileNameLength))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	extraField, i, err := readBytes(bs, i, int(extraFieldLength))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Unlike the central directory's, a local header's ZIP64 extra field
+	// always holds both sizes.
+	if compressedSize == 0xFFFFFFFF || uncompressedSize == 0xFFFFFFFF {
+		data, ok := findExtraField(extraField, zip64ExtraFieldID)
+		if ok && len(data) >= 16 {
+			uncompressedSize, _, _ = readUint64(data, 0)
+			compressedSize, _, _ = readUint64(data, 8)
+		}
+	}
+
+	return &localFileHeader{
+		signature:        signature,
+		version:          version,
+		bitFlag:          bitFlag,
+		compression:      compression,
+		lastModified:     lastModified,
+		crc32:            crc32,
+		compressedSize:   compressedSize,
+		uncompressedSize: uncompressedSize,
+		fileName:         fileName,
+		extraField:       extraField,
+		offset:           start,
+		dataOffset:       i,
+	}, i, nil
+}
+
+func parseLocalFileHeaders(bs []byte) ([]*localFileHeader, error) {
+	if len(bs) == 0 {
+		return nil, ErrEmptyFile
+	}
+
+	var headers []*localFileHeader
+	end := 0
+	for end < len(bs) {
+		lfh, next, err := parseLocalFileHeader(bs, end)
+		if err == ErrNotZip && end > 0 {
+			break
+		}
+		// An archive with no entries is nothing but its end of
+		// central directory record.
+		if err == ErrNotZip && atEndOfCentralDirectory(bs, end) {
+			break
+		}
+		// A file that doesn't start with a header isn't a damaged
+		// archive, it isn't one at all.
+		if err == ErrNotZip && end == 0 {
+			return nil, err
+		}
+		if err != nil {
+			return nil, formatError(int64(end), "local file header", err)
+		}
+
+		end = next
+		headers = append(headers, lfh)
+	}
+
+	return headers, nil
+}
+
+// Entry is a single file stored in an archive. Modified is to the
+// second or better when the archiver recorded it in an extra field, and
+// Accessed and Created are zero unless it recorded those. Extra is the
+// central directory's extra field, or the local header's for entries
+// found without one; LocalExtra reads the local header's.
+type Entry struct {
+	Name             string
+	Modified         time.Time
+	Accessed         time.Time
+	Created          time.Time
+	Method           Compression
+	Flags            uint16
+	ReaderVersion    uint16
+	CRC32            uint32
+	CompressedSize   uint64
+	UncompressedSize uint64
+	CreatorVersion   uint16
+	ExternalAttrs    uint32
+	Extra            []byte
+	Comment          string
+
+	// rawName is the name as it is stored, before decoding.
+	rawName      string
+	zip64        *Zip64ExtraField
+	r            io.ReaderAt
+	password     []byte
+	limits       *limiter
+	noSymlinks   bool
+	headerOffset int64
+	// dataOffset is -1 until the local header has been read.
+	dataOffset int64
+	// record is the central directory record the entry came from, if
+	// it came from one.
+	record   *centralDirectoryRecord
+	progress *progress
+}
+
+// HeaderOffset returns where in the file the entry's local header
+// starts, which is the order entries' data is laid out in whatever
+// order the central directory lists them.
+func (e *Entry) HeaderOffset() int64 {
+	return e.headerOffset
+}
+
+// Open returns a reader that decompresses the entry's contents. Only
+// the entry's own bytes are read from the archive, and only as the
+// returned reader is read.
+func (e *Entry) Open() (io.ReadCloser, error) {
+	// Directories have no contents, whatever their header claims.
+	if e.IsDir() {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	if _, err := e.DataOffset(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	method := e.Method
+	var aesField *aesExtraField
+	if method == aesCompression {
+		aesField, err = parseAESExtraField(e.Extra)
+		if err != nil {
+			return nil, err
+		}
+		method = aesField.compression
+	}
+
+	dcomp, ok := e.legacyDecompressor(method)
+	if !ok {
+		dcomp, err = decompressor(method)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var data io.Reader = io.NewSectionReader(e.r, e.dataOffset, int64(e.CompressedSize))
+	if e.Flags&encryptedFlag != 0 || aesField != nil {
+		if e.password == nil {
+			return nil, ErrPasswordRequired
+		}
+	}
+
+	var ar *aesReader
+	if aesField != nil {
+		ar, err = newAESReader(data, int64(e.CompressedSize), e.password, aesField)
+		if err != nil {
+			return nil, err
+		}
+		data = ar
+	} else if e.Flags&encryptedFlag != 0 {
+		check := byte(e.CRC32 >> 24)
+		if e.Flags&dataDescriptorFlag != 0 {
+			_, lmTime := goTimeToMsdosTime(e.Modified)
+			if e.record != nil {
+				lmTime = e.record.modifiedTime
+			}
+			check = byte(lmTime >> 8)
+		}
+
+		data, err = newZipCryptoReader(data, e.password, check)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rc := dcomp(data)
+	if ar != nil {
+		rc = &aesEntryReader{ReadCloser: rc, ar: ar}
+	}
+
+	return e.progress.reader(e, e.limits.reader(e, rc)), nil
+}
+
+// OpenN is like Open but reads at most the first n bytes of the entry's
+// contents, decompressing and reading from the archive no more than it
+// takes to get them, so sniffing an entry's type doesn't cost
+// decompressing all of it. Closing a WinZip AES entry still reads the
+// rest of it to check its authentication code.
+func (e *Entry) OpenN(n int64) (io.ReadCloser, error) {
+	rc, err := e.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &headReader{ReadCloser: rc, r: io.LimitReader(rc, n)}, nil
+}
+
+type headReader struct {
+	io.ReadCloser
+	r io.Reader
+}
+
+func (hr *headReader) Read(p []byte) (int, error) {
+	return hr.r.Read(p)
+}
+
+// Reader holds the entries parsed from an archive's central directory.
+// Their contents are not read until opened.
+type Reader struct {
+	entries    []*Entry
+	src        io.ReaderAt
+	size       int64
+	comment    string
+	base       int64
+	prefix     int64
+	cdStart    int64
+	end        int64
+	f          *os.File
+	volumes    []*os.File
+	password   []byte
+	limits     *limiter
+	duplicates DuplicatePolicy
+	encoding   Encoding
+	noSymlinks bool
+	salvage    bool
+	parse      ParseOptions
+	progress   *progress
+
+	// fsNodes is built from entries the first time Reader is used as
+	// an fs.FS, and byName the first time Lookup is called.
+	fsOnce     sync.Once
+	fsNodes    map[string]*fsNode
+	byNameOnce sync.Once
+	byName     map[string]*Entry
+
+	// listed is entries with the directories they imply, built the
+	// first time they are listed if synthesizeDirs is set.
+	synthesizeDirs bool
+	listedOnce     sync.Once
+	listed         []*Entry
+}
+
+// Option configures a Reader.
+type Option func(*Reader)
+
+// WithPassword sets the password encrypted entries are decrypted with.
+func WithPassword(password string) Option {
+	return func(r *Reader) {
+		r.password = []byte(password)
+	}
+}
+
+// Entries returns the archive's entries in the order they appear, with
+// the directories they imply if WithSynthesizeDirs(true) was given.
+func (r *Reader) Entries() []*Entry {
+	return r.listedEntries()
+}
+
+// WithSynthesizeDirs(true) makes Entries, Dirs, All and Since include
+// an entry for every directory that only the names of the entries in it
+// imply, which many archivers don't write entries for, just before the
+// first of those entries. Synthesized entries are modified when that
+// entry was and have no local header or data, so they can be extracted
+// but not copied. By default, as with false, only the entries the
+// archive has are listed. The Reader's fs.FS methods see the implied
+// directories either way, and Lookup never does.
+func WithSynthesizeDirs(synthesize bool) Option {
+	return func(r *Reader) {
+		r.synthesizeDirs = synthesize
+	}
+}
+
+// listedEntries returns the entries Entries lists.
+func (r *Reader) listedEntries() []*Entry {
+	if !r.synthesizeDirs {
+		return r.entries
+	}
+
+	r.listedOnce.Do(func() {
+		dirs := map[string]bool{}
+		for _, e := range r.entries {
+			if e.IsDir() {
+				dirs[strings.TrimSuffix(e.Name, "/")+"/"] = true
+			}
+		}
+
+		for _, e := range r.entries {
+			name := strings.TrimSuffix(e.Name, "/")
+			if fs.ValidPath(name) {
+				for i := 0; i < len(name); i++ {
+					if name[i] != '/' || dirs[name[:i+1]] {
+						continue
+					}
+					dirs[name[:i+1]] = true
+					r.listed = append(r.listed, &Entry{
+						Name:       name[:i+1],
+						Modified:   e.Modified,
+						r:          bytes.NewReader(nil),
+						dataOffset: -1,
+					})
+				}
+			}
+			r.listed = append(r.listed, e)
+		}
+	})
+
+	return r.listed
+}
+
+// Dirs returns the archive's directory entries in the order they
+// appear. Directories that only the names of the entries in them imply
+// aren't included unless WithSynthesizeDirs(true) was given.
+func (r *Reader) Dirs() []*Entry {
+	var dirs []*Entry
+	for _, e := range r.listedEntries() {
+		if e.IsDir() {
+			dirs = append(dirs, e)
+		}
+	}
+
+	return dirs
+}
+
+// RegularFiles returns the archive's entries that are neither
+// directories nor symlinks or other special files, in the order they
+// appear.
+func (r *Reader) RegularFiles() []*Entry {
+	var files []*Entry
+	for _, e := range r.entries {
+		if e.Mode().IsRegular() {
+			files = append(files, e)
+		}
+	}
+
+	return files
+}
+
+// MethodCounts returns how many of the archive's entries use each
+// compression method, as the central directory gives them, which shows
+// at a glance whether any need a decompressor that isn't registered.
+// Encrypted WinZip AES entries are counted by the method under the
+// encryption.
+func (r *Reader) MethodCounts() map[Compression]int {
+	counts := map[Compression]int{}
+	for _, e := range r.entries {
+		method := e.Method
+		if a, ok := e.AESExtraField(); ok {
+			method = a.Method
+		}
+		counts[method]++
+	}
+
+	return counts
+}
+
+// MaxVersionNeeded returns the highest version needed to extract of
+// the archive's entries, such as 45 if any needs ZIP64 or 63 for zstd,
+// in the form of ReaderVersion, or 0 for an empty archive. It is what a
+// reader has to support for the whole archive.
+func (r *Reader) MaxVersionNeeded() uint16 {
+	var max uint16
+	for _, e := range r.entries {
+		if e.ReaderVersion > max {
+			max = e.ReaderVersion
+		}
+	}
+
+	return max
+}
+
+// ArchiveSize returns where the archive ends in what it was read from:
+// the end of the end of central directory record's comment. Anything
+// after that, such as another archive or junk, isn't part of it. With
+// Prefix it tells where the archive is, so of two archives back to
+// back, the Reader finds the second and the first can then be read
+// from the bytes before its Prefix. Archives read without a central
+// directory are taken to run to the end.
+func (r *Reader) ArchiveSize() int64 {
+	return r.end
+}
+
+// Open opens and parses the archive at path. If it is the last volume
+// of a split archive, the others are opened from next to it. The Reader
+// must be closed when done with.
+func Open(path string, opts ...Option) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r, err := NewReader(f, info.Size(), opts...)
+	if err == ErrSplitArchive {
+		f.Close()
+		return openSplit(path, opts...)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r.f = f
+	return r, nil
+}
+
+// NewFromFile parses the archive in f, an already open file, reading
+// from it as NewReader does rather than all at once. The file is the
+// caller's to close, and Close leaves it open. Unlike Open, it doesn't
+// look for the other volumes of a split archive.
+func NewFromFile(f *os.File, opts ...Option) (*Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReader(f, info.Size(), opts...)
+}
+
+// NewReaderAtOffset parses the archive of size bytes that starts offset
+// bytes into r, such as one embedded in a container format with more of
+// the file after it, which NewReader wouldn't find the end of. The
+// archive's offsets, and those its entries report, count from offset.
+func NewReaderAtOffset(r io.ReaderAt, size, offset int64, opts ...Option) (*Reader, error) {
+	return NewReader(io.NewSectionReader(r, offset, size), size, opts...)
+}
+
+// Close closes the files opened by Open. It does nothing for a Reader
+// from NewReader.
+func (r *Reader) Close() error {
+	var err error
+	for _, f := range r.volumes {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if r.f == nil {
+		return err
+	}
+
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func readAt(r io.ReaderAt, offset int64, n int64) ([]byte, error) {
+	bs := make([]byte, n)
+	read, err := r.ReadAt(bs, offset)
+	if int64(read) == n {
+		return bs, nil
+	}
+	if err == io.EOF {
+		return nil, ErrOverranBuffer
+	}
+
+	return nil, err
+}
+
+// NewReader parses the archive of size bytes read from r. Only the end
+// of central directory record and the central directory itself are
+// read up front.
+func NewReader(r io.ReaderAt, size int64, opts ...Option) (*Reader, error) {
+	reader := &Reader{src: r, size: size}
+	for _, opt := range opts {
+		opt(reader)
+	}
+
+	walk := reader.parse.LocalHeaders
+	var cd *centralDirectory
+	var err error
+	if !walk {
+		cd, err = readCentralDirectory(r, size)
+	}
+	salvage := reader.salvage && err != nil && err != ErrSplitArchive && err != ErrEmptyFile
+	if walk || (err == ErrNoEndOfCentralDirectory && !reader.parse.Strict) || salvage {
+		// Without a central directory, fall back to walking local
+		// headers from the front, which is all a truncated stream
+		// leaves to go on, or to scanning for them when salvaging.
+		read := reader.readLocalFileHeaders
+		if salvage {
+			read = reader.salvageLocalFileHeaders
+		}
+		if err := read(r, size); err != nil {
+			return nil, err
+		}
+		reader.entries, err = applyDuplicates(reader.entries, reader.duplicates)
+		if err != nil {
+			return nil, err
+		}
+		if err := reader.limits.checkEntries(reader.entries); err != nil {
+			return nil, err
+		}
+
+		reader.end = size
+		reader.setProgress()
+		return reader, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	reader.comment = cd.eocd.comment
+	reader.base = cd.base
+	reader.end = cd.eocdOffset + endOfCentralDirectoryLength + int64(len(cd.eocd.comment))
+	records := cd.records
+
+	reader.cdStart = cd.start()
+	reader.prefix = reader.cdStart
+	for _, cdr := range records {
+		if offset := cd.offset(cdr.diskNumberStart, cdr.localHeaderOffset); offset < reader.prefix {
+			reader.prefix = offset
+		}
+	}
+
+	entries := make([]*Entry, len(records))
+	for i, cdr := range records {
+		entries[i] = reader.centralEntry(r, cd, cdr)
+	}
+
+	if err := checkOverlaps(entries, cd.start()); err != nil {
+		return nil, err
+	}
+
+	if reader.parse.Strict {
+		if err := checkStrict(cd, entries, size); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err = applyDuplicates(entries, reader.duplicates)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reader.limits.checkEntries(entries); err != nil {
+		return nil, err
+	}
+
+	reader.entries = entries
+	reader.setProgress()
+	return reader, nil
+}
+
+// centralEntry returns the entry for cdr, a record of cd, the central
+// directory of the archive in r.
+func (reader *Reader) centralEntry(r io.ReaderAt, cd *centralDirectory, cdr *centralDirectoryRecord) *Entry {
+	e := &Entry{
+		Name:             reader.decodeText(cdr.fileName, cdr.bitFlag, cdr.versionMadeBy, cdr.extraField, unicodePathExtraFieldID),
+		Modified:         cdr.lastModified,
+		Method:           cdr.compression,
+		Flags:            cdr.bitFlag,
+		ReaderVersion:    cdr.versionNeeded,
+		CRC32:            cdr.crc32,
+		CompressedSize:   cdr.compressedSize,
+		UncompressedSize: cdr.uncompressedSize,
+		CreatorVersion:   cdr.versionMadeBy,
+		ExternalAttrs:    cdr.externalAttrs,
+		Extra:            cdr.extraField,
+		Comment:          reader.decodeText(cdr.comment, cdr.bitFlag, cdr.versionMadeBy, cdr.extraField, unicodeCommentExtraFieldID),
+		rawName:          cdr.fileName,
+		zip64:            cdr.zip64,
+		r:                r,
+		password:         reader.password,
+		limits:           reader.limits,
+		noSymlinks:       reader.noSymlinks,
+		headerOffset:     cd.offset(cdr.diskNumberStart, cdr.localHeaderOffset),
+		dataOffset:       -1,
+		record:           cdr,
+	}
+	e.setPreciseTimes()
+	return e
+}
+
+// readLocalFileHeaders has to read the whole archive since without a
+// central directory there is no telling where entries are without
+// walking through all of them.
+func (reader *Reader) readLocalFileHeaders(r io.ReaderAt, size int64) error {
+	bs, err := readAt(r, 0, size)
+	if err != nil {
+		return err
+	}
+
+	headers, err := parseLocalFileHeaders(bs)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]*Entry, len(headers))
+	for i, lfh := range headers {
+		entries[i] = reader.localEntry(r, lfh)
+	}
+
+	reader.entries = entries
+	return nil
+}
+
+// localEntry returns the entry for a local header read from r.
+func (reader *Reader) localEntry(r io.ReaderAt, lfh *localFileHeader) *Entry {
+	e := &Entry{
+		Name:             reader.decodeText(lfh.fileName, lfh.bitFlag, lfh.version, lfh.extraField, unicodePathExtraFieldID),
+		Modified:         lfh.lastModified,
+		Method:           lfh.compression,
+		Flags:            lfh.bitFlag,
+		ReaderVersion:    lfh.version,
+		CRC32:            lfh.crc32,
+		CompressedSize:   lfh.compressedSize,
+		UncompressedSize: lfh.uncompressedSize,
+		Extra:            lfh.extraField,
+		rawName:          lfh.fileName,
+		r:                r,
+		password:         reader.password,
+		limits:           reader.limits,
+		noSymlinks:       reader.noSymlinks,
+		headerOffset:     int64(lfh.offset),
+		dataOffset:       int64(lfh.dataOffset),
+	}
+	e.setPreciseTimes()
+	return e
+}
@@ -0,0 +1,653 @@
+package gozip
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// streamEntry returns a local header for contents compressed with
+// method, then the data, then a data descriptor with or without its
+// signature, as a streaming writer leaves them with no central
+// directory after. A zip64 descriptor has 8-byte sizes, and the local
+// header says so with a ZIP64 extra field.
+func streamEntry(t *testing.T, name string, method Compression, contents []byte, signed, zip64 bool) []byte {
+	t.Helper()
+
+	data, err := compress(method, flate.DefaultCompression, contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b byteWriter
+	b.uint32(localFileHeaderSignature)
+	b.uint16(20)
+	b.uint16(dataDescriptorFlag)
+	b.uint16(uint16(method))
+	b.uint16(0)
+	b.uint16(0)
+	b.uint32(0)
+	b.uint32(0)
+	b.uint32(0)
+	b.uint16(uint16(len(name)))
+	if zip64 {
+		b.uint16(20)
+	} else {
+		b.uint16(0)
+	}
+	b.WriteString(name)
+	if zip64 {
+		b.uint16(zip64ExtraFieldID)
+		b.uint16(16)
+		b.Write(make([]byte, 16))
+	}
+	b.Write(data)
+
+	if signed {
+		b.uint32(dataDescriptorSignature)
+	}
+	b.uint32(crc32.ChecksumIEEE(contents))
+	if zip64 {
+		b.uint32(uint32(len(data)))
+		b.uint32(0)
+		b.uint32(uint32(len(contents)))
+		b.uint32(0)
+	} else {
+		b.uint32(uint32(len(data)))
+		b.uint32(uint32(len(contents)))
+	}
+
+	return b.Bytes()
+}
+
+func TestDataDescriptors(t *testing.T) {
+	first := []byte("first entry, first entry, first entry\n")
+	second := []byte("second\n")
+
+	tests := []struct {
+		name   string
+		method Compression
+		signed bool
+		zip64  bool
+	}{
+		{"stored", NoCompression, false, false},
+		{"stored signed", NoCompression, true, false},
+		{"stored zip64", NoCompression, false, true},
+		{"stored signed zip64", NoCompression, true, true},
+		{"deflated", DeflateCompression, false, false},
+		{"deflated signed", DeflateCompression, true, false},
+		{"deflated zip64", DeflateCompression, false, true},
+		{"deflated signed zip64", DeflateCompression, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := append(
+				streamEntry(t, "a.txt", test.method, first, test.signed, test.zip64),
+				streamEntry(t, "b.txt", test.method, second, test.signed, test.zip64)...)
+
+			r := readArchive(t, bs)
+			if len(r.Entries()) != 2 {
+				t.Fatalf("got %d entries, want 2", len(r.Entries()))
+			}
+
+			for name, want := range map[string][]byte{"a.txt": first, "b.txt": second} {
+				e := lookupEntry(t, r, name)
+				if e.UncompressedSize != uint64(len(want)) {
+					t.Errorf("%s: got size %d, want %d", name, e.UncompressedSize, len(want))
+				}
+
+				rc, err := e.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s: got %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDataDescriptorWriters(t *testing.T) {
+	names := []string{"test/hello.text", "test/large.text"}
+	contents := map[string][]byte{}
+	for _, name := range names {
+		bs, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[name] = bs
+	}
+
+	// archive/zip follows every entry with a signed data descriptor.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(contents[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	type archive struct {
+		writer string
+		bs     []byte
+	}
+	archives := []archive{{"archive/zip", buf.Bytes()}}
+	// Written by test/zip.sh.
+	for _, path := range []string{"test/descriptors.zip", "test/descriptors-python.zip"} {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives = append(archives, archive{path, bs})
+	}
+
+	for _, a := range archives {
+		writer, bs := a.writer, a.bs
+		// The same entries with the descriptors' signatures cut out, as
+		// some writers leave them, are found walking local headers.
+		r := readArchive(t, bs)
+		unsigned := bs[:bytes.Index(bs, []byte("PK\x01\x02"))]
+		for i := len(names) - 1; i >= 0; i-- {
+			e := lookupEntry(t, r, names[i])
+			if e.Flags&dataDescriptorFlag == 0 {
+				t.Fatalf("%s: %s has no data descriptor", writer, e.Name)
+			}
+			data, err := e.DataOffset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			end := data + int64(e.CompressedSize)
+			if string(unsigned[end:end+4]) != "PK\x07\x08" {
+				t.Fatalf("%s: %s's data descriptor isn't signed", writer, e.Name)
+			}
+			unsigned = append(unsigned[:end:end], unsigned[end+4:]...)
+		}
+
+		for _, test := range []struct {
+			name string
+			r    *Reader
+		}{
+			{"signed", r},
+			{"signed walking", readArchive(t, bs, WithParseOptions(ParseOptions{LocalHeaders: true}))},
+			{"unsigned walking", readArchive(t, unsigned, WithParseOptions(ParseOptions{LocalHeaders: true}))},
+		} {
+			t.Run(writer+" "+test.name, func(t *testing.T) {
+				if len(test.r.Entries()) != len(names) {
+					t.Fatalf("got %d entries, want %d", len(test.r.Entries()), len(names))
+				}
+				for _, name := range names {
+					got, err := lookupEntry(t, test.r, name).ReadAll()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, contents[name]) {
+						t.Errorf("%s: contents differ", name)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBzip2(t *testing.T) {
+	// Written by Info-ZIP's zip -Z bzip2, in test/zip.sh.
+	r, err := Open("test/bzip2.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	want, err := os.ReadFile("test/large.text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := lookupEntry(t, r, "test/large.text")
+	if e.Method != Bzip2Compression {
+		t.Errorf("got method %s, want %s", e.Method, Bzip2Compression)
+	}
+	got, err := e.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("contents differ")
+	}
+}
+
+func TestNotArchives(t *testing.T) {
+	empty := writeArchive(t, func(w *Writer) {})
+
+	tests := []struct {
+		name string
+		bs   []byte
+		opts []Option
+		err  error
+	}{
+		{"empty file", nil, nil, ErrEmptyFile},
+		{"junk", []byte("junk"), nil, ErrNotZip},
+		{"strict junk", []byte("junk"), []Option{WithParseOptions(ParseOptions{Strict: true})}, ErrNoEndOfCentralDirectory},
+		{"empty archive", empty, nil, nil},
+		// Cut short, the end record is only found walking from the front.
+		{"truncated empty archive", empty[:len(empty)-1], nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(test.bs), int64(len(test.bs)), test.opts...)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err == nil && len(r.Entries()) != 0 {
+				t.Errorf("got %d entries, want none", len(r.Entries()))
+			}
+		})
+	}
+}
+
+// countingReaderAt counts the bytes read from r.
+type countingReaderAt struct {
+	r    io.ReaderAt
+	read int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.read += int64(n)
+	return n, err
+}
+
+func TestOpenN(t *testing.T) {
+	// Hex of random bytes only halves in size deflated, so the entry's
+	// compressed data is still megabytes.
+	contents := []byte(fmt.Sprintf("%x", testRandom(2<<20)))
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "stored.txt", NoCompression, contents)
+		writeEntry(t, w, "deflated.txt", DeflateCompression, contents)
+	})
+
+	tests := []struct {
+		name  string
+		entry string
+		n     int64
+	}{
+		{"stored", "stored.txt", 512},
+		{"deflated", "deflated.txt", 512},
+		{"nothing", "deflated.txt", 0},
+		{"more than there is", "deflated.txt", int64(len(contents)) + 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cr := &countingReaderAt{r: bytes.NewReader(bs)}
+			r, err := NewReader(cr, int64(len(bs)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := lookupEntry(t, r, test.entry)
+			if e.Method != DeflateCompression && test.entry == "deflated.txt" {
+				t.Fatal("entry wasn't deflated")
+			}
+
+			cr.read = 0
+			rc, err := e.OpenN(test.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := contents
+			if test.n < int64(len(contents)) {
+				want = contents[:test.n]
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("got %d bytes, want the first %d", len(got), len(want))
+			}
+			if len(want) < len(contents) && cr.read > 64<<10 {
+				t.Errorf("read %d bytes of the archive for %d of the entry", cr.read, len(want))
+			}
+		})
+	}
+}
+
+func TestNewFromFile(t *testing.T) {
+	contents := testRandom(16 << 20)
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "big.bin", NoCompression, contents)
+		writeEntry(t, w, "small.txt", DeflateCompression, []byte("small\n"))
+	})
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(path, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	bs = nil
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	r, err := NewFromFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := lookupEntry(t, r, "small.txt").ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if string(got) != "small\n" {
+		t.Errorf("got %q", got)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes reading a small entry", allocated)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Stat(); err != nil {
+		t.Errorf("file was closed: %v", err)
+	}
+}
+
+func TestDirsAndRegularFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		dirs    []string
+		files   []string
+	}{
+		{"explicit directories", []string{"dir/", "dir/a.txt", "dir/sub/", "dir/sub/b.txt", "link"}, []string{"dir/", "dir/sub/"}, []string{"dir/a.txt", "dir/sub/b.txt"}},
+		{"implied directories", []string{"dir/a.txt", "dir/sub/b.txt", "link"}, nil, []string{"dir/a.txt", "dir/sub/b.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				for _, name := range test.entries {
+					e := &Entry{Name: name, Modified: testModified}
+					var contents []byte
+					switch {
+					case name == "link":
+						e.SetMode(os.ModeSymlink | 0777)
+						contents = []byte("dir/a.txt")
+					case !e.IsDir():
+						contents = []byte(name)
+					}
+					if err := w.WriteEntry(e, contents); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+			r := readArchive(t, bs)
+
+			for _, c := range []struct {
+				kind    string
+				entries []*Entry
+				want    []string
+			}{
+				{"directories", r.Dirs(), test.dirs},
+				{"files", r.RegularFiles(), test.files},
+			} {
+				var got []string
+				for _, e := range c.entries {
+					got = append(got, e.Name)
+				}
+				if strings.Join(got, ",") != strings.Join(c.want, ",") {
+					t.Errorf("got %s %v, want %v", c.kind, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxVersionNeeded(t *testing.T) {
+	contents := bytes.Repeat([]byte("versions\n"), 100)
+	tests := []struct {
+		name    string
+		methods []Compression
+		zip64   bool
+		want    uint16
+	}{
+		{"empty", nil, false, 0},
+		{"stored", []Compression{NoCompression}, false, 10},
+		{"mixed", []Compression{NoCompression, DeflateCompression, NoCompression}, false, 20},
+		{"zstd", []Compression{DeflateCompression, ZstdCompression}, false, 63},
+		{"ZIP64", []Compression{NoCompression, DeflateCompression}, true, 45},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				for i, method := range test.methods {
+					writeEntry(t, w, fmt.Sprintf("%d.txt", i), method, contents)
+				}
+			})
+			if test.zip64 {
+				// Mark the first entry as needing ZIP64, as its writer would.
+				cd := bytes.Index(bs, []byte("PK\x01\x02"))
+				binary.LittleEndian.PutUint16(bs[cd+6:], 45)
+			}
+
+			if got := readArchive(t, bs).MaxVersionNeeded(); got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewReaderAtOffset(t *testing.T) {
+	contents := bytes.Repeat([]byte("embedded\n"), 100)
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", DeflateCompression, contents)
+		writeEntry(t, w, "b.txt", NoCompression, contents)
+	})
+
+	// Far more follows the archive than the end of central directory
+	// record is looked for in.
+	head, tail := testRandom(1000), testRandom(200000)
+	file := append(append(append([]byte(nil), head...), bs...), tail...)
+	if _, err := NewReader(bytes.NewReader(file), int64(len(file))); err == nil {
+		t.Fatal("found an archive without its offset")
+	}
+
+	r, err := NewReaderAtOffset(bytes.NewReader(file), int64(len(bs)), int64(len(head)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alone := readArchive(t, bs)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		e := lookupEntry(t, r, name)
+		got, err := e.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, contents) {
+			t.Errorf("%s: contents differ", name)
+		}
+
+		// Offsets count from the start of the archive.
+		if want := lookupEntry(t, alone, name).HeaderOffset(); e.HeaderOffset() != want {
+			t.Errorf("%s: header at %d, want %d", name, e.HeaderOffset(), want)
+		}
+	}
+}
+
+func TestArchiveSize(t *testing.T) {
+	first := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "first.txt", NoCompression, []byte("first\n"))
+		if err := w.SetComment("the first archive"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	second := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "second.txt", NoCompression, []byte("second\n"))
+	})
+	junk := []byte("trailing junk")
+	file := append(append(append([]byte(nil), first...), second...), junk...)
+
+	// Read from the back: the second archive is found, and the first
+	// is in the bytes before it.
+	r := readArchive(t, file)
+	lookupEntry(t, r, "second.txt")
+	if want := int64(len(first) + len(second)); r.ArchiveSize() != want {
+		t.Errorf("second archive ends at %d, want %d", r.ArchiveSize(), want)
+	}
+	if r.Prefix() != int64(len(first)) {
+		t.Fatalf("second archive starts at %d, want %d", r.Prefix(), len(first))
+	}
+
+	r = readArchive(t, file[:r.Prefix()])
+	lookupEntry(t, r, "first.txt")
+	if r.ArchiveSize() != int64(len(first)) {
+		t.Errorf("first archive ends at %d, want %d", r.ArchiveSize(), len(first))
+	}
+
+	// Walking local headers, the archive runs to the end.
+	r = readArchive(t, second, WithParseOptions(ParseOptions{LocalHeaders: true}))
+	if r.ArchiveSize() != int64(len(second)) {
+		t.Errorf("walked archive ends at %d, want %d", r.ArchiveSize(), len(second))
+	}
+}
+
+// boundedReaderAt fails the test on reads outside its size bytes.
+type boundedReaderAt struct {
+	t  *testing.T
+	bs []byte
+}
+
+func (b boundedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(b.bs)) {
+		b.t.Errorf("read %d bytes at %d of %d", len(p), off, len(b.bs))
+	}
+	return bytes.NewReader(b.bs).ReadAt(p, off)
+}
+
+func TestTinyArchives(t *testing.T) {
+	empty := writeArchive(t, func(w *Writer) {})
+	one := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a", NoCompression, []byte("a"))
+	})
+	strict := []Option{WithParseOptions(ParseOptions{Strict: true})}
+
+	tests := []struct {
+		name    string
+		bs      []byte
+		opts    []Option
+		entries int
+		err     error
+	}{
+		{"0 bytes", nil, nil, 0, ErrEmptyFile},
+		{"10 bytes", []byte("0123456789"), nil, 0, ErrNotZip},
+		{"10 bytes strict", []byte("0123456789"), strict, 0, ErrNoEndOfCentralDirectory},
+		{"10 bytes of end record", empty[:10], strict, 0, ErrNoEndOfCentralDirectory},
+		{"end record alone", empty, strict, 0, nil},
+		{"comment overrunning the file", append(empty[:20:20], 1, 0), strict, 0, ErrNoEndOfCentralDirectory},
+		{"one byte entry", one, strict, 1, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader(boundedReaderAt{t, test.bs}, int64(len(test.bs)), test.opts...)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err == nil && len(r.Entries()) != test.entries {
+				t.Errorf("got %d entries, want %d", len(r.Entries()), test.entries)
+			}
+		})
+	}
+}
+
+func TestSynthesizeDirs(t *testing.T) {
+	names := []string{"a/b/c.txt", "a/", "d.txt", "e/f.txt"}
+	bs := writeArchive(t, func(w *Writer) {
+		for _, name := range names {
+			writeEntry(t, w, name, NoCompression, nil)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		entries []string
+		dirs    []string
+	}{
+		{"default", nil, names, []string{"a/"}},
+		{"off", []Option{WithSynthesizeDirs(false)}, names, []string{"a/"}},
+		{"on", []Option{WithSynthesizeDirs(true)}, []string{"a/b/", "a/b/c.txt", "a/", "d.txt", "e/", "e/f.txt"}, []string{"a/b/", "a/", "e/"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := readArchive(t, bs, test.opts...)
+			for _, c := range []struct {
+				kind    string
+				entries []*Entry
+				want    []string
+			}{
+				{"entries", r.Entries(), test.entries},
+				{"directories", r.Dirs(), test.dirs},
+			} {
+				var got []string
+				for _, e := range c.entries {
+					got = append(got, e.Name)
+				}
+				if strings.Join(got, ",") != strings.Join(c.want, ",") {
+					t.Errorf("got %s %v, want %v", c.kind, got, c.want)
+				}
+			}
+
+			for _, e := range r.Entries() {
+				if _, ok := r.Lookup(e.Name); ok {
+					continue
+				}
+
+				// Synthesized directories can be extracted, but have
+				// no local header to copy.
+				dir := t.TempDir()
+				if err := e.Extract(dir); err != nil {
+					t.Fatal(err)
+				}
+				if info, err := os.Stat(filepath.Join(dir, e.Name)); err != nil || !info.IsDir() {
+					t.Errorf("%s: extracted %v, %v", e.Name, info, err)
+				}
+				if _, err := e.LocalExtra(); err == nil {
+					t.Errorf("%s: got a local header", e.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package gozip
+
+import (
+	"compress/bzip2"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Decompressor returns a reader that decompresses the data read from r.
+// Closing it must not close r.
+type Decompressor func(r io.Reader) io.ReadCloser
+
+var ErrUnsupportedCompression = fmt.Errorf("Unsupported compression method")
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[Compression]Decompressor{
+		NoCompression:        ioutil.NopCloser,
+		ShrinkCompression:    newUnshrinkReader,
+		DeflateCompression:   flate.NewReader,
+		Deflate64Compression: newDeflate64Reader,
+		Bzip2Compression:     newBzip2Reader,
+		ZstdCompression:      newZstdReader,
+	}
+)
+
+func newBzip2Reader(r io.Reader) io.ReadCloser {
+	return ioutil.NopCloser(bzip2.NewReader(r))
+}
+
+// RegisterDecompressor makes a decompressor available for entries that
+// use the given compression method number. It panics if the method
+// already has a decompressor, including the built-ins.
+func RegisterDecompressor(method uint16, dcomp Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	if _, ok := decompressors[Compression(method)]; ok || isLegacyMethod(Compression(method)) {
+		panic(fmt.Sprintf("decompressor already registered for method %d", method))
+	}
+	decompressors[Compression(method)] = dcomp
+}
+
+func decompressor(method Compression) (Decompressor, error) {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+
+	dcomp, ok := decompressors[method]
+	if !ok {
+		return nil, &UnsupportedMethodError{Method: method}
+	}
+
+	return dcomp, nil
+}
+
+// Compressor returns a writer that compresses what is written to it to
+// w. Closing it must flush the compressed data but not close w.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// compressors return a writer that compresses what is written to it to
+// w, at level if the method has levels. Closing it flushes the
+// compressed data but does not close w.
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[Compression]func(w io.Writer, level int) (io.WriteCloser, error){
+		NoCompression: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return nopWriteCloser{w}, nil
+		},
+		DeflateCompression: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		},
+		ZstdCompression: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return newZstdWriter(w), nil
+		},
+	}
+)
+
+// RegisterCompressor makes a compressor available to the Writer for
+// entries that use the given compression method number, which it
+// otherwise refuses with ErrUnsupportedCompression. The Writer's level
+// isn't passed on. It panics if the method already has a compressor,
+// including the built-ins.
+func RegisterCompressor(method uint16, comp Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+
+	if _, ok := compressors[Compression(method)]; ok {
+		panic(fmt.Sprintf("compressor already registered for method %d", method))
+	}
+	compressors[Compression(method)] = func(w io.Writer, level int) (io.WriteCloser, error) {
+		return comp(w)
+	}
+}
+
+func compressor(method Compression) (func(w io.Writer, level int) (io.WriteCloser, error), error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	comp, ok := compressors[method]
+	if !ok {
+		return nil, &UnsupportedMethodError{Method: method}
+	}
+
+	return comp, nil
+}
@@ -0,0 +1,169 @@
+package gozip
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// xorReader flips every bit of what it reads, a stand-in compression
+// method for testing the registry.
+type xorReader struct {
+	r io.Reader
+}
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= 0xFF
+	}
+	return n, err
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	const method = 0xF00D
+	contents := []byte("custom method\n")
+
+	flipped := make([]byte, len(contents))
+	for i, b := range contents {
+		flipped[i] = b ^ 0xFF
+	}
+
+	// Write the flipped bytes stored, then mark the entry as using the
+	// custom method with the CRC of what it decompresses to.
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, flipped)
+	})
+	cd := bytes.Index(bs, []byte("PK\x01\x02"))
+	binary.LittleEndian.PutUint16(bs[8:], method)
+	binary.LittleEndian.PutUint32(bs[14:], crc32.ChecksumIEEE(contents))
+	binary.LittleEndian.PutUint16(bs[cd+10:], method)
+	binary.LittleEndian.PutUint32(bs[cd+16:], crc32.ChecksumIEEE(contents))
+
+	e := lookupEntry(t, readArchive(t, bs), "a.txt")
+	if _, err := e.Open(); !errors.Is(err, ErrUnsupportedCompression) {
+		t.Fatalf("got %v before registering, want %v", err, ErrUnsupportedCompression)
+	}
+
+	RegisterDecompressor(method, func(r io.Reader) io.ReadCloser {
+		return ioutil.NopCloser(xorReader{r})
+	})
+	defer func() {
+		decompressorsMu.Lock()
+		delete(decompressors, method)
+		decompressorsMu.Unlock()
+	}()
+
+	rc, err := e.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, contents) {
+		t.Errorf("got %q, want %q", got, contents)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("registering the method again didn't panic")
+			}
+		}()
+		RegisterDecompressor(method, nil)
+	}()
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	// Deflate at its best under a method number of its own.
+	const method = 0xBEEF
+	contents := bytes.Repeat([]byte("custom compressor\n"), 100)
+	write := func(w *Writer) error {
+		e := &Entry{Name: "written.txt", Modified: testModified, Method: method}
+		if err := w.WriteEntry(e, contents); err != nil {
+			return err
+		}
+		fw, err := w.CreateEntry(&Entry{Name: "streamed.txt", Modified: testModified, Method: method})
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(contents)
+		return err
+	}
+
+	if err := write(NewWriter(ioutil.Discard)); !errors.Is(err, ErrUnsupportedCompression) {
+		t.Fatalf("got %v before registering, want %v", err, ErrUnsupportedCompression)
+	}
+
+	RegisterCompressor(method, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestCompression)
+	})
+	RegisterDecompressor(method, flate.NewReader)
+	defer func() {
+		compressorsMu.Lock()
+		delete(compressors, method)
+		compressorsMu.Unlock()
+		decompressorsMu.Lock()
+		delete(decompressors, method)
+		decompressorsMu.Unlock()
+	}()
+
+	bs := writeArchive(t, func(w *Writer) {
+		if err := write(w); err != nil {
+			t.Fatal(err)
+		}
+	})
+	r := readArchive(t, bs)
+	for _, name := range []string{"written.txt", "streamed.txt"} {
+		e := lookupEntry(t, r, name)
+		if e.Method != method {
+			t.Errorf("%s: got method %d, want %d", name, e.Method, method)
+		}
+		got, err := e.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, contents) {
+			t.Errorf("%s: contents differ", name)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("registering the method again didn't panic")
+			}
+		}()
+		RegisterCompressor(method, nil)
+	}()
+}
+
+func TestMethodCounts(t *testing.T) {
+	contents := bytes.Repeat([]byte("compressible\n"), 100)
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, contents)
+		writeEntry(t, w, "b.txt", DeflateCompression, contents)
+		writeEntry(t, w, "c.txt", ZstdCompression, contents)
+		w.SetEncryption(AESEncryption, "secret")
+		writeEntry(t, w, "d.txt", DeflateCompression, contents)
+	})
+
+	got := readArchive(t, bs).MethodCounts()
+	want := map[Compression]int{NoCompression: 1, DeflateCompression: 2, ZstdCompression: 1}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for method, n := range want {
+		if got[method] != n {
+			t.Errorf("got %d %s entries, want %d", got[method], method, n)
+		}
+	}
+}
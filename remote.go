@@ -0,0 +1,105 @@
+package gozip
+
+import (
+	"io"
+	"sync"
+)
+
+// SizedReaderAt is an io.ReaderAt that knows its size, as
+// *io.SectionReader, *bytes.Reader and the remote readers here do.
+type SizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// NewReaderAt is NewReader for readers that know their size.
+func NewReaderAt(r SizedReaderAt, opts ...Option) (*Reader, error) {
+	return NewReader(r, r.Size(), opts...)
+}
+
+// remoteBlockSize is the least a blockCache fetches at once, since
+// decompressors read a few KiB at a time and every fetch from a remote
+// object costs a round trip.
+const remoteBlockSize = 1 << 20
+
+// blockCache serves reads from the last block fetch returned, fetching
+// another when a read starts outside it.
+type blockCache struct {
+	// fetch returns the n bytes at off.
+	fetch func(off, n int64) ([]byte, error)
+	size  int64
+
+	mu          sync.Mutex
+	block       []byte
+	blockOffset int64
+}
+
+// NewBufferedReaderAt wraps r, of size bytes, so reads from it are made
+// at least 1MiB long and the last is kept to serve the reads after it.
+// Reading an entry then takes a few large reads of r rather than many
+// small ones, which matters when every read of r is a request to a
+// remote object store through its SDK.
+func NewBufferedReaderAt(r io.ReaderAt, size int64) SizedReaderAt {
+	return &blockCache{
+		fetch: func(off, n int64) ([]byte, error) {
+			block := make([]byte, n)
+			if _, err := r.ReadAt(block, off); err != nil && err != io.EOF {
+				return nil, err
+			}
+			return block, nil
+		},
+		size: size,
+	}
+}
+
+// Size returns the size of the file.
+func (c *blockCache) Size() int64 {
+	return c.size
+}
+
+// ReadAt reads len(p) bytes at off, fetching what the last block
+// doesn't have. It is safe for concurrent use, though fetches are made
+// one at a time.
+func (c *blockCache) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off+int64(n) < c.size {
+		block, start, err := c.blockAt(off+int64(n), len(p)-n)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block[off+int64(n)-start:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// blockAt returns a block holding off and, if it can, the want bytes
+// after it, and where the block starts.
+func (c *blockCache) blockAt(off int64, want int) ([]byte, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if off >= c.blockOffset && off < c.blockOffset+int64(len(c.block)) {
+		return c.block, c.blockOffset, nil
+	}
+
+	n := int64(want)
+	if n < remoteBlockSize {
+		n = remoteBlockSize
+	}
+	if off+n > c.size {
+		n = c.size - off
+	}
+
+	block, err := c.fetch(off, n)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	c.block, c.blockOffset = block, off
+	return block, off, nil
+}
@@ -0,0 +1,179 @@
+package gozip
+
+import (
+	"os"
+	"strings"
+)
+
+// RenameEntry renames the entry named oldName in the archive in the
+// named file to newName, along with the entries under it if it is a
+// directory, failing with ErrEntryNotFound if there is none and with
+// ErrDuplicateEntry if a new name is taken. Compressed data is never
+// touched. When every name keeps its length the names are overwritten
+// where they are in the local headers and central directory; otherwise
+// the archive is rewritten as DeleteEntries does.
+func RenameEntry(name, oldName, newName string) error {
+	r, err := Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	oldDir := strings.TrimSuffix(oldName, "/") + "/"
+	newDir := strings.TrimSuffix(newName, "/") + "/"
+	renamed := map[*Entry]*centralDirectoryRecord{}
+	taken := map[string]bool{}
+	for _, e := range r.entries {
+		switch {
+		case strings.HasPrefix(e.Name, oldDir):
+			renamed[e] = e.renamedRecord(newDir + strings.TrimPrefix(e.Name, oldDir))
+		case e.Name == oldName:
+			renamed[e] = e.renamedRecord(newName)
+		default:
+			taken[e.Name] = true
+		}
+	}
+
+	if len(renamed) == 0 {
+		return ErrEntryNotFound
+	}
+	for _, cdr := range renamed {
+		if taken[cdr.fileName] {
+			return ErrDuplicateEntry
+		}
+		taken[cdr.fileName] = true
+	}
+
+	inPlace, err := renamesInPlace(r, renamed)
+	if err != nil {
+		return err
+	}
+	if inPlace {
+		r.Close()
+		return renameInPlace(name, renamed)
+	}
+
+	return rewrite(name, r, func(w *Writer) error {
+		for _, e := range r.entries {
+			cdr, ok := renamed[e]
+			if !ok {
+				cdr = e.rawRecord()
+			}
+			if err := w.copyRecord(e, cdr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// renamedRecord returns e's raw record with its name changed to name,
+// flagged as UTF-8 if it needs to be, and without the Info-ZIP UTF-8
+// name that would otherwise override it.
+func (e *Entry) renamedRecord(name string) *centralDirectoryRecord {
+	cdr := e.rawRecord()
+	cdr.fileName = name
+	if !isASCII(name) {
+		cdr.bitFlag |= utf8Flag
+	}
+	cdr.extraField = withoutExtraField(cdr.extraField, unicodePathExtraFieldID)
+	return cdr
+}
+
+// withoutExtraField returns extraField without its id records. One that
+// can't be parsed is returned as it is.
+func withoutExtraField(extraField []byte, id uint16) []byte {
+	fields, err := ParseExtraFields(extraField)
+	if err != nil {
+		return extraField
+	}
+
+	var kept []ExtraField
+	for _, f := range fields {
+		if f.ID != id {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == len(fields) {
+		return extraField
+	}
+
+	b, err := EncodeExtraFields(kept...)
+	if err != nil {
+		return extraField
+	}
+
+	return b
+}
+
+// renamesInPlace reports whether the renamed records differ from the
+// ones they replace only in names of the same length as before, in both
+// the central directory and the local headers.
+func renamesInPlace(r *Reader, renamed map[*Entry]*centralDirectoryRecord) (bool, error) {
+	for e, cdr := range renamed {
+		old := e.record
+		if old == nil || old.zip64 != nil || len(old.fileName) != len(cdr.fileName) ||
+			old.bitFlag != cdr.bitFlag || len(old.extraField) != len(cdr.extraField) {
+			return false, nil
+		}
+
+		header, err := readAt(e.r, e.headerOffset, localFileHeaderLength)
+		if err != nil {
+			return false, err
+		}
+		fileNameLength, _, err := readUint16(header, 26)
+		if err != nil {
+			return false, err
+		}
+		if int(fileNameLength) != len(cdr.fileName) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// renameInPlace overwrites the names of the renamed entries in the
+// named file, in their local headers and central directory records.
+func renameInPlace(name string, renamed map[*Entry]*centralDirectoryRecord) error {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	cd, err := readCentralDirectory(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	// Entries are matched to their central directory records by where
+	// their local headers are, which no two can share.
+	records := map[int64]int64{}
+	offset := cd.start()
+	for _, cdr := range cd.records {
+		records[cd.offset(cdr.diskNumberStart, cdr.localHeaderOffset)] = offset
+		offset += centralDirectoryRecordLength + int64(len(cdr.fileName)+len(cdr.extraField)+len(cdr.comment))
+	}
+
+	for e, cdr := range renamed {
+		recordOffset, ok := records[e.headerOffset]
+		if !ok {
+			return ErrEntryNotFound
+		}
+
+		if _, err := f.WriteAt([]byte(cdr.fileName), e.headerOffset+localFileHeaderLength); err != nil {
+			return err
+		}
+		if _, err := f.WriteAt([]byte(cdr.fileName), recordOffset+centralDirectoryRecordLength); err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,269 @@
+package gozip
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Salvaged is what Repair made of a local header it found.
+type Salvaged struct {
+	Name   string
+	Offset int64
+	// Err is why the entry was left out, or nil if it was kept.
+	Err error
+
+	entry *Entry
+}
+
+// WithSalvage makes NewReader, when an archive's central directory is
+// missing or damaged, scan the whole archive for local headers and take
+// the entries it can, passing over those it can't, rather than fail.
+// Entries read this way are checked against their CRC-32 as usual.
+func WithSalvage() Option {
+	return func(r *Reader) {
+		r.salvage = true
+	}
+}
+
+// salvageLocalFileHeaders reads the entries salvage finds in the
+// archive of size bytes in r.
+func (reader *Reader) salvageLocalFileHeaders(r io.ReaderAt, size int64) error {
+	bs, err := readAt(r, 0, size)
+	if err != nil {
+		return err
+	}
+
+	var entries []*Entry
+	for _, s := range reader.salvageEntries(r, bs) {
+		if s.Err == nil {
+			entries = append(entries, s.entry)
+		}
+	}
+
+	reader.entries = entries
+	return nil
+}
+
+// salvageEntries scans bs, the archive in r, for local headers wherever
+// they are rather than following one to the next, so junk and damaged
+// entries are passed over. Each that parses is skipped to its end, and
+// whatever central directory records can still be found give the
+// entries the attributes and comments local headers don't have.
+func (reader *Reader) salvageEntries(r io.ReaderAt, bs []byte) []Salvaged {
+	var found []Salvaged
+	signature := []byte("PK\x03\x04")
+	for i := 0; ; {
+		j := bytes.Index(bs[i:], signature)
+		if j < 0 {
+			break
+		}
+		start := i + j
+
+		lfh, next, err := parseLocalFileHeader(bs, start)
+		if err != nil {
+			// Headers whose name can't even be read are most likely
+			// the signature turning up in some entry's data.
+			if name, flags, ok := localHeaderName(bs, start); ok {
+				found = append(found, Salvaged{
+					Name:   reader.decodeText(name, flags, 0, nil, unicodePathExtraFieldID),
+					Offset: int64(start),
+					Err:    err,
+				})
+			}
+			i = start + len(signature)
+			continue
+		}
+
+		e := reader.localEntry(r, lfh)
+		found = append(found, Salvaged{Name: e.Name, Offset: int64(start), entry: e})
+		i = next
+	}
+
+	reader.applySalvagedRecords(found, bs)
+	return found
+}
+
+// localHeaderName returns the raw name and flags of the local header
+// at start, if there is room in bs for them and they look like a real
+// header's: a version some archiver has written and a name without
+// control characters.
+func localHeaderName(bs []byte, start int) (string, uint16, bool) {
+	version, i, err := readUint16(bs, start+4)
+	if err != nil || version&0xFF > 63 {
+		return "", 0, false
+	}
+
+	flags, _, err := readUint16(bs, i)
+	if err != nil {
+		return "", 0, false
+	}
+
+	nameLength, _, err := readUint16(bs, start+26)
+	if err != nil || nameLength == 0 {
+		return "", 0, false
+	}
+
+	name, _, err := readString(bs, start+localFileHeaderLength, int(nameLength))
+	if err != nil {
+		return "", 0, false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] == 0x7F {
+			return "", 0, false
+		}
+	}
+
+	return name, flags, true
+}
+
+// salvagedRecordKey is what a central directory record has to agree
+// with a local header on to be taken for its entry's, since offsets
+// can't be trusted in a damaged archive.
+type salvagedRecordKey struct {
+	name           string
+	crc32          uint32
+	compressedSize uint64
+}
+
+// applySalvagedRecords scans bs for central directory records and
+// gives the entries found the name, comment, attributes and extra
+// field of the record that matches them.
+func (reader *Reader) applySalvagedRecords(found []Salvaged, bs []byte) {
+	records := map[salvagedRecordKey]*centralDirectoryRecord{}
+	signature := []byte("PK\x01\x02")
+	for i := 0; ; {
+		j := bytes.Index(bs[i:], signature)
+		if j < 0 {
+			break
+		}
+
+		cdr, next, err := parseCentralDirectoryRecord(bs, i+j)
+		if err != nil {
+			i += j + len(signature)
+			continue
+		}
+
+		records[salvagedRecordKey{cdr.fileName, cdr.crc32, cdr.compressedSize}] = cdr
+		i = next
+	}
+
+	for _, s := range found {
+		e := s.entry
+		if e == nil {
+			continue
+		}
+
+		cdr, ok := records[salvagedRecordKey{e.rawName, e.CRC32, e.CompressedSize}]
+		if !ok {
+			continue
+		}
+
+		e.Name = reader.decodeText(cdr.fileName, cdr.bitFlag, cdr.versionMadeBy, cdr.extraField, unicodePathExtraFieldID)
+		e.Comment = reader.decodeText(cdr.comment, cdr.bitFlag, cdr.versionMadeBy, cdr.extraField, unicodeCommentExtraFieldID)
+		e.CreatorVersion = cdr.versionMadeBy
+		e.ExternalAttrs = cdr.externalAttrs
+		e.Extra = cdr.extraField
+		e.record = cdr
+		e.setPreciseTimes()
+	}
+}
+
+// Repair writes to w a new archive of the entries it can salvage from
+// the damaged one of size bytes in r, as zip -FF does. It scans for
+// local headers rather than trusting the central directory, checks
+// each entry it finds against its CRC-32, and copies those that pass
+// without recompressing them. Encrypted entries can't be checked
+// without the password and are copied as they are. It returns what it
+// made of every header it found, including those it left out and why.
+func Repair(w io.Writer, r io.ReaderAt, size int64) ([]Salvaged, error) {
+	if size == 0 {
+		return nil, ErrEmptyFile
+	}
+
+	bs, err := readAt(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{}
+	found := reader.salvageEntries(r, bs)
+
+	zw := NewWriter(w)
+	if i, err := findEndOfCentralDirectory(bs); err == nil {
+		if eocd, err := parseEndOfCentralDirectory(bs, i); err == nil {
+			zw.comment = eocd.comment
+		}
+	}
+
+	for i := range found {
+		s := &found[i]
+		if s.Err != nil {
+			continue
+		}
+
+		if s.entry.Flags&encryptedFlag == 0 {
+			if err := s.entry.Verify(); err != nil {
+				s.Err = err
+				continue
+			}
+		}
+
+		if err := zw.CopyRaw(s.entry); err == ErrTooLarge {
+			s.Err = err
+			continue
+		} else if err != nil {
+			return found, err
+		}
+	}
+
+	return found, zw.Close()
+}
+
+// CRCFix is what RepairCRCs made of an entry.
+type CRCFix struct {
+	Name string
+	// Old is the CRC-32 the archive had for the entry and New the one
+	// its contents have, the same if it was right.
+	Old, New uint32
+	// Err is why the contents couldn't be read, in which case the entry
+	// was copied as it was.
+	Err error
+}
+
+// RepairCRCs writes to w a copy of the archive r with each entry's
+// CRC-32, in its local header, data descriptor and central directory
+// record alike, replaced by that of its decompressed contents, for
+// archives from writers that got them wrong. Data is copied without
+// recompressing it. Entries that can't be decompressed are copied as
+// they are, and so are encrypted ones, whose password check can hang
+// on the CRC-32 they have. It returns what it made of every entry.
+func RepairCRCs(w io.Writer, r *Reader) ([]CRCFix, error) {
+	zw := NewWriter(w)
+	zw.comment = r.comment
+
+	fixes := make([]CRCFix, len(r.entries))
+	for i, e := range r.entries {
+		fix := &fixes[i]
+		*fix = CRCFix{Name: e.Name, Old: e.CRC32, New: e.CRC32}
+
+		cdr := e.rawRecord()
+		if e.hasCRC32() && e.Flags&encryptedFlag == 0 {
+			var ce *ChecksumError
+			err := e.Verify()
+			switch {
+			case errors.As(err, &ce):
+				fix.New = ce.Actual
+				cdr.crc32 = ce.Actual
+			case err != nil:
+				fix.Err = err
+			}
+		}
+
+		if err := zw.copyRecord(e, cdr); err != nil {
+			return fixes, err
+		}
+	}
+
+	return fixes, zw.Close()
+}
@@ -0,0 +1,176 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	contents := map[string][]byte{
+		"a.txt": []byte("first\n"),
+		"b.txt": bytes.Repeat([]byte("second\n"), 100),
+		"c.txt": []byte("third\n"),
+	}
+
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, contents["a.txt"])
+		writeEntry(t, w, "b.txt", DeflateCompression, contents["b.txt"])
+		writeEntry(t, w, "c.txt", NoCompression, contents["c.txt"])
+	})
+	r := readArchive(t, bs)
+	b := lookupEntry(t, r, "b.txt")
+	bData, err := b.DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := bytes.Index(bs, []byte("PK\x01\x02"))
+
+	tests := []struct {
+		name   string
+		damage func(bs []byte) []byte
+		kept   []string
+	}{
+		{"intact", func(bs []byte) []byte { return bs }, []string{"a.txt", "b.txt", "c.txt"}},
+		{"no central directory", func(bs []byte) []byte { return bs[:cd+10] }, []string{"a.txt", "b.txt", "c.txt"}},
+		{"junk before", func(bs []byte) []byte { return append([]byte("junk"), bs...) }, []string{"a.txt", "b.txt", "c.txt"}},
+		{"corrupt data", func(bs []byte) []byte {
+			bs[bData+int64(b.CompressedSize)/2] ^= 0xFF
+			return bs
+		}, []string{"a.txt", "c.txt"}},
+		{"corrupt header", func(bs []byte) []byte {
+			binary.LittleEndian.PutUint32(bs[b.headerOffset+18:], 0xFFFFFFF0)
+			return bs[:cd]
+		}, []string{"a.txt", "c.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			damaged := test.damage(append([]byte(nil), bs...))
+
+			var out bytes.Buffer
+			found, err := Repair(&out, bytes.NewReader(damaged), int64(len(damaged)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var kept []string
+			for _, s := range found {
+				if s.Err == nil {
+					kept = append(kept, s.Name)
+				}
+			}
+			if len(kept) != len(test.kept) {
+				t.Fatalf("kept %v, want %v", kept, test.kept)
+			}
+
+			// What Repair keeps, reading with WithSalvage finds too.
+			repaired := readArchive(t, out.Bytes())
+			salvaged := readArchive(t, damaged, WithSalvage())
+			for i, name := range test.kept {
+				if kept[i] != name {
+					t.Fatalf("kept %v, want %v", kept, test.kept)
+				}
+
+				for _, r := range []*Reader{repaired, salvaged} {
+					got, err := lookupEntry(t, r, name).ReadAll()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, contents[name]) {
+						t.Errorf("%s: contents differ", name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRepairCRCs(t *testing.T) {
+	contents := bytes.Repeat([]byte("checked\n"), 100)
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "right.txt", DeflateCompression, contents)
+		writeEntry(t, w, "wrong.txt", DeflateCompression, contents)
+		fw, err := w.CreateEntry(&Entry{Name: "streamed.txt", Modified: testModified, Method: NoCompression})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+		writeEntry(t, w, "unreadable.txt", NoCompression, contents)
+	})
+
+	// record returns where name's central directory record is.
+	record := func(name string) int {
+		for i := bytes.Index(bs, []byte("PK\x01\x02")); ; {
+			n := int(binary.LittleEndian.Uint16(bs[i+28:]))
+			if string(bs[i+46:i+46+n]) == name {
+				return i
+			}
+			i += 46 + n + int(binary.LittleEndian.Uint16(bs[i+30:])) + int(binary.LittleEndian.Uint16(bs[i+32:]))
+		}
+	}
+
+	// Break the CRC-32 everywhere each entry has it, and give one a
+	// method nothing can decompress.
+	r := readArchive(t, bs)
+	for _, name := range []string{"wrong.txt", "streamed.txt"} {
+		e := lookupEntry(t, r, name)
+		binary.LittleEndian.PutUint32(bs[e.headerOffset+14:], 0xBAD)
+		binary.LittleEndian.PutUint32(bs[record(name)+16:], 0xBAD)
+		if e.Flags&dataDescriptorFlag != 0 {
+			data, err := e.DataOffset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			binary.LittleEndian.PutUint32(bs[data+int64(e.CompressedSize)+4:], 0xBAD)
+		}
+	}
+	unreadable := lookupEntry(t, r, "unreadable.txt")
+	binary.LittleEndian.PutUint16(bs[unreadable.headerOffset+8:], 0xF00D)
+	binary.LittleEndian.PutUint16(bs[record("unreadable.txt")+10:], 0xF00D)
+
+	var out bytes.Buffer
+	fixes, err := RepairCRCs(&out, readArchive(t, bs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"right.txt": false, "wrong.txt": true, "streamed.txt": true, "unreadable.txt": false}
+	if len(fixes) != len(want) {
+		t.Fatalf("got %d fixes, want %d", len(fixes), len(want))
+	}
+	for _, fix := range fixes {
+		if fixed := fix.New != fix.Old; fixed != want[fix.Name] {
+			t.Errorf("%s: fixed is %t, want %t", fix.Name, fixed, want[fix.Name])
+		}
+		if (fix.Err != nil) != (fix.Name == "unreadable.txt") {
+			t.Errorf("%s: got error %v", fix.Name, fix.Err)
+		}
+	}
+
+	repaired := readArchive(t, out.Bytes(), WithParseOptions(ParseOptions{Strict: true}))
+	for _, name := range []string{"right.txt", "wrong.txt", "streamed.txt"} {
+		e := lookupEntry(t, repaired, name)
+		got, err := e.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, contents) {
+			t.Errorf("%s: contents differ", name)
+		}
+		mismatches, err := e.VerifyHeaders()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mismatches) != 0 {
+			t.Errorf("%s: %v", name, mismatches)
+		}
+	}
+	e := lookupEntry(t, repaired, "unreadable.txt")
+	if _, err := e.ReadAll(); !errors.Is(err, ErrUnsupportedCompression) {
+		t.Errorf("got %v, want %v", err, ErrUnsupportedCompression)
+	}
+}
@@ -0,0 +1,27 @@
+package gozip
+
+import "time"
+
+// SetReproducible makes entries added from now on depend only on their
+// names, contents, methods and comments, so the same files written in
+// the same order make the same archive byte for byte wherever and
+// whenever they are. Each is modified at modified, with its MS-DOS time
+// in UTC rather than the local time zone, records no host system or
+// external attributes, and has no extra field but the extended
+// timestamp of modified. Encrypted entries never come out the same,
+// their salts and headers being random.
+func (w *Writer) SetReproducible(modified time.Time) {
+	modified = modified.UTC()
+	w.reproducible = &modified
+}
+
+// reproducibleEntry returns a copy of e as SetReproducible has entries
+// written.
+func reproducibleEntry(e *Entry, modified time.Time) *Entry {
+	r := *e
+	r.Modified = modified
+	r.CreatorVersion &= 0xFF
+	r.ExternalAttrs = 0
+	r.Extra = nil
+	return &r
+}
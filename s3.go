@@ -0,0 +1,134 @@
+package gozip
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Object is an object in Amazon S3, or a store that speaks its API.
+type S3Object struct {
+	Bucket string
+	Key    string
+	// Region is us-east-1 if empty.
+	Region string
+	// Endpoint, if set, is where requests go instead of AWS, such as
+	// http://localhost:9000 for MinIO. The bucket goes in the path.
+	Endpoint string
+
+	// Without credentials requests are anonymous, which is enough for
+	// public objects.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// emptySHA256 is the hash of the empty payload of a GET.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// NewS3ReaderAt returns an HTTPReaderAt for o, whose requests are
+// signed with AWS Signature Version 4 if o has credentials, so an
+// archive in S3 can be read without downloading it. Requests are made
+// with client.
+func NewS3ReaderAt(client *http.Client, o S3Object) (*HTTPReaderAt, error) {
+	if o.Region == "" {
+		o.Region = "us-east-1"
+	}
+
+	u := "https://" + o.Bucket + ".s3." + o.Region + ".amazonaws.com/" + s3Escape(o.Key)
+	if o.Endpoint != "" {
+		u = strings.TrimSuffix(o.Endpoint, "/") + "/" + o.Bucket + "/" + s3Escape(o.Key)
+	}
+
+	var sign func(req *http.Request) error
+	if o.AccessKeyID != "" {
+		sign = func(req *http.Request) error {
+			o.sign(req, time.Now())
+			return nil
+		}
+	}
+
+	return newHTTPReaderAt(client, u, sign)
+}
+
+// s3Escape escapes key for a URL path as Signature Version 4 wants it:
+// everything but unreserved characters, keeping slashes.
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sign adds the headers and Authorization Signature Version 4 calls for
+// to req, a GET, as of now.
+func (o *S3Object) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if o.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", o.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+
+	scope := date + "/" + o.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+o.SecretAccessKey), date)
+	key = hmacSHA256(key, o.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+o.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
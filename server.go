@@ -0,0 +1,82 @@
+package gozip
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// FileServer opens the archive at archivePath and returns a handler
+// that serves its contents, as Reader.Handler does. The archive stays
+// open for as long as the handler is used.
+func FileServer(archivePath string, opts ...Option) (http.Handler, error) {
+	r, err := Open(archivePath, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Handler(), nil
+}
+
+// Handler returns a handler that serves the archive's files as
+// http.FileServer serves a directory, with index.html for directories
+// that have one and listings for those that don't. Files get an ETag
+// made from their CRC-32 and size, so clients can revalidate them
+// without fetching them again, and a Content-Type from their extension
+// or contents. Ranges of stored entries are read straight out of the
+// archive; other entries are decompressed from their start to get to
+// the range asked for, and checked against their CRC-32 when read to
+// the end.
+func (r *Reader) Handler() http.Handler {
+	return &fileServer{r: r, dirs: http.FileServer(http.FS(r))}
+}
+
+type fileServer struct {
+	r *Reader
+	// dirs serves what isn't a file: redirects, listings and 404s.
+	dirs http.Handler
+}
+
+func (s *fileServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	n, err := s.r.lookup("open", name)
+	if err == nil && n.dir && strings.HasSuffix(req.URL.Path, "/") {
+		if index, err := s.r.lookup("open", path.Join(name, "index.html")); err == nil && !index.dir {
+			n = index
+		}
+	}
+	if err != nil || n.dir {
+		s.dirs.ServeHTTP(rw, req)
+		return
+	}
+
+	e := n.entry
+	if e.hasCRC32() {
+		rw.Header().Set("Etag", fmt.Sprintf(`"%08x-%x"`, e.CRC32, e.UncompressedSize))
+	}
+
+	// Stored data is served straight from the archive, but only as much
+	// of it as the entry takes up there. Anything else goes through
+	// Open, which checks the limits and the CRC-32.
+	var content io.ReadSeeker
+	if e.Method == NoCompression && !e.IsEncrypted() && e.CompressedSize == e.UncompressedSize {
+		offset, err := e.DataOffset()
+		if err != nil {
+			http.Error(rw, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		content = io.NewSectionReader(e.r, offset, int64(e.CompressedSize))
+	} else {
+		f := &fsFile{node: n}
+		defer f.Close()
+		content = f
+	}
+
+	http.ServeContent(rw, req, path.Base(n.name), e.Modified, content)
+}
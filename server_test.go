@@ -0,0 +1,142 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "index.html", DeflateCompression, []byte("<html><body>hi</body></html>"))
+		writeEntry(t, w, "app.css", NoCompression, []byte("body{color:red}0123456789"))
+		writeEntry(t, w, "data.txt", DeflateCompression, []byte(strings.Repeat("0123456789", 100)))
+	})
+	srv := httptest.NewServer(readArchive(t, bs).Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		path, rangeHeader string
+		status            int
+		contentType, body string
+	}{
+		{"/", "", http.StatusOK, "text/html; charset=utf-8", "<html><body>hi</body></html>"},
+		{"/app.css", "", http.StatusOK, "text/css; charset=utf-8", "body{color:red}0123456789"},
+		{"/app.css", "bytes=5-9", http.StatusPartialContent, "text/css; charset=utf-8", "color"},
+		{"/data.txt", "bytes=12-14", http.StatusPartialContent, "text/plain; charset=utf-8", "234"},
+		{"/missing", "", http.StatusNotFound, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path+" "+test.rangeHeader, func(t *testing.T) {
+			req, err := http.NewRequest("GET", srv.URL+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.rangeHeader != "" {
+				req.Header.Set("Range", test.rangeHeader)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != test.status {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, test.status)
+			}
+			if test.status == http.StatusNotFound {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != test.contentType {
+				t.Errorf("got Content-Type %q, want %q", got, test.contentType)
+			}
+			if string(body) != test.body {
+				t.Errorf("got body %q, want %q", body, test.body)
+			}
+			if resp.Header.Get("Etag") == "" {
+				t.Error("no ETag")
+			}
+		})
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", DeflateCompression, []byte("contents"))
+	})
+	srv := httptest.NewServer(readArchive(t, bs).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/a.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", resp.Header.Get("Etag"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+}
+
+func TestHandlerOverstatedSize(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, []byte("public"))
+		writeEntry(t, w, "b.txt", NoCompression, []byte("SECRET"))
+	})
+	// a.txt claims to be bigger than the data it takes up.
+	cd := bytes.Index(bs, []byte("PK\x01\x02"))
+	binary.LittleEndian.PutUint32(bs[cd+24:], 100)
+
+	srv := httptest.NewServer(readArchive(t, bs).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// The response is cut short of the length claimed, so the error
+	// reading it is expected.
+	body, _ := io.ReadAll(resp.Body)
+
+	if bytes.Contains(body, []byte("SECRET")) {
+		t.Fatalf("served another entry's data: %q", body)
+	}
+}
+
+func TestFSChecksum(t *testing.T) {
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", DeflateCompression, []byte("contents"))
+	})
+	cd := bytes.Index(bs, []byte("PK\x01\x02"))
+	bs[cd+16] ^= 0xFF
+
+	_, err := fs.ReadFile(readArchive(t, bs), "a.txt")
+	var ce *ChecksumError
+	if !errors.As(err, &ce) {
+		t.Fatalf("got %v, want a ChecksumError", err)
+	}
+}
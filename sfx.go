@@ -0,0 +1,17 @@
+package gozip
+
+// Prefix returns how many bytes come before the archive's first entry,
+// such as the stub of a self-extracting archive. Offsets in the archive
+// may be from the start of the file or from the end of the prefix, and
+// are read either way.
+func (r *Reader) Prefix() int64 {
+	return r.prefix
+}
+
+// SetOffset tells w that n bytes, such as a self-extracting stub, were
+// written before the archive, so offsets in it are from the start of
+// the file as zip -A makes them. It must be called before anything is
+// written.
+func (w *Writer) SetOffset(n int64) {
+	w.w.count = n
+}
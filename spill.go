@@ -0,0 +1,67 @@
+package gozip
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// spillSize is how much of an entry CompressReader holds in memory
+// before the rest goes to a temporary file.
+const spillSize = 4 << 20
+
+// spillBuffer holds what is written to it in memory up to spillSize
+// bytes and in a temporary file past that.
+type spillBuffer struct {
+	buf  bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func (b *spillBuffer) Write(p []byte) (int, error) {
+	if b.file == nil && b.buf.Len()+len(p) > spillSize {
+		f, err := os.CreateTemp("", "gozip-*")
+		if err != nil {
+			return 0, err
+		}
+		b.file = f
+		if _, err := b.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.buf.Write(p)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// WriteTo writes everything written to b to w. It can only be called
+// once.
+func (b *spillBuffer) WriteTo(w io.Writer) (int64, error) {
+	if b.file == nil {
+		return b.buf.WriteTo(w)
+	}
+
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, b.file)
+}
+
+// Close removes the temporary file, if there is one.
+func (b *spillBuffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+
+	f := b.file
+	b.file = nil
+	f.Close()
+	return os.Remove(f.Name())
+}
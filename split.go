@@ -0,0 +1,278 @@
+package gozip
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrSplitArchive  = fmt.Errorf("Archive is split into volumes")
+	ErrMissingVolume = fmt.Errorf("Archive volume missing")
+	ErrSplitTooSmall = fmt.Errorf("Split size too small to hold a header")
+)
+
+const (
+	// spanningSignature starts the first volume of a split archive.
+	spanningSignature = 0x08074b50
+	// singleVolumeSignature replaces it when the archive turned out to
+	// fit in one volume.
+	singleVolumeSignature = 0x30304b50
+)
+
+// volumeName returns the name zip gives the volume numbered disk, from
+// 0, of the split archive whose last volume is path: path with its
+// extension replaced by .z01, .z02 and so on.
+func volumeName(path string, disk uint32) string {
+	return fmt.Sprintf("%s.z%02d", strings.TrimSuffix(path, filepath.Ext(path)), disk+1)
+}
+
+// volumes reads the volumes of a split archive as though they were one
+// file, each following the one before.
+type volumes struct {
+	parts  []SizedReaderAt
+	starts []int64
+	size   int64
+}
+
+func newVolumes(parts []SizedReaderAt) *volumes {
+	v := &volumes{parts: parts}
+	for _, part := range parts {
+		v.starts = append(v.starts, v.size)
+		v.size += part.Size()
+	}
+
+	return v
+}
+
+func (v *volumes) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= v.size {
+			return n, io.EOF
+		}
+
+		i := sort.Search(len(v.starts), func(i int) bool { return v.starts[i] > pos }) - 1
+		chunk := p[n:]
+		if rest := v.starts[i] + v.parts[i].Size() - pos; int64(len(chunk)) > rest {
+			chunk = chunk[:rest]
+		}
+
+		m, err := v.parts[i].ReadAt(chunk, pos-v.starts[i])
+		n += m
+		if err != nil && (err != io.EOF || m < len(chunk)) {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// NewSplitReader parses the archive split into parts, its volumes in
+// order: name.z01, name.z02 and so on, then name.zip. Open finds them
+// itself when given the last.
+func NewSplitReader(parts []SizedReaderAt, opts ...Option) (*Reader, error) {
+	v := newVolumes(parts)
+	return NewReader(v, v.size, opts...)
+}
+
+// openSplit opens the split archive whose last volume is at path,
+// finding the others next to it.
+func openSplit(path string, opts ...Option) (*Reader, error) {
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	for disk := uint32(0); ; disk++ {
+		f, err := os.Open(volumeName(path, disk))
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	last, err := os.Open(path)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	files = append(files, last)
+
+	parts := make([]SizedReaderAt, len(files))
+	for i, f := range files {
+		info, err := f.Stat()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		parts[i] = io.NewSectionReader(f, 0, info.Size())
+	}
+
+	r, err := NewSplitReader(parts, opts...)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	r.volumes = files
+	return r, nil
+}
+
+// splitWriter writes the volumes of a split archive, going on to the
+// next when one is full. Each is named as zip names them, and the last
+// is renamed to the archive's own name when done.
+type splitWriter struct {
+	path string
+	size int64
+
+	f       *os.File
+	disk    uint32
+	written int64
+}
+
+// CreateSplit returns a Writer that writes an archive to path split
+// into volumes of at most size bytes, as zip -s does: all but the last
+// are named for path with the extension .z01, .z02 and so on, and the
+// last is path itself. Headers and central directory records are never
+// split between volumes. Close closes the volumes too.
+func CreateSplit(path string, size int64) (*Writer, error) {
+	s := &splitWriter{path: path, size: size}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	var b byteWriter
+	b.uint32(spanningSignature)
+	if err := s.keep(b.Len()); err != nil {
+		s.f.Close()
+		return nil, err
+	}
+	if _, err := s.Write(b.Bytes()); err != nil {
+		s.f.Close()
+		return nil, err
+	}
+
+	w := NewWriter(s)
+	w.split = s
+	return w, nil
+}
+
+func (s *splitWriter) open() error {
+	f, err := os.Create(volumeName(s.path, s.disk))
+	if err != nil {
+		return err
+	}
+
+	s.f, s.written = f, 0
+	return nil
+}
+
+func (s *splitWriter) next() error {
+	if s.disk == 0xFFFE {
+		return ErrTooLarge
+	}
+
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+
+	s.disk++
+	return s.open()
+}
+
+func (s *splitWriter) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if s.written == s.size {
+			if err := s.next(); err != nil {
+				return n, err
+			}
+		}
+
+		chunk := p[n:]
+		if rest := s.size - s.written; int64(len(chunk)) > rest {
+			chunk = chunk[:rest]
+		}
+
+		m, err := s.f.Write(chunk)
+		n += m
+		s.written += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// keep goes on to the next volume if the n bytes about to be written,
+// which can't be split, don't fit in what is left of this one.
+func (s *splitWriter) keep(n int) error {
+	if int64(n) > s.size {
+		return ErrSplitTooSmall
+	}
+
+	if s.written+int64(n) > s.size {
+		return s.next()
+	}
+
+	return nil
+}
+
+// close finishes the last volume and gives it the archive's name. An
+// archive that fit in one volume is marked as not split after all.
+func (s *splitWriter) close() error {
+	if s.disk == 0 {
+		var b byteWriter
+		b.uint32(singleVolumeSignature)
+		if _, err := s.f.WriteAt(b.Bytes(), 0); err != nil {
+			s.f.Close()
+			return err
+		}
+	}
+
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(volumeName(s.path, s.disk), s.path); err != nil {
+		return err
+	}
+
+	// Volumes left over from an archive of the same name split into
+	// more would be taken for part of this one.
+	for disk := s.disk + 1; os.Remove(volumeName(s.path, disk)) == nil; disk++ {
+	}
+
+	return nil
+}
+
+// keep is splitWriter.keep for the Writer, which does nothing unless
+// it is writing a split archive.
+func (w *Writer) keep(n int) error {
+	if w.split == nil {
+		return nil
+	}
+
+	return w.split.keep(n)
+}
+
+// position returns the volume w is writing and where in it.
+func (w *Writer) position() (uint32, int64) {
+	if w.split == nil {
+		return 0, w.w.count
+	}
+
+	return w.split.disk, w.split.written
+}
@@ -0,0 +1,97 @@
+package gozip
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	contents := map[string][]byte{
+		"a.txt": bytes.Repeat([]byte("first\n"), 50),
+		"b.bin": testRandom(1000),
+		"c.txt": []byte("third\n"),
+	}
+	names := []string{"a.txt", "b.bin", "c.txt"}
+
+	tests := []struct {
+		name    string
+		size    int64
+		volumes int
+		remove  int
+		err     error
+	}{
+		{"one volume", 1 << 20, 1, -1, nil},
+		{"several volumes", 256, 7, -1, nil},
+		{"missing volume", 256, 7, 1, ErrMissingVolume},
+		{"too small", 16, 0, -1, ErrSplitTooSmall},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "archive.zip")
+			w, err := CreateSplit(path, test.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range names {
+				err = w.WriteEntry(&Entry{Name: name, Modified: testModified, Method: NoCompression}, contents[name])
+				if err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = w.Close()
+			}
+			if test.volumes == 0 {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("got %v writing, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for disk := uint32(0); disk < uint32(test.volumes)-1; disk++ {
+				info, err := os.Stat(volumeName(path, disk))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Size() > test.size {
+					t.Errorf("volume %d is %d bytes, over %d", disk, info.Size(), test.size)
+				}
+			}
+			if _, err := os.Stat(volumeName(path, uint32(test.volumes)-1)); err == nil {
+				t.Errorf("more than %d volumes", test.volumes)
+			}
+
+			if test.remove >= 0 {
+				if err := os.Remove(volumeName(path, uint32(test.remove))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r, err := Open(path)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			defer r.Close()
+
+			for _, name := range names {
+				got, err := lookupEntry(t, r, name).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, contents[name]) {
+					t.Errorf("%s: contents differ", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package gozip
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ParseOptions are how strictly a Reader holds an archive to the
+// specification. By default it puts up with whatever real archivers get
+// wrong as long as the entries can still be found and read.
+type ParseOptions struct {
+	// Strict fails to open archives that break the specification in
+	// any way the Reader can tell: versions and flags it doesn't
+	// define, invalid dates, malformed names and extra fields, local
+	// headers that disagree with the central directory, and counts,
+	// sizes and trailing data that don't add up. Archives without a
+	// central directory aren't read at all.
+	Strict bool
+	// LocalHeaders finds the entries by walking the local headers from
+	// the front of the archive, as streaming readers do, rather than
+	// through the central directory, which is then ignored, Strict
+	// along with it. Reader.CompareParses says where the two disagree.
+	LocalHeaders bool
+}
+
+// WithParseOptions sets how strictly the archive is parsed.
+func WithParseOptions(opts ParseOptions) Option {
+	return func(r *Reader) {
+		r.parse = opts
+	}
+}
+
+// ViolationError is how a strict Reader fails on an archive that
+// breaks the specification.
+type ViolationError struct {
+	// Entry is the name of the entry at fault, or empty if it is the
+	// archive as a whole.
+	Entry     string
+	Violation string
+}
+
+func (e *ViolationError) Error() string {
+	if e.Entry == "" {
+		return "Invalid archive: " + e.Violation
+	}
+
+	return "Invalid entry " + e.Entry + ": " + e.Violation
+}
+
+// reservedFlags are the bits of the general purpose flags the
+// specification leaves unused or reserves.
+const reservedFlags = 0xD780
+
+// maxVersion is the newest version of the specification, 6.3, that a
+// version field can give.
+const maxVersion = 63
+
+// checkStrict checks the archive of size bytes whose central directory
+// is cd, and its entries, against the specification.
+func checkStrict(cd *centralDirectory, entries []*Entry, size int64) error {
+	eocd := cd.eocd
+	if trailing := size - cd.eocdOffset - endOfCentralDirectoryLength - int64(len(eocd.comment)); trailing != 0 {
+		return &ViolationError{Violation: fmt.Sprintf("%d bytes after the end of central directory record", trailing)}
+	}
+
+	if cd.disks == nil && eocd.diskEntries != eocd.entries {
+		return &ViolationError{Violation: fmt.Sprintf("%d entries on the only disk of %d in all", eocd.diskEntries, eocd.entries)}
+	}
+
+	var cdSize uint64
+	for _, cdr := range cd.records {
+		cdSize += uint64(centralDirectoryRecordLength + len(cdr.fileName) + len(cdr.extraField) + len(cdr.comment))
+	}
+	if cdSize != eocd.centralDirectorySize {
+		return &ViolationError{Violation: fmt.Sprintf("central directory is %d bytes, not %d", cdSize, eocd.centralDirectorySize)}
+	}
+
+	for _, e := range entries {
+		if violation := recordViolation(e.record); violation != "" {
+			return &ViolationError{Entry: e.Name, Violation: violation}
+		}
+
+		mismatches, err := e.VerifyHeaders()
+		if errors.Is(err, ErrNotZip) {
+			return &ViolationError{Entry: e.Name, Violation: "no local header where the central directory says"}
+		}
+		if errors.Is(err, ErrNoDataDescriptor) {
+			return &ViolationError{Entry: e.Name, Violation: "no data descriptor after its data"}
+		}
+		if err != nil {
+			return err
+		}
+		if len(mismatches) > 0 {
+			return &ViolationError{Entry: e.Name, Violation: mismatches[0].String()}
+		}
+	}
+
+	return nil
+}
+
+// recordViolation returns how cdr breaks the specification, or "" if
+// it doesn't.
+func recordViolation(cdr *centralDirectoryRecord) string {
+	switch {
+	case cdr.versionNeeded > maxVersion:
+		return fmt.Sprintf("version needed to extract is %d", cdr.versionNeeded)
+	case cdr.versionMadeBy&0xFF > maxVersion:
+		return fmt.Sprintf("version made by is %d", cdr.versionMadeBy&0xFF)
+	case cdr.bitFlag&reservedFlags != 0:
+		return fmt.Sprintf("reserved flags %#04x are set", cdr.bitFlag&reservedFlags)
+	case !validMsdosTime(cdr.modifiedDate, cdr.modifiedTime):
+		return fmt.Sprintf("invalid MS-DOS date %#04x and time %#04x", cdr.modifiedDate, cdr.modifiedTime)
+	case cdr.fileName == "":
+		return "empty name"
+	case strings.Contains(cdr.fileName, "\\"):
+		return "name has backslashes"
+	case strings.HasPrefix(cdr.fileName, "/"):
+		return "name is absolute"
+	case cdr.bitFlag&utf8Flag != 0 && !utf8.ValidString(cdr.fileName):
+		return "name is flagged UTF-8 but isn't"
+	case cdr.bitFlag&utf8Flag != 0 && !utf8.ValidString(cdr.comment):
+		return "comment is flagged UTF-8 but isn't"
+	}
+
+	if _, err := ParseExtraFields(cdr.extraField); err != nil {
+		return "malformed extra field"
+	}
+
+	return ""
+}
+
+// validMsdosTime reports whether d and t are a real MS-DOS date and
+// time.
+func validMsdosTime(d, t uint16) bool {
+	year := int(d>>9) + 1980
+	month := int(d>>5) & 0x0F
+	day := int(d & 0x1F)
+	if month < 1 || month > 12 || day < 1 {
+		return false
+	}
+
+	// The day after the last of the month is the first of the next.
+	if time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Day() != day {
+		return false
+	}
+
+	return t>>11 < 24 && (t>>5)&0x3F < 60 && t&0x1F < 30
+}
+
+// headerMismatches returns how the local header lfh disagrees with the
+// central directory record cdr of the same entry. Entries with data
+// descriptors may leave their CRC-32 and sizes zero in the local header.
+func headerMismatches(cdr *centralDirectoryRecord, lfh *localFileHeader) []Mismatch {
+	var mismatches []Mismatch
+	mismatch := func(field string, local, central interface{}) {
+		mismatches = append(mismatches, Mismatch{Field: field, Local: fmt.Sprint(local), Central: fmt.Sprint(central)})
+	}
+
+	if lfh.fileName != cdr.fileName {
+		mismatch("name", fmt.Sprintf("%q", lfh.fileName), fmt.Sprintf("%q", cdr.fileName))
+	}
+	if lfh.version != cdr.versionNeeded {
+		mismatch("version needed", lfh.version, cdr.versionNeeded)
+	}
+	if lfh.bitFlag != cdr.bitFlag {
+		mismatch("flags", fmt.Sprintf("%#04x", lfh.bitFlag), fmt.Sprintf("%#04x", cdr.bitFlag))
+	}
+	if lfh.compression != cdr.compression {
+		mismatch("method", lfh.compression, cdr.compression)
+	}
+	if !lfh.lastModified.Equal(cdr.lastModified) {
+		mismatch("modified time", lfh.lastModified.Format(time.RFC3339), cdr.lastModified.Format(time.RFC3339))
+	}
+
+	descriptor := lfh.bitFlag&dataDescriptorFlag != 0
+	if lfh.crc32 != cdr.crc32 && !(descriptor && lfh.crc32 == 0) {
+		mismatch("CRC-32", fmt.Sprintf("%08x", lfh.crc32), fmt.Sprintf("%08x", cdr.crc32))
+	}
+	if lfh.compressedSize != cdr.compressedSize && !(descriptor && lfh.compressedSize == 0) {
+		mismatch("compressed size", lfh.compressedSize, cdr.compressedSize)
+	}
+	if lfh.uncompressedSize != cdr.uncompressedSize && !(descriptor && lfh.uncompressedSize == 0) {
+		mismatch("uncompressed size", lfh.uncompressedSize, cdr.uncompressedSize)
+	}
+
+	return mismatches
+}
+
+// localHeader reads the entry's local header, without its data.
+func (e *Entry) localHeader() (*localFileHeader, error) {
+	lfh, err := e.readLocalHeader()
+	if err != nil {
+		return nil, formatError(e.headerOffset, "local file header", err)
+	}
+
+	return lfh, nil
+}
+
+func (e *Entry) readLocalHeader() (*localFileHeader, error) {
+	fixed, err := readAt(e.r, e.headerOffset, localFileHeaderLength)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNameLength, i, err := readUint16(fixed, 26)
+	if err != nil {
+		return nil, err
+	}
+	extraFieldLength, _, err := readUint16(fixed, i)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := readAt(e.r, e.headerOffset, localFileHeaderLength+int64(fileNameLength)+int64(extraFieldLength))
+	if err != nil {
+		return nil, err
+	}
+
+	lfh, _, err := parseLocalFileHeaderFields(bs, 0)
+	return lfh, err
+}
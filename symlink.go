@@ -0,0 +1,85 @@
+package gozip
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsSymlink reports whether the entry is a symbolic link, which zip
+// stores as an entry whose contents are the path it points to.
+func (e *Entry) IsSymlink() bool {
+	return e.Mode()&fs.ModeSymlink != 0
+}
+
+// Linkname returns the path a symlink entry points to.
+func (e *Entry) Linkname() (string, error) {
+	target, err := e.ReadAll()
+	if err != nil {
+		return "", err
+	}
+
+	return string(target), nil
+}
+
+// WithoutSymlinks makes Extract write symlink entries out as regular
+// files holding the path they point to, as archivers do on systems
+// without symlinks, instead of creating the links.
+func WithoutSymlinks() Option {
+	return func(r *Reader) {
+		r.noSymlinks = true
+	}
+}
+
+// extractSymlink creates the symlink entry at path, under dir. Links
+// that are absolute or lead out of dir would let later entries be
+// written outside it through them, so they are refused with
+// ErrUnsafePath. The target is resolved from where the link's directory
+// really is, since .. from a symlinked directory leaves the directory
+// it points to rather than the one it is in.
+func (e *Entry) extractSymlink(dir, path string) error {
+	target, err := e.Linkname()
+	if err != nil {
+		return err
+	}
+
+	target = filepath.Clean(filepath.FromSlash(target))
+	if filepath.IsAbs(target) || filepath.VolumeName(target) != "" || strings.HasPrefix(target, string(filepath.Separator)) {
+		return ErrUnsafePath
+	}
+
+	root, err := realPath(dir)
+	if err != nil {
+		return err
+	}
+	parent, err := realPath(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(root, filepath.Join(parent, target))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ErrUnsafePath
+	}
+
+	// Replace whatever is there already, as extracting a file would,
+	// unless it is a directory.
+	if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return os.Symlink(target, path)
+}
+
+// realPath returns the absolute path to path with symlinks resolved.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
@@ -1 +1,12 @@
 zip test/test.zip $(find test -name "*.text")
+# Entries followed by data descriptors, as Info-ZIP and Python's zipfile
+# write them.
+zip -fd test/descriptors.zip test/hello.text test/large.text
+python3 -c '
+import sys, zipfile
+with zipfile.ZipFile(sys.stdout.buffer, "w", zipfile.ZIP_DEFLATED) as z:
+    for name in sys.argv[1:]:
+        z.write(name)
+' test/hello.text test/large.text | cat > test/descriptors-python.zip
+# A bzip2 entry, method 12.
+zip -Z bzip2 test/bzip2.zip test/large.text
@@ -0,0 +1,98 @@
+package gozip
+
+import (
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrOverlappingEntries = fmt.Errorf("Entries overlap")
+	ErrDuplicateEntry     = fmt.Errorf("Duplicate entry name")
+)
+
+// checkOverlaps makes sure no two entries' local headers and data
+// share bytes, and none run into the central directory at end. Zip
+// bombs reuse one entry's compressed data for many entries to get
+// around the limit deflate puts on a single entry's ratio, which no
+// archive made honestly does. An entry takes at least its local header,
+// with the name from the central directory, and its compressed data.
+func checkOverlaps(entries []*Entry, end int64) error {
+	sorted := make([]*Entry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].headerOffset < sorted[j].headerOffset
+	})
+
+	for i, e := range sorted {
+		next := end
+		if i+1 < len(sorted) {
+			next = sorted[i+1].headerOffset
+		}
+
+		size := uint64(localFileHeaderLength) + uint64(len(e.rawName)) + e.CompressedSize
+		if size > uint64(next-e.headerOffset) {
+			return ErrOverlappingEntries
+		}
+	}
+
+	return nil
+}
+
+// DuplicatePolicy is what a Reader does with entries that have the same
+// name as another.
+type DuplicatePolicy int
+
+const (
+	// KeepDuplicates keeps every entry.
+	KeepDuplicates DuplicatePolicy = iota
+	// RejectDuplicates fails to open the archive with ErrDuplicateEntry.
+	RejectDuplicates
+	// KeepFirstDuplicate keeps only the first entry with a name.
+	KeepFirstDuplicate
+	// KeepLastDuplicate keeps only the last entry with a name, which is
+	// the one extracting every entry in order leaves on disk.
+	KeepLastDuplicate
+)
+
+// WithDuplicates sets what to do with entries whose names repeat. By
+// default they are all kept.
+func WithDuplicates(policy DuplicatePolicy) Option {
+	return func(r *Reader) {
+		r.duplicates = policy
+	}
+}
+
+// applyDuplicates returns entries with the policy applied, in the same
+// order as they were.
+func applyDuplicates(entries []*Entry, policy DuplicatePolicy) ([]*Entry, error) {
+	if policy == KeepDuplicates {
+		return entries, nil
+	}
+
+	// keep is the index of the entry kept for each name.
+	keep := map[string]int{}
+	for i, e := range entries {
+		if _, ok := keep[e.Name]; ok {
+			if policy == RejectDuplicates {
+				return nil, ErrDuplicateEntry
+			}
+			if policy == KeepFirstDuplicate {
+				continue
+			}
+		}
+		keep[e.Name] = i
+	}
+
+	if len(keep) == len(entries) {
+		return entries, nil
+	}
+
+	kept := make([]*Entry, 0, len(keep))
+	for i, e := range entries {
+		if keep[e.Name] == i {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept, nil
+}
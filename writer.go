@@ -0,0 +1,862 @@
+package gozip
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+const utf8Flag = 0x800
+
+var (
+	ErrWriterClosed     = fmt.Errorf("Writer is closed")
+	ErrTooLarge         = fmt.Errorf("Archive too large without ZIP64")
+	ErrInvalidLevel     = fmt.Errorf("Invalid compression level")
+	ErrInvalidAlignment = fmt.Errorf("Invalid alignment")
+)
+
+// Writer builds an archive: a local header and data per entry followed
+// by the central directory and end of central directory record, which
+// Close writes.
+type Writer struct {
+	w          *countWriter
+	records    []*centralDirectoryRecord
+	current    *entryWriter
+	closed     bool
+	encryption Encryption
+	password   []byte
+	comment    string
+	level      int
+	progress   *progress
+	// reproducible is the time SetReproducible gives entries, if set.
+	reproducible *time.Time
+	// alignment is what SetAlignment aligns stored entries' data to.
+	alignment int
+
+	// file is what Append opened, and existing is how many of records
+	// came from the archive already in it.
+	file     *os.File
+	existing int
+	// zip64 is set by Append for archives that already have a ZIP64
+	// end of central directory record, which Close writes again.
+	zip64 bool
+
+	// split is what CreateSplit writes the volumes with.
+	split *splitWriter
+}
+
+// Encryption is how the Writer encrypts entries.
+type Encryption int
+
+const (
+	NoEncryption Encryption = iota
+	// AESEncryption is WinZip's AES-256, AE-2.
+	AESEncryption
+	// ZipCryptoEncryption is the traditional PKWARE cipher. It is weak
+	// and only for readers that support nothing else.
+	ZipCryptoEncryption
+)
+
+// SetLevel sets the level entries added from now on are deflated at,
+// from 0 for none to 9 for the smallest output, with -1 for the
+// default and -2 for Huffman coding only, as in compress/flate. Other
+// methods ignore it.
+func (w *Writer) SetLevel(level int) error {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return ErrInvalidLevel
+	}
+
+	w.level = level
+	return nil
+}
+
+// SetEncryption encrypts entries added from now on with password.
+func (w *Writer) SetEncryption(encryption Encryption, password string) {
+	w.encryption = encryption
+	w.password = []byte(password)
+}
+
+// encrypt marks cdr, whose data is about to be written, as encrypted.
+// AES entries claim method 99 and move the real method to the AES
+// extra field.
+func (w *Writer) encrypt(cdr *centralDirectoryRecord) {
+	switch w.encryption {
+	case AESEncryption:
+		extraField := append([]byte{}, cdr.extraField...)
+		cdr.extraField = append(extraField, aesExtraFieldFor(cdr.compression)...)
+		cdr.compression = aesCompression
+		cdr.versionNeeded = 51
+		cdr.bitFlag |= encryptedFlag
+	case ZipCryptoEncryption:
+		cdr.bitFlag |= encryptedFlag
+	}
+}
+
+// newEncrypter returns a writer that encrypts to w what is written to
+// it as the entry cdr's data, or nil if entries aren't encrypted.
+func (w *Writer) newEncrypter(dst io.Writer, cdr *centralDirectoryRecord) (io.WriteCloser, error) {
+	switch w.encryption {
+	case AESEncryption:
+		return newAESWriter(dst, w.password)
+	case ZipCryptoEncryption:
+		check := byte(cdr.crc32 >> 24)
+		if cdr.bitFlag&dataDescriptorFlag != 0 {
+			check = byte(cdr.modifiedTime >> 8)
+		}
+		return newZipCryptoWriter(dst, w.password, check)
+	}
+
+	return nil, nil
+}
+
+type countWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.count += int64(n)
+	return n, err
+}
+
+// NewWriter returns a Writer that writes an archive to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: &countWriter{w: w}, level: flate.DefaultCompression}
+}
+
+// goTimeToMsdosTime is the inverse of msdosTimeToGoTime, which reads
+// MS-DOS times as local time.
+func goTimeToMsdosTime(t time.Time) (uint16, uint16) {
+	return msdosTime(t.Local())
+}
+
+// msdosTime returns t's date and time as they are in its location.
+func msdosTime(t time.Time) (uint16, uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, t.Location())
+	}
+
+	d := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tm := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return d, tm
+}
+
+func versionNeeded(compression Compression) uint16 {
+	switch compression {
+	case NoCompression:
+		return 10
+	case ZstdCompression:
+		return 63
+	}
+
+	return 20
+}
+
+func newCentralDirectoryRecord(e *Entry) *centralDirectoryRecord {
+	var bitFlag uint16
+	if (!isASCII(e.Name) && utf8.ValidString(e.Name)) || (!isASCII(e.Comment) && utf8.ValidString(e.Comment)) {
+		bitFlag |= utf8Flag
+	}
+
+	modified := e.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	// MS-DOS times lose the time zone and odd seconds, so record the
+	// modification time in an extended timestamp too, unless e has one,
+	// and in the NTFS extra field if it is to less than a second.
+	extraField := e.Extra
+	var fields []ExtraField
+	if _, ok := findExtraField(extraField, extendedTimestampExtraFieldID); !ok {
+		ts := ExtendedTimestamp{Modified: modified}
+		fields = append(fields, ts.ExtraField())
+	}
+	if _, ok := findExtraField(extraField, ntfsExtraFieldID); !ok && modified.Nanosecond() != 0 {
+		nt := NTFSTimes{Modified: modified, Accessed: e.Accessed, Created: e.Created}
+		fields = append(fields, nt.ExtraField())
+	}
+	if len(fields) > 0 {
+		var b byteWriter
+		b.Write(extraField)
+		for _, f := range fields {
+			b.uint16(f.ID)
+			b.uint16(uint16(len(f.Data)))
+			b.Write(f.Data)
+		}
+		extraField = b.Bytes()
+	}
+
+	versionMadeBy := e.CreatorVersion
+	if versionMadeBy == 0 {
+		versionMadeBy = 20
+	}
+
+	// Directories have no contents to compress.
+	method := e.Method
+	if e.IsDir() {
+		method = NoCompression
+	}
+
+	lmDate, lmTime := goTimeToMsdosTime(modified)
+	return &centralDirectoryRecord{
+		versionMadeBy: versionMadeBy,
+		versionNeeded: versionNeeded(method),
+		bitFlag:       bitFlag,
+		compression:   method,
+		lastModified:  modified,
+		modifiedDate:  lmDate,
+		modifiedTime:  lmTime,
+		fileName:      e.Name,
+		extraField:    extraField,
+		comment:       e.Comment,
+		externalAttrs: e.ExternalAttrs,
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+type byteWriter struct {
+	bytes.Buffer
+}
+
+func (b *byteWriter) uint16(v uint16) {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *byteWriter) uint32(v uint32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (b *byteWriter) uint64(v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	b.Write(buf[:])
+}
+
+func (w *Writer) writeLocalFileHeader(cdr *centralDirectoryRecord) error {
+	// The padding that aligns the data depends on where the header
+	// ends up, so room is kept for the most it could need.
+	extraField := cdr.extraField
+	if cdr.localExtraField != nil {
+		extraField = cdr.localExtraField
+	}
+
+	compressedSize, uncompressedSize := uint32(cdr.compressedSize), uint32(cdr.uncompressedSize)
+	sizes64 := cdr.compressedSize >= 0xFFFFFFFF || cdr.uncompressedSize >= 0xFFFFFFFF
+	if sizes64 {
+		compressedSize, uncompressedSize = 0xFFFFFFFF, 0xFFFFFFFF
+		extraField = zip64LocalExtraField(cdr, extraField)
+		if len(extraField) > 0xFFFF {
+			return ErrTooLarge
+		}
+	}
+
+	length := localFileHeaderLength + len(cdr.fileName) + len(extraField)
+	aligned := w.aligns(cdr)
+	reserve := length
+	if aligned {
+		reserve += alignmentFieldLength + w.alignment - 1
+	}
+	if err := w.keep(reserve); err != nil {
+		return err
+	}
+	disk, offset := w.position()
+	cdr.diskNumberStart, cdr.localHeaderOffset = disk, uint64(offset)
+	if (sizes64 || offset >= 0xFFFFFFFF) && cdr.versionNeeded < zip64Version {
+		cdr.versionNeeded = zip64Version
+	}
+
+	if aligned {
+		extraField = append(extraField[:len(extraField):len(extraField)], alignmentField(offset, length, w.alignment)...)
+		if len(extraField) > 0xFFFF {
+			return ErrTooLarge
+		}
+	}
+
+	var b byteWriter
+	b.uint32(localFileHeaderSignature)
+	b.uint16(cdr.versionNeeded)
+	b.uint16(cdr.bitFlag)
+	b.uint16(uint16(cdr.compression))
+	b.uint16(cdr.modifiedTime)
+	b.uint16(cdr.modifiedDate)
+	b.uint32(cdr.crc32)
+	b.uint32(compressedSize)
+	b.uint32(uncompressedSize)
+	b.uint16(uint16(len(cdr.fileName)))
+	b.uint16(uint16(len(extraField)))
+	b.WriteString(cdr.fileName)
+	b.Write(extraField)
+
+	_, err := w.w.Write(b.Bytes())
+	return err
+}
+
+// prepare checks w can take another entry and gives cdr, the entry's
+// record, its place in the archive.
+func (w *Writer) prepare(cdr *centralDirectoryRecord) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if err := w.closeCurrent(); err != nil {
+		return err
+	}
+
+	cdr.diskNumberStart, cdr.localHeaderOffset = 0, uint64(w.w.count)
+	w.replaceExisting(cdr.fileName)
+	return nil
+}
+
+// newRecord returns the record for adding e, after checking its fields
+// fit in one.
+func (w *Writer) newRecord(e *Entry) (*centralDirectoryRecord, error) {
+	if w.reproducible != nil {
+		e = reproducibleEntry(e, *w.reproducible)
+	}
+
+	cdr := newCentralDirectoryRecord(e)
+	if len(cdr.fileName) > 0xFFFF || len(cdr.extraField) > 0xFFFF || len(cdr.comment) > 0xFFFF {
+		return nil, ErrTooLarge
+	}
+
+	if w.reproducible != nil {
+		cdr.modifiedDate, cdr.modifiedTime = msdosTime(cdr.lastModified)
+	}
+
+	return cdr, nil
+}
+
+// Compressed is an entry compressed, and encrypted if the Writer
+// encrypts, by Writer.Compress and waiting to be written.
+type Compressed struct {
+	cdr  *centralDirectoryRecord
+	data []byte
+	// spill holds the data instead for CompressReader.
+	spill *spillBuffer
+}
+
+// Close releases what an entry CompressReader compressed holds, for an
+// entry that won't be written after all. WriteCompressed does this
+// itself.
+func (c *Compressed) Close() error {
+	if c.spill == nil {
+		return nil
+	}
+
+	return c.spill.Close()
+}
+
+// WriteEntry adds an entry with the given contents. The name,
+// modification time, method, external attributes, extra field and
+// comment are taken from e. An entry whose contents do not get any smaller
+// compressed is stored instead.
+func (w *Writer) WriteEntry(e *Entry, contents []byte) error {
+	c, err := w.Compress(e, contents)
+	if err != nil {
+		return err
+	}
+
+	return w.WriteCompressed(c)
+}
+
+// Compress does the work of WriteEntry short of writing the entry,
+// which WriteCompressed then does. Unlike the Writer's other methods it
+// can be called from several goroutines at once, to compress entries in
+// parallel, as long as SetLevel and SetEncryption aren't called
+// meanwhile.
+func (w *Writer) Compress(e *Entry, contents []byte) (*Compressed, error) {
+	cdr, err := w.newRecord(e)
+	if err != nil {
+		return nil, err
+	}
+
+	data := contents
+	if cdr.compression != NoCompression {
+		data, err = compress(cdr.compression, w.level, contents)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) >= len(contents) {
+			data = contents
+			cdr.compression = NoCompression
+			cdr.versionNeeded = versionNeeded(NoCompression)
+		}
+	}
+
+	cdr.crc32 = crc32.ChecksumIEEE(contents)
+	cdr.uncompressedSize = uint64(len(contents))
+
+	if w.encryption != NoEncryption && !e.IsDir() {
+		var buf bytes.Buffer
+		enc, err := w.newEncrypter(&buf, cdr)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := enc.Write(data); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+
+		data = buf.Bytes()
+		w.encrypt(cdr)
+		if w.encryption == AESEncryption {
+			cdr.crc32 = 0
+		}
+	}
+	cdr.compressedSize = uint64(len(data))
+
+	return &Compressed{cdr: cdr, data: data}, nil
+}
+
+// CompressReader is Compress for contents read from r, which are
+// compressed as they are read rather than all held in memory first.
+// What they compress to is held in memory up to a few megabytes and in
+// a temporary file past that, until WriteCompressed or Close. Contents
+// that don't get any smaller are only stored instead when r is an
+// io.Seeker, so they can be read again.
+func (w *Writer) CompressReader(e *Entry, r io.Reader) (*Compressed, error) {
+	cdr, err := w.newRecord(e)
+	if err != nil {
+		return nil, err
+	}
+
+	var start int64
+	seeker, canSeek := r.(io.Seeker)
+	if canSeek {
+		start, err = seeker.Seek(0, io.SeekCurrent)
+		canSeek = err == nil
+	}
+
+	crc := crc32.NewIEEE()
+	read := &countWriter{w: crc}
+	data, err := compressFrom(cdr.compression, w.level, io.TeeReader(r, read))
+	if err != nil {
+		return nil, err
+	}
+	cdr.crc32 = crc.Sum32()
+	cdr.uncompressedSize = uint64(read.count)
+
+	if cdr.compression != NoCompression && data.size >= read.count && canSeek {
+		data.Close()
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		data, err = compressFrom(NoCompression, w.level, io.LimitReader(r, read.count))
+		if err != nil {
+			return nil, err
+		}
+		cdr.compression = NoCompression
+		cdr.versionNeeded = versionNeeded(NoCompression)
+	}
+
+	if w.encryption != NoEncryption && !e.IsDir() {
+		encrypted := &spillBuffer{}
+		enc, err := w.newEncrypter(encrypted, cdr)
+		if err == nil {
+			_, err = data.WriteTo(enc)
+		}
+		if err == nil {
+			err = enc.Close()
+		}
+		data.Close()
+		if err != nil {
+			encrypted.Close()
+			return nil, err
+		}
+
+		data = encrypted
+		w.encrypt(cdr)
+		if w.encryption == AESEncryption {
+			cdr.crc32 = 0
+		}
+	}
+	cdr.compressedSize = uint64(data.size)
+
+	return &Compressed{cdr: cdr, spill: data}, nil
+}
+
+// compressFrom compresses what is read from r with compression into a
+// spillBuffer.
+func compressFrom(compression Compression, level int, r io.Reader) (*spillBuffer, error) {
+	data := &spillBuffer{}
+	cw, err := newCompressor(compression, level, data)
+	if err == nil {
+		_, err = io.Copy(cw, r)
+	}
+	if err == nil {
+		err = cw.Close()
+	}
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// WriteCompressed adds the entry Compress or CompressReader
+// compressed. Entries are added in the order they are written, not the
+// order they were compressed.
+func (w *Writer) WriteCompressed(c *Compressed) error {
+	defer c.Close()
+
+	cdr := c.cdr
+	if err := w.prepare(cdr); err != nil {
+		return err
+	}
+
+	if err := w.writeLocalFileHeader(cdr); err != nil {
+		return err
+	}
+
+	if c.spill != nil {
+		if _, err := c.spill.WriteTo(w.w); err != nil {
+			return err
+		}
+	} else if _, err := w.w.Write(c.data); err != nil {
+		return err
+	}
+
+	w.records = append(w.records, cdr)
+	w.progress.add(cdr.fileName, cdr.uncompressedSize)
+	return nil
+}
+
+func newCompressor(compression Compression, level int, w io.Writer) (io.WriteCloser, error) {
+	comp, err := compressor(compression)
+	if err != nil {
+		return nil, err
+	}
+
+	return comp(w, level)
+}
+
+func compress(compression Compression, level int, contents []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	cw, err := newCompressor(compression, level, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cw.Write(contents); err != nil {
+		return nil, err
+	}
+
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Create adds a deflated entry named name, modified now, and returns a
+// writer for its contents. The contents are streamed so their sizes
+// and CRC follow them in a data descriptor. The writer is valid until
+// the next call to Create, CreateEntry, WriteEntry or Close.
+func (w *Writer) Create(name string) (io.Writer, error) {
+	return w.CreateEntry(&Entry{
+		Name:     name,
+		Modified: time.Now(),
+		Method:   DeflateCompression,
+	})
+}
+
+// CreateEntry is like Create but takes the name, modification time,
+// method, external attributes, extra field and comment from e.
+func (w *Writer) CreateEntry(e *Entry) (io.Writer, error) {
+	if _, err := compressor(e.Method); err != nil {
+		return nil, err
+	}
+
+	cdr, err := w.newRecord(e)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.prepare(cdr); err != nil {
+		return nil, err
+	}
+
+	cdr.bitFlag |= dataDescriptorFlag
+	method := cdr.compression
+	w.encrypt(cdr)
+	if err := w.writeLocalFileHeader(cdr); err != nil {
+		return nil, err
+	}
+
+	ew := &entryWriter{
+		cdr:      cdr,
+		progress: w.progress,
+		raw:      &countWriter{w: w.w},
+		crc:      crc32.NewIEEE(),
+		noCRC:    w.encryption == AESEncryption,
+	}
+
+	var dst io.Writer = ew.raw
+	ew.encrypter, err = w.newEncrypter(ew.raw, cdr)
+	if err != nil {
+		return nil, err
+	}
+	if ew.encrypter != nil {
+		dst = ew.encrypter
+	}
+
+	ew.compressor, err = newCompressor(method, w.level, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	w.current = ew
+	return ew, nil
+}
+
+// CreateFromReader adds a deflated entry named name, modified now, with
+// the contents read from r until EOF. They are streamed in as Create
+// streams them, so r can be of any length, such as a pipe, and isn't
+// held in memory.
+func (w *Writer) CreateFromReader(name string, r io.Reader) error {
+	return w.CreateEntryFromReader(&Entry{
+		Name:     name,
+		Modified: time.Now(),
+		Method:   DeflateCompression,
+	}, r)
+}
+
+// CreateEntryFromReader is like CreateFromReader but takes the entry's
+// fields from e as CreateEntry does, so its contents can be stored
+// rather than deflated.
+func (w *Writer) CreateEntryFromReader(e *Entry, r io.Reader) error {
+	ew, err := w.CreateEntry(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(ew, r); err != nil {
+		return err
+	}
+
+	return w.closeCurrent()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+type entryWriter struct {
+	cdr        *centralDirectoryRecord
+	raw        *countWriter
+	compressor io.WriteCloser
+	encrypter  io.WriteCloser
+	crc        hash.Hash32
+	noCRC      bool
+	progress   *progress
+	size       uint64
+	closed     bool
+}
+
+func (ew *entryWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, ErrWriterClosed
+	}
+
+	ew.crc.Write(p)
+	ew.size += uint64(len(p))
+	ew.progress.add(ew.cdr.fileName, uint64(len(p)))
+	return ew.compressor.Write(p)
+}
+
+func (w *Writer) closeCurrent() error {
+	ew := w.current
+	if ew == nil {
+		return nil
+	}
+	w.current = nil
+	ew.closed = true
+
+	if err := ew.compressor.Close(); err != nil {
+		return err
+	}
+
+	if ew.encrypter != nil {
+		if err := ew.encrypter.Close(); err != nil {
+			return err
+		}
+	}
+
+	cdr := ew.cdr
+	cdr.crc32 = ew.crc.Sum32()
+	if ew.noCRC {
+		cdr.crc32 = 0
+	}
+	cdr.compressedSize = uint64(ew.raw.count)
+	cdr.uncompressedSize = ew.size
+	if _, err := w.w.Write(dataDescriptorBytes(cdr)); err != nil {
+		return err
+	}
+
+	w.records = append(w.records, cdr)
+	return nil
+}
+
+// Close finishes the current entry and writes the central directory
+// and end of central directory record. It does not close the
+// underlying writer, unless it is CreateSplit's.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if err := w.closeCurrent(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	// ZIP64 end records are written once anything overflows the plain
+	// one's fields, or if the archive appended to had them.
+	zip64 := w.zip64 || len(w.records) >= 0xFFFF
+
+	// The central directory starts where its first record does, which
+	// may be on the next volume of a split archive.
+	start := w.w.count
+	cdDisk, cdOffset := w.position()
+	lastDisk, diskRecords := cdDisk, 0
+	for i, cdr := range w.records {
+		compressedSize, uncompressedSize := uint32(cdr.compressedSize), uint32(cdr.uncompressedSize)
+		localHeaderOffset := uint32(cdr.localHeaderOffset)
+		extraField := cdr.extraField
+		if needsZip64(cdr) {
+			zip64 = true
+			compressedSize, uncompressedSize, localHeaderOffset = 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF
+			extraField = zip64RecordExtraField(cdr)
+			if len(extraField) > 0xFFFF {
+				return ErrTooLarge
+			}
+		}
+
+		var b byteWriter
+		b.uint32(centralDirectorySignature)
+		b.uint16(cdr.versionMadeBy)
+		b.uint16(cdr.versionNeeded)
+		b.uint16(cdr.bitFlag)
+		b.uint16(uint16(cdr.compression))
+		b.uint16(cdr.modifiedTime)
+		b.uint16(cdr.modifiedDate)
+		b.uint32(cdr.crc32)
+		b.uint32(compressedSize)
+		b.uint32(uncompressedSize)
+		b.uint16(uint16(len(cdr.fileName)))
+		b.uint16(uint16(len(extraField)))
+		b.uint16(uint16(len(cdr.comment)))
+		b.uint16(uint16(cdr.diskNumberStart))
+		b.uint16(cdr.internalAttrs)
+		b.uint32(cdr.externalAttrs)
+		b.uint32(localHeaderOffset)
+		b.WriteString(cdr.fileName)
+		b.Write(extraField)
+		b.WriteString(cdr.comment)
+
+		if err := w.keep(b.Len()); err != nil {
+			return err
+		}
+		disk, offset := w.position()
+		if i == 0 {
+			cdDisk, cdOffset = disk, offset
+		}
+		if disk != lastDisk {
+			lastDisk, diskRecords = disk, 0
+		}
+		diskRecords++
+
+		if _, err := w.w.Write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	end := w.w.count
+	if cdOffset >= 0xFFFFFFFF || end-start >= 0xFFFFFFFF {
+		zip64 = true
+	}
+
+	length := endOfCentralDirectoryLength + len(w.comment)
+	if zip64 {
+		length += zip64EndOfCentralDirectoryLength + zip64EndOfCentralDirectoryLocatorLength
+	}
+	if err := w.keep(length); err != nil {
+		return err
+	}
+	disk, offset := w.position()
+	if disk != lastDisk {
+		diskRecords = 0
+	}
+	if len(w.records) == 0 {
+		cdDisk, cdOffset = disk, offset
+	}
+
+	var b byteWriter
+	if zip64 {
+		b.uint32(zip64EndOfCentralDirectorySignature)
+		b.uint64(zip64EndOfCentralDirectoryLength - 12)
+		b.uint16(zip64Version)
+		b.uint16(zip64Version)
+		b.uint32(disk)
+		b.uint32(cdDisk)
+		b.uint64(uint64(diskRecords))
+		b.uint64(uint64(len(w.records)))
+		b.uint64(uint64(end - start))
+		b.uint64(uint64(cdOffset))
+
+		b.uint32(zip64EndOfCentralDirectoryLocatorSignature)
+		b.uint32(disk)
+		b.uint64(uint64(offset))
+		b.uint32(disk + 1)
+	}
+
+	// Values that don't fit are all ones, leaving readers to take them
+	// from the ZIP64 record.
+	b.uint32(endOfCentralDirectorySignature)
+	b.uint16(uint16(disk))
+	b.uint16(uint16(cdDisk))
+	b.uint16(uint16(min64(int64(diskRecords), 0xFFFF)))
+	b.uint16(uint16(min64(int64(len(w.records)), 0xFFFF)))
+	b.uint32(uint32(min64(end-start, 0xFFFFFFFF)))
+	b.uint32(uint32(min64(cdOffset, 0xFFFFFFFF)))
+	b.uint16(uint16(len(w.comment)))
+	b.WriteString(w.comment)
+	if _, err := w.w.Write(b.Bytes()); err != nil {
+		return err
+	}
+
+	if w.split != nil {
+		return w.split.close()
+	}
+
+	return w.truncate()
+}
@@ -0,0 +1,121 @@
+package gozip
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCompressReader(t *testing.T) {
+	text := bytes.Repeat([]byte("compressible text\n"), 1<<18)
+	random := testRandom(6 << 20)
+
+	tests := []struct {
+		name       string
+		contents   []byte
+		method     Compression
+		seekable   bool
+		encryption Encryption
+		want       Compression
+	}{
+		{"stored", text, NoCompression, true, NoEncryption, NoCompression},
+		{"deflated", text, DeflateCompression, true, NoEncryption, DeflateCompression},
+		{"zstd", text, ZstdCompression, false, NoEncryption, ZstdCompression},
+		{"incompressible stored instead", random, DeflateCompression, true, NoEncryption, NoCompression},
+		{"incompressible without seeking", random, DeflateCompression, false, NoEncryption, DeflateCompression},
+		{"AES", text, DeflateCompression, true, AESEncryption, aesCompression},
+		{"ZipCrypto", random, DeflateCompression, true, ZipCryptoEncryption, NoCompression},
+		{"empty", nil, DeflateCompression, true, NoEncryption, NoCompression},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				if test.encryption != NoEncryption {
+					w.SetEncryption(test.encryption, "secret")
+				}
+
+				var r io.Reader = bytes.NewReader(test.contents)
+				if !test.seekable {
+					r = io.MultiReader(r)
+				}
+				c, err := w.CompressReader(&Entry{Name: "file", Modified: testModified, Method: test.method}, r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := w.WriteCompressed(c); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			e := lookupEntry(t, readArchive(t, bs, WithPassword("secret")), "file")
+			if e.Method != test.want {
+				t.Errorf("got method %v, want %v", e.Method, test.want)
+			}
+			got, err := e.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, test.contents) {
+				t.Error("contents differ")
+			}
+		})
+	}
+}
+
+func TestCreateFromReader(t *testing.T) {
+	contents := bytes.Repeat([]byte("streamed from a pipe\n"), 1<<12)
+
+	tests := []struct {
+		name   string
+		method Compression
+	}{
+		{"deflated", DeflateCompression},
+		{"stored", NoCompression},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			go func() {
+				// Write in pieces so the source's length isn't known
+				// until the pipe is closed.
+				for i := 0; i < len(contents); i += 1000 {
+					end := i + 1000
+					if end > len(contents) {
+						end = len(contents)
+					}
+					pw.Write(contents[i:end])
+				}
+				pw.Close()
+			}()
+
+			bs := writeArchive(t, func(w *Writer) {
+				var err error
+				if test.method == DeflateCompression {
+					err = w.CreateFromReader("file.txt", pr)
+				} else {
+					err = w.CreateEntryFromReader(&Entry{Name: "file.txt", Modified: testModified, Method: test.method}, pr)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			e := lookupEntry(t, readArchive(t, bs), "file.txt")
+			if e.Method != test.method {
+				t.Errorf("got method %v, want %v", e.Method, test.method)
+			}
+			if e.record.bitFlag&dataDescriptorFlag == 0 {
+				t.Error("no data descriptor")
+			}
+			got, err := e.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, contents) {
+				t.Error("contents differ")
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package gozip
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	zip64ExtraFieldID = 0x0001
+
+	zip64EndOfCentralDirectorySignature        = 0x06064b50
+	zip64EndOfCentralDirectoryLocatorSignature = 0x07064b50
+	zip64EndOfCentralDirectoryLocatorLength    = 20
+	zip64EndOfCentralDirectoryLength           = 56
+
+	// zip64Version is the version needed to extract anything ZIP64.
+	zip64Version = 45
+)
+
+var ErrInvalidZip64 = fmt.Errorf("Invalid ZIP64 record")
+
+// findExtraField returns the data of the first record in an extra field
+// with the given header ID.
+func findExtraField(extraField []byte, id uint16) ([]byte, bool) {
+	i := 0
+	for i < len(extraField) {
+		recordID, next, err := readUint16(extraField, i)
+		if err != nil {
+			return nil, false
+		}
+
+		size, next, err := readUint16(extraField, next)
+		if err != nil {
+			return nil, false
+		}
+
+		data, next, err := readBytes(extraField, next, int(size))
+		if err != nil {
+			return nil, false
+		}
+
+		if recordID == id {
+			return data, true
+		}
+
+		i = next
+	}
+
+	return nil, false
+}
+
+// applyZip64ExtraField replaces the central directory fields that are
+// set to their sentinel value, all ones, with the 64-bit values from the
+// ZIP64 extended information extra field. The extra field only holds
+// the fields that overflowed, in this fixed order.
+func applyZip64ExtraField(cdr *centralDirectoryRecord) error {
+	needsUncompressedSize := cdr.uncompressedSize == 0xFFFFFFFF
+	needsCompressedSize := cdr.compressedSize == 0xFFFFFFFF
+	needsOffset := cdr.localHeaderOffset == 0xFFFFFFFF
+	needsDisk := cdr.diskNumberStart == 0xFFFF
+	if !needsUncompressedSize && !needsCompressedSize && !needsOffset && !needsDisk {
+		return nil
+	}
+
+	data, ok := findExtraField(cdr.extraField, zip64ExtraFieldID)
+	if !ok {
+		return ErrInvalidZip64
+	}
+
+	i := 0
+	var err error
+	cdr.zip64 = &Zip64ExtraField{}
+	if needsUncompressedSize {
+		cdr.uncompressedSize, i, err = readUint64(data, i)
+		if err != nil {
+			return ErrInvalidZip64
+		}
+		cdr.zip64.UncompressedSize = cdr.uncompressedSize
+	}
+
+	if needsCompressedSize {
+		cdr.compressedSize, i, err = readUint64(data, i)
+		if err != nil {
+			return ErrInvalidZip64
+		}
+		cdr.zip64.CompressedSize = cdr.compressedSize
+	}
+
+	if needsOffset {
+		cdr.localHeaderOffset, i, err = readUint64(data, i)
+		if err != nil {
+			return ErrInvalidZip64
+		}
+		cdr.zip64.LocalHeaderOffset = cdr.localHeaderOffset
+	}
+
+	if needsDisk {
+		cdr.diskNumberStart, _, err = readUint32(data, i)
+		if err != nil {
+			return ErrInvalidZip64
+		}
+		cdr.zip64.DiskNumberStart = cdr.diskNumberStart
+	}
+
+	return nil
+}
+
+// needsZip64 reports whether the central directory record cdr has to
+// be written with a ZIP64 extra field: if its sizes or offset don't fit
+// in 32 bits, or it had one in the archive Append found it in.
+func needsZip64(cdr *centralDirectoryRecord) bool {
+	return cdr.zip64 != nil || cdr.compressedSize >= 0xFFFFFFFF || cdr.uncompressedSize >= 0xFFFFFFFF || cdr.localHeaderOffset >= 0xFFFFFFFF
+}
+
+// zip64RecordExtraField returns cdr's extra field with a ZIP64 record of
+// its sizes and local header offset in place of any it had, for writing
+// it with all three set to all ones.
+func zip64RecordExtraField(cdr *centralDirectoryRecord) []byte {
+	var b byteWriter
+	b.uint16(zip64ExtraFieldID)
+	b.uint16(24)
+	b.uint64(cdr.uncompressedSize)
+	b.uint64(cdr.compressedSize)
+	b.uint64(cdr.localHeaderOffset)
+	b.Write(withoutExtraField(cdr.extraField, zip64ExtraFieldID))
+	return b.Bytes()
+}
+
+// zip64LocalExtraField returns extraField, a local header's, with a
+// ZIP64 record of cdr's sizes in place of any it had. Unlike the central
+// directory's, a local header's record holds both sizes and nothing
+// else.
+func zip64LocalExtraField(cdr *centralDirectoryRecord, extraField []byte) []byte {
+	var b byteWriter
+	b.uint16(zip64ExtraFieldID)
+	b.uint16(16)
+	b.uint64(cdr.uncompressedSize)
+	b.uint64(cdr.compressedSize)
+	b.Write(withoutExtraField(extraField, zip64ExtraFieldID))
+	return b.Bytes()
+}
+
+// dataDescriptorBytes returns the data descriptor that follows cdr's
+// data when its sizes weren't known for the local header, with 8-byte
+// sizes if they don't fit in 4. Readers can't tell from a local header
+// written before the sizes were known which it is, so they try both.
+func dataDescriptorBytes(cdr *centralDirectoryRecord) []byte {
+	var b byteWriter
+	b.uint32(dataDescriptorSignature)
+	b.uint32(cdr.crc32)
+	if cdr.compressedSize >= 0xFFFFFFFF || cdr.uncompressedSize >= 0xFFFFFFFF {
+		b.uint64(cdr.compressedSize)
+		b.uint64(cdr.uncompressedSize)
+	} else {
+		b.uint32(uint32(cdr.compressedSize))
+		b.uint32(uint32(cdr.uncompressedSize))
+	}
+	return b.Bytes()
+}
+
+// min64 returns the smaller of a and b.
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// findZip64EndOfCentralDirectory looks for the ZIP64 end of central
+// directory locator just before the end of central directory record at
+// eocdOffset and parses the ZIP64 record it points to. It returns a nil
+// record if the archive is not ZIP64.
+func findZip64EndOfCentralDirectory(r io.ReaderAt, eocdOffset int64) (*endOfCentralDirectory, int64, error) {
+	locatorOffset := eocdOffset - zip64EndOfCentralDirectoryLocatorLength
+	if locatorOffset < 0 {
+		return nil, 0, nil
+	}
+
+	locator, err := readAt(r, locatorOffset, zip64EndOfCentralDirectoryLocatorLength)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	signature, i, err := readUint32(locator, 0)
+	if err != nil || signature != zip64EndOfCentralDirectoryLocatorSignature {
+		return nil, 0, nil
+	}
+
+	// Skip the number of the disk the ZIP64 record is on.
+	recordOffset, _, err := readUint64(locator, i+4)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The stored offset is relative to the start of the archive. When
+	// data has been prepended to it the record is not there, but unless
+	// it has extensible data it sits directly before the locator.
+	offset := int64(recordOffset)
+	record, err := readZip64EndOfCentralDirectory(r, offset)
+	if recordOffset > uint64(locatorOffset) || err != nil {
+		offset = locatorOffset - zip64EndOfCentralDirectoryLength
+		record, err = readZip64EndOfCentralDirectory(r, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	eocd, err := parseZip64EndOfCentralDirectory(record, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return eocd, offset, nil
+}
+
+func readZip64EndOfCentralDirectory(r io.ReaderAt, offset int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, ErrInvalidZip64
+	}
+
+	record, err := readAt(r, offset, zip64EndOfCentralDirectoryLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if !atZip64EndOfCentralDirectory(record, 0) {
+		return nil, ErrInvalidZip64
+	}
+
+	return record, nil
+}
+
+func atZip64EndOfCentralDirectory(bs []byte, offset int) bool {
+	signature, _, err := readUint32(bs, offset)
+	return err == nil && signature == zip64EndOfCentralDirectorySignature
+}
+
+func parseZip64EndOfCentralDirectory(bs []byte, start int) (*endOfCentralDirectory, error) {
+	// Skip the signature, the size of the record and the versions made
+	// by and needed to extract.
+	i := start + 4 + 8 + 2 + 2
+
+	diskNumber, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	centralDirectoryDisk, i, err := readUint32(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	diskEntries, i, err := readUint64(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, i, err := readUint64(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	centralDirectorySize, i, err := readUint64(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	centralDirectoryOffset, _, err := readUint64(bs, i)
+	if err != nil {
+		return nil, err
+	}
+
+	return &endOfCentralDirectory{
+		diskNumber:             diskNumber,
+		centralDirectoryDisk:   centralDirectoryDisk,
+		diskEntries:            diskEntries,
+		entries:                entries,
+		centralDirectorySize:   centralDirectorySize,
+		centralDirectoryOffset: centralDirectoryOffset,
+	}, nil
+}
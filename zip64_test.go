@@ -0,0 +1,342 @@
+package gozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// forceZip64 rewrites the archive in bs, which has no comment, as a
+// ZIP64 one that a writer forced to use ZIP64 might make: every central
+// directory record's sizes, and its local header offset if offsets is
+// set, are all ones with the values in a ZIP64 extra field, and the end
+// of central directory record defers to a ZIP64 one.
+func forceZip64(t *testing.T, bs []byte, offsets bool) []byte {
+	t.Helper()
+
+	start := bytes.Index(bs, []byte("PK\x01\x02"))
+	end := len(bs) - 22
+	if start < 0 || binary.LittleEndian.Uint32(bs[end:]) != endOfCentralDirectorySignature {
+		t.Fatal("not an archive with a central directory and no comment")
+	}
+
+	var out byteWriter
+	out.Write(bs[:start])
+
+	entries := 0
+	for i := start; i < end; entries++ {
+		record := bs[i : i+46]
+		nameLength := int(binary.LittleEndian.Uint16(record[28:]))
+		extraLength := int(binary.LittleEndian.Uint16(record[30:]))
+		commentLength := int(binary.LittleEndian.Uint16(record[32:]))
+
+		var zip64 byteWriter
+		zip64.Write(record[24:28])
+		zip64.Write(make([]byte, 4))
+		zip64.Write(record[20:24])
+		zip64.Write(make([]byte, 4))
+		if offsets {
+			zip64.Write(record[42:46])
+			zip64.Write(make([]byte, 4))
+		}
+
+		fixed := append([]byte(nil), record...)
+		binary.LittleEndian.PutUint32(fixed[20:], 0xFFFFFFFF)
+		binary.LittleEndian.PutUint32(fixed[24:], 0xFFFFFFFF)
+		if offsets {
+			binary.LittleEndian.PutUint32(fixed[42:], 0xFFFFFFFF)
+		}
+		binary.LittleEndian.PutUint16(fixed[30:], uint16(4+zip64.Len()+extraLength))
+
+		rest := i + 46
+		out.Write(fixed)
+		out.Write(bs[rest : rest+nameLength])
+		out.uint16(zip64ExtraFieldID)
+		out.uint16(uint16(zip64.Len()))
+		out.Write(zip64.Bytes())
+		out.Write(bs[rest+nameLength : rest+nameLength+extraLength+commentLength])
+
+		i = rest + nameLength + extraLength + commentLength
+	}
+
+	size := out.Len() - start
+	record := out.Len()
+
+	out.uint32(zip64EndOfCentralDirectorySignature)
+	out.uint64(zip64EndOfCentralDirectoryLength - 12)
+	out.uint16(45)
+	out.uint16(45)
+	out.uint32(0)
+	out.uint32(0)
+	out.uint64(uint64(entries))
+	out.uint64(uint64(entries))
+	out.uint64(uint64(size))
+	out.uint64(uint64(start))
+
+	out.uint32(zip64EndOfCentralDirectoryLocatorSignature)
+	out.uint32(0)
+	out.uint64(uint64(record))
+	out.uint32(1)
+
+	out.uint32(endOfCentralDirectorySignature)
+	out.uint16(0)
+	out.uint16(0)
+	out.uint16(0xFFFF)
+	out.uint16(0xFFFF)
+	out.uint32(0xFFFFFFFF)
+	out.uint32(0xFFFFFFFF)
+	out.uint16(0)
+
+	return out.Bytes()
+}
+
+func TestZip64(t *testing.T) {
+	contents := map[string][]byte{
+		"a.txt": []byte("first\n"),
+		"b.txt": bytes.Repeat([]byte("second\n"), 100),
+	}
+
+	bs := writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, contents["a.txt"])
+		writeEntry(t, w, "b.txt", DeflateCompression, contents["b.txt"])
+	})
+
+	tests := []struct {
+		name    string
+		offsets bool
+	}{
+		{"sizes", false},
+		// Local header offsets are all ones too, as they are past
+		// 4GiB, and have to come from the extra field.
+		{"sizes and offsets", true},
+	}
+	plain := readArchive(t, bs)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := readArchive(t, forceZip64(t, bs, test.offsets))
+			if len(r.Entries()) != len(contents) {
+				t.Fatalf("got %d entries, want %d", len(r.Entries()), len(contents))
+			}
+
+			for name, want := range contents {
+				e := lookupEntry(t, r, name)
+				if _, ok := e.Zip64ExtraField(); !ok {
+					t.Errorf("%s: no ZIP64 extra field", name)
+				}
+				if e.UncompressedSize != uint64(len(want)) {
+					t.Errorf("%s: got size %d, want %d", name, e.UncompressedSize, len(want))
+				}
+				if offset := lookupEntry(t, plain, name).HeaderOffset(); e.HeaderOffset() != offset {
+					t.Errorf("%s: got header at %d, want %d", name, e.HeaderOffset(), offset)
+				}
+
+				rc, err := e.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s: got %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAppendZip64(t *testing.T) {
+	forced := forceZip64(t, writeArchive(t, func(w *Writer) {
+		writeEntry(t, w, "a.txt", NoCompression, []byte("a\n"))
+		writeEntry(t, w, "b.txt", DeflateCompression, bytes.Repeat([]byte("b\n"), 100))
+	}), true)
+
+	// One entry short of the most the end of central directory record
+	// can count.
+	var names []string
+	for i := 0; i < 0xFFFE; i++ {
+		names = append(names, fmt.Sprintf("%05d", i))
+	}
+	many := writeArchive(t, func(w *Writer) {
+		for _, name := range names {
+			writeEntry(t, w, name, NoCompression, nil)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		bs       []byte
+		wasZip64 bool
+		entries  int
+	}{
+		{"already ZIP64", forced, true, 3},
+		{"comes to need ZIP64", many, false, 0xFFFF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if bytes.Contains(test.bs, []byte("PK\x06\x06")) != test.wasZip64 {
+				t.Fatalf("ZIP64 end record before appending is %t, want %t", !test.wasZip64, test.wasZip64)
+			}
+
+			path := filepath.Join(t.TempDir(), "archive.zip")
+			if err := os.WriteFile(path, test.bs, 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			w, err := Append(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeEntry(t, w, "c.txt", DeflateCompression, bytes.Repeat([]byte("c\n"), 100))
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			bs, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(bs, []byte("PK\x06\x06")) {
+				t.Error("no ZIP64 end record")
+			}
+
+			r := readArchive(t, bs, WithParseOptions(ParseOptions{Strict: true}))
+			if len(r.Entries()) != test.entries {
+				t.Fatalf("got %d entries, want %d", len(r.Entries()), test.entries)
+			}
+			for _, e := range r.Entries() {
+				if err := e.Verify(); err != nil {
+					t.Fatalf("%s: %v", e.Name, err)
+				}
+			}
+			if got, err := lookupEntry(t, r, "c.txt").ReadAll(); err != nil || !bytes.Equal(got, bytes.Repeat([]byte("c\n"), 100)) {
+				t.Errorf("got %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestZip64LocalHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	cdr, err := w.newRecord(&Entry{Name: "big.bin", Method: DeflateCompression})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdr.compressedSize, cdr.uncompressedSize = 5<<30, 6<<30
+	if err := w.writeLocalFileHeader(cdr); err != nil {
+		t.Fatal(err)
+	}
+
+	lfh, _, err := parseLocalFileHeaderFields(buf.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.LittleEndian.Uint32(buf.Bytes()[18:]); got != 0xFFFFFFFF {
+		t.Errorf("got compressed size %#x in the header, want all ones", got)
+	}
+	if lfh.compressedSize != cdr.compressedSize || lfh.uncompressedSize != cdr.uncompressedSize {
+		t.Errorf("got sizes %d and %d, want %d and %d", lfh.compressedSize, lfh.uncompressedSize, cdr.compressedSize, cdr.uncompressedSize)
+	}
+	if lfh.version != zip64Version || cdr.versionNeeded != zip64Version {
+		t.Errorf("got version needed %d locally and %d centrally, want %d", lfh.version, cdr.versionNeeded, zip64Version)
+	}
+
+	dd, _, err := parseDataDescriptor(dataDescriptorBytes(cdr), 0, false, cdr.compressedSize, cdr.uncompressedSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dd.compressedSize != cdr.compressedSize || dd.uncompressedSize != cdr.uncompressedSize {
+		t.Errorf("got descriptor sizes %d and %d, want %d and %d", dd.compressedSize, dd.uncompressedSize, cdr.compressedSize, cdr.uncompressedSize)
+	}
+}
+
+func TestAppendPast4GiB(t *testing.T) {
+	// The archive starts 5GiB into a sparse file, so every offset in it
+	// is past what 32 bits hold.
+	const start = 5 << 30
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string][]byte{
+		"a.txt": []byte("a\n"),
+		"b.txt": bytes.Repeat([]byte("b\n"), 100),
+		"c.txt": bytes.Repeat([]byte("c\n"), 100),
+		"d.txt": bytes.Repeat([]byte("d\n"), 100),
+	}
+
+	w := NewWriter(f)
+	w.SetOffset(start)
+	writeEntry(t, w, "a.txt", NoCompression, contents["a.txt"])
+	writeEntry(t, w, "b.txt", DeflateCompression, contents["b.txt"])
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = Append(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEntry(t, w, "c.txt", DeflateCompression, contents["c.txt"])
+	cw, err := w.Create("d.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cw.Write(contents["d.txt"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(f, info.Size(), WithParseOptions(ParseOptions{Strict: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Entries()) != len(contents) {
+		t.Fatalf("got %d entries, want %d", len(r.Entries()), len(contents))
+	}
+
+	for name, want := range contents {
+		e := lookupEntry(t, r, name)
+		if _, ok := e.Zip64ExtraField(); !ok {
+			t.Errorf("%s: no ZIP64 extra field", name)
+		}
+		if e.HeaderOffset() < start {
+			t.Errorf("%s: got header at %d, want past %d", name, e.HeaderOffset(), int64(start))
+		}
+		if e.ReaderVersion != zip64Version {
+			t.Errorf("%s: got version needed %d, want %d", name, e.ReaderVersion, zip64Version)
+		}
+		if err := e.Verify(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, err := e.ReadAll(); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+}
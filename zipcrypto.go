@@ -0,0 +1,136 @@
+package gozip
+
+import (
+	"crypto/rand"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	encryptedFlag         = 0x1
+	zipCryptoHeaderLength = 12
+)
+
+var (
+	ErrPasswordRequired = fmt.Errorf("Entry is encrypted and no password was given")
+	ErrPassword         = fmt.Errorf("Incorrect password")
+)
+
+// IsEncrypted reports whether the entry's data is encrypted, with
+// ZipCrypto or, when its method is 99, WinZip AES.
+func (e *Entry) IsEncrypted() bool {
+	return e.Flags&encryptedFlag != 0
+}
+
+// zipCryptoKeys is the state of the traditional PKWARE stream cipher,
+// which the spec calls ZipCrypto.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	keys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range password {
+		keys.update(b)
+	}
+
+	return keys
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+(k[0]&0xFF))*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) streamByte() byte {
+	temp := uint16(k[2]) | 2
+	return byte((uint32(temp) * uint32(temp^1)) >> 8)
+}
+
+func (k *zipCryptoKeys) decrypt(bs []byte) {
+	for i, c := range bs {
+		p := c ^ k.streamByte()
+		k.update(p)
+		bs[i] = p
+	}
+}
+
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (zr *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := zr.r.Read(p)
+	zr.keys.decrypt(p[:n])
+	return n, err
+}
+
+// newZipCryptoReader checks password against the 12 byte encryption
+// header at the start of r and returns a reader of the rest of r,
+// decrypted. The header's last byte is the high byte of the entry's
+// CRC-32, or of its MS-DOS modification time if the CRC was not known
+// when the header was written (bit 3 of the flags). That is all there
+// is to verify a password with, so one in 256 wrong passwords gets
+// through and only fails the CRC check after decompression.
+func newZipCryptoReader(r io.Reader, password []byte, check byte) (io.Reader, error) {
+	header := make([]byte, zipCryptoHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	keys := newZipCryptoKeys(password)
+	keys.decrypt(header)
+	if header[zipCryptoHeaderLength-1] != check {
+		return nil, ErrPassword
+	}
+
+	return &zipCryptoReader{r, keys}, nil
+}
+
+func (k *zipCryptoKeys) encrypt(bs []byte) {
+	for i, p := range bs {
+		c := p ^ k.streamByte()
+		k.update(p)
+		bs[i] = c
+	}
+}
+
+type zipCryptoWriter struct {
+	w    io.Writer
+	keys *zipCryptoKeys
+}
+
+// newZipCryptoWriter writes the 12 byte encryption header to w, ending
+// in check, and returns a writer that encrypts to w what is written to
+// it.
+func newZipCryptoWriter(w io.Writer, password []byte, check byte) (io.WriteCloser, error) {
+	header := make([]byte, zipCryptoHeaderLength)
+	if _, err := rand.Read(header[:zipCryptoHeaderLength-1]); err != nil {
+		return nil, err
+	}
+	header[zipCryptoHeaderLength-1] = check
+
+	keys := newZipCryptoKeys(password)
+	keys.encrypt(header)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &zipCryptoWriter{w, keys}, nil
+}
+
+func (zw *zipCryptoWriter) Write(p []byte) (int, error) {
+	enc := make([]byte, len(p))
+	copy(enc, p)
+	zw.keys.encrypt(enc)
+	return zw.w.Write(enc)
+}
+
+func (zw *zipCryptoWriter) Close() error {
+	return nil
+}
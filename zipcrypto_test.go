@@ -0,0 +1,74 @@
+package gozip
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	contents := bytes.Repeat([]byte("secret contents\n"), 100)
+
+	tests := []struct {
+		name       string
+		encryption Encryption
+		method     Compression
+		streamed   bool
+	}{
+		{"zipcrypto stored", ZipCryptoEncryption, NoCompression, false},
+		{"zipcrypto deflate", ZipCryptoEncryption, DeflateCompression, false},
+		// Streamed entries' CRCs aren't known before their data, so
+		// the password is checked against the modification time.
+		{"zipcrypto streamed", ZipCryptoEncryption, DeflateCompression, true},
+		{"aes stored", AESEncryption, NoCompression, false},
+		{"aes deflate", AESEncryption, DeflateCompression, false},
+		{"aes streamed", AESEncryption, DeflateCompression, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bs := writeArchive(t, func(w *Writer) {
+				w.SetEncryption(test.encryption, "secret")
+				if !test.streamed {
+					writeEntry(t, w, "file.txt", test.method, contents)
+					return
+				}
+
+				fw, err := w.CreateEntry(&Entry{Name: "file.txt", Modified: testModified, Method: test.method})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := fw.Write(contents); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if bytes.Contains(bs, contents[:16]) {
+				t.Fatal("contents were written in the clear")
+			}
+
+			e := lookupEntry(t, readArchive(t, bs), "file.txt")
+			if !e.IsEncrypted() {
+				t.Fatal("entry isn't marked encrypted")
+			}
+			if _, err := e.ReadAll(); !errors.Is(err, ErrPasswordRequired) {
+				t.Errorf("got %v without a password, want %v", err, ErrPasswordRequired)
+			}
+
+			// ZipCrypto only checks one byte of the password up front,
+			// so a wrong one may instead fail decompressing or the CRC.
+			e = lookupEntry(t, readArchive(t, bs, WithPassword("wrong")), "file.txt")
+			if _, err := e.ReadAll(); err == nil {
+				t.Error("read with the wrong password")
+			}
+
+			e = lookupEntry(t, readArchive(t, bs, WithPassword("secret")), "file.txt")
+			got, err := e.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, contents) {
+				t.Error("contents differ")
+			}
+		})
+	}
+}
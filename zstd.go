@@ -0,0 +1,1113 @@
+package gozip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/bits"
+)
+
+// Zstandard, RFC 8878. Frames hold blocks that are stored, a single
+// repeated byte, or compressed: Huffman coded literals followed by
+// FSE coded sequences of literal length, match offset and match length.
+
+const (
+	zstdMagic            = 0xFD2FB528
+	zstdSkippableMagic   = 0x184D2A50
+	zstdSkippableMask    = 0xFFFFFFF0
+	zstdMaxBlockSize     = 128 << 10
+	zstdMaxWindowSize    = 1 << 31
+	zstdMaxHuffmanBits   = 11
+	zstdMaxLiteralLength = 35
+	zstdMaxMatchLength   = 52
+	zstdMaxOffsetCode    = 31
+)
+
+const (
+	zstdBlockRaw = iota
+	zstdBlockRLE
+	zstdBlockCompressed
+)
+
+var (
+	ErrInvalidZstd    = fmt.Errorf("Invalid zstd data")
+	ErrZstdDictionary = fmt.Errorf("Zstd dictionaries are not supported")
+	ErrZstdChecksum   = fmt.Errorf("Zstd content checksum mismatch")
+)
+
+var (
+	zstdLiteralLengthBase = [...]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512,
+		1024, 2048, 4096, 8192, 16384, 32768, 65536,
+	}
+	zstdLiteralLengthBits = [...]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9,
+		10, 11, 12, 13, 14, 15, 16,
+	}
+	zstdMatchLengthBase = [...]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515,
+		1027, 2051, 4099, 8195, 16387, 32771, 65539,
+	}
+	zstdMatchLengthBits = [...]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9,
+		10, 11, 12, 13, 14, 15, 16,
+	}
+
+	// The predefined distributions sequences are coded with when a
+	// block doesn't describe its own.
+	zstdLiteralLengthDistribution = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	zstdMatchLengthDistribution = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	zstdOffsetDistribution = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+// zstdSequenceKinds describes the three FSE coded fields of a
+// sequence, in the order their tables appear in a block.
+var zstdSequenceKinds = [3]struct {
+	maxLog     int
+	maxSymbol  int
+	predefined *fseTable
+}{
+	{9, zstdMaxLiteralLength, mustBuildFSETable(zstdLiteralLengthDistribution, 6)},
+	{8, zstdMaxOffsetCode, mustBuildFSETable(zstdOffsetDistribution, 5)},
+	{9, zstdMaxMatchLength, mustBuildFSETable(zstdMatchLengthDistribution, 6)},
+}
+
+// forwardBitReader reads bits least significant first, as FSE table
+// descriptions are written.
+type forwardBitReader struct {
+	b   []byte
+	pos int
+}
+
+func (br *forwardBitReader) peek(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		p := br.pos + i
+		if p/8 < len(br.b) {
+			v |= uint32(br.b[p/8]>>(p%8)&1) << i
+		}
+	}
+
+	return v
+}
+
+func (br *forwardBitReader) bits(n int) uint32 {
+	v := br.peek(n)
+	br.pos += n
+	return v
+}
+
+// reverseBitReader reads a bitstream backwards from the end, where the
+// highest set bit of the last byte marks its start. Reading past the
+// beginning yields zeros and leaves pos negative.
+type reverseBitReader struct {
+	b   []byte
+	pos int
+}
+
+func newReverseBitReader(b []byte) (*reverseBitReader, error) {
+	if len(b) == 0 || b[len(b)-1] == 0 {
+		return nil, ErrInvalidZstd
+	}
+
+	return &reverseBitReader{b: b, pos: (len(b)-1)*8 + bits.Len8(b[len(b)-1]) - 1}, nil
+}
+
+func (br *reverseBitReader) peek(n uint8) uint64 {
+	start := br.pos - int(n)
+	lo := start
+	if lo < 0 {
+		lo = 0
+	}
+
+	width := int(n) - (lo - start)
+	if width <= 0 {
+		return 0
+	}
+
+	var x uint64
+	i := lo / 8
+	if i+8 <= len(br.b) {
+		x = binary.LittleEndian.Uint64(br.b[i:])
+	} else {
+		for j := 0; i+j < len(br.b); j++ {
+			x |= uint64(br.b[i+j]) << (8 * j)
+		}
+	}
+
+	x = x >> uint(lo%8) & (1<<uint(width) - 1)
+	return x << uint(lo-start)
+}
+
+func (br *reverseBitReader) bits(n uint8) uint64 {
+	v := br.peek(n)
+	br.pos -= int(n)
+	return v
+}
+
+type fseEntry struct {
+	symbol uint8
+	bits   uint8
+	base   uint16
+}
+
+type fseTable struct {
+	log     uint8
+	entries []fseEntry
+}
+
+// spreadFSESymbols lays symbols out over a table of 1<<log states the
+// way both the FSE encoder and decoder expect. Symbols with a
+// probability of "less than one", -1, take the last states.
+func spreadFSESymbols(distribution []int16, log uint8) ([]uint8, error) {
+	size := 1 << log
+	symbols := make([]uint8, size)
+	high := size - 1
+	for s, count := range distribution {
+		if count == -1 {
+			symbols[high] = uint8(s)
+			high--
+		}
+	}
+
+	pos, step, mask := 0, size>>1+size>>3+3, size-1
+	for s, count := range distribution {
+		for i := 0; i < int(count); i++ {
+			symbols[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, ErrInvalidZstd
+	}
+
+	return symbols, nil
+}
+
+func buildFSETable(distribution []int16, log uint8) (*fseTable, error) {
+	symbols, err := spreadFSESymbols(distribution, log)
+	if err != nil {
+		return nil, err
+	}
+
+	next := make([]int, len(distribution))
+	for s, count := range distribution {
+		next[s] = int(count)
+		if count == -1 {
+			next[s] = 1
+		}
+	}
+
+	size := 1 << log
+	entries := make([]fseEntry, size)
+	for i, s := range symbols {
+		state := next[s]
+		next[s]++
+		n := int(log) - (bits.Len(uint(state)) - 1)
+		entries[i] = fseEntry{symbol: s, bits: uint8(n), base: uint16(state<<n - size)}
+	}
+
+	return &fseTable{log: log, entries: entries}, nil
+}
+
+func mustBuildFSETable(distribution []int16, log uint8) *fseTable {
+	table, err := buildFSETable(distribution, log)
+	if err != nil {
+		panic(err)
+	}
+
+	return table
+}
+
+// readFSETable parses an FSE table description and returns the table
+// and the number of bytes the description took up.
+func readFSETable(b []byte, maxLog, maxSymbol int) (*fseTable, int, error) {
+	br := forwardBitReader{b: b}
+	log := int(br.bits(4)) + 5
+	if log > maxLog {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	var distribution []int16
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	n := log + 1
+	previousZero := false
+	for remaining > 1 && len(distribution) <= maxSymbol {
+		if previousZero {
+			for {
+				repeat := int(br.bits(2))
+				for i := 0; i < repeat; i++ {
+					distribution = append(distribution, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+			if len(distribution) > maxSymbol {
+				return nil, 0, ErrInvalidZstd
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		count := int(br.peek(n - 1))
+		if count < max {
+			br.pos += n - 1
+		} else {
+			count = int(br.bits(n))
+			if count >= threshold {
+				count -= max
+			}
+		}
+		count--
+
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		distribution = append(distribution, int16(count))
+		previousZero = count == 0
+		for remaining < threshold {
+			n--
+			threshold >>= 1
+		}
+	}
+
+	if remaining != 1 || br.pos > len(b)*8 {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	table, err := buildFSETable(distribution, uint8(log))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return table, (br.pos + 7) / 8, nil
+}
+
+type fseState struct {
+	table *fseTable
+	state uint64
+}
+
+func (s *fseState) init(br *reverseBitReader) {
+	s.state = br.bits(s.table.log)
+}
+
+func (s *fseState) symbol() uint8 {
+	return s.table.entries[s.state].symbol
+}
+
+func (s *fseState) update(br *reverseBitReader) {
+	e := s.table.entries[s.state]
+	s.state = uint64(e.base) + br.bits(e.bits)
+}
+
+type huffmanEntry struct {
+	symbol uint8
+	bits   uint8
+}
+
+type huffmanTable struct {
+	maxBits uint8
+	entries []huffmanEntry
+}
+
+// readHuffmanTable parses the weights a literals section's Huffman
+// code is described by and returns the decoding table and the number
+// of bytes the description took up.
+func readHuffmanTable(b []byte) (*huffmanTable, int, error) {
+	if len(b) < 1 {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	var weights [256]uint8
+	var count, n int
+	if b[0] >= 128 {
+		// Weights stored directly, four bits each.
+		count = int(b[0]) - 127
+		n = 1 + (count+1)/2
+		if len(b) < n {
+			return nil, 0, ErrInvalidZstd
+		}
+
+		for i := 0; i < count; i++ {
+			w := b[1+i/2]
+			if i%2 == 0 {
+				w >>= 4
+			}
+			weights[i] = w & 0xF
+		}
+	} else {
+		n = 1 + int(b[0])
+		if len(b) < n {
+			return nil, 0, ErrInvalidZstd
+		}
+
+		var err error
+		count, err = readHuffmanWeights(b[1:n], weights[:255])
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// The last symbol's weight is implied by the others: whatever
+	// makes the total a power of two.
+	total := 0
+	for _, w := range weights[:count] {
+		if w > zstdMaxHuffmanBits {
+			return nil, 0, ErrInvalidZstd
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	maxBits := bits.Len(uint(total))
+	rest := 1<<maxBits - total
+	if maxBits > zstdMaxHuffmanBits || rest&(rest-1) != 0 {
+		return nil, 0, ErrInvalidZstd
+	}
+	weights[count] = uint8(bits.Len(uint(rest)))
+	count++
+
+	// Codes are handed out to the longest first, in symbol order
+	// within each length.
+	var rankStart [zstdMaxHuffmanBits + 2]int
+	for _, w := range weights[:count] {
+		if w > 0 {
+			rankStart[w+1] += 1 << (w - 1)
+		}
+	}
+	for w := 2; w < len(rankStart); w++ {
+		rankStart[w] += rankStart[w-1]
+	}
+
+	entries := make([]huffmanEntry, 1<<maxBits)
+	for s, w := range weights[:count] {
+		if w == 0 {
+			continue
+		}
+
+		length := 1 << (w - 1)
+		for i := rankStart[w]; i < rankStart[w]+length; i++ {
+			entries[i] = huffmanEntry{symbol: uint8(s), bits: uint8(maxBits + 1 - int(w))}
+		}
+		rankStart[w] += length
+	}
+
+	return &huffmanTable{maxBits: uint8(maxBits), entries: entries}, n, nil
+}
+
+// readHuffmanWeights decodes FSE compressed Huffman weights, which
+// alternate between two states sharing one bitstream.
+func readHuffmanWeights(b []byte, weights []uint8) (int, error) {
+	table, n, err := readFSETable(b, 6, zstdMaxHuffmanBits)
+	if err != nil {
+		return 0, err
+	}
+
+	br, err := newReverseBitReader(b[n:])
+	if err != nil {
+		return 0, err
+	}
+
+	states := [2]fseState{{table: table}, {table: table}}
+	states[0].init(br)
+	states[1].init(br)
+	count := 0
+	for i := 0; ; i ^= 1 {
+		if count+2 > len(weights) {
+			return 0, ErrInvalidZstd
+		}
+
+		weights[count] = states[i].symbol()
+		count++
+		states[i].update(br)
+		if br.pos < 0 {
+			weights[count] = states[i^1].symbol()
+			count++
+			return count, nil
+		}
+	}
+}
+
+func (t *huffmanTable) decode(b []byte, dst []byte) error {
+	br, err := newReverseBitReader(b)
+	if err != nil {
+		return err
+	}
+
+	for i := range dst {
+		e := t.entries[br.peek(t.maxBits)]
+		dst[i] = e.symbol
+		br.pos -= int(e.bits)
+	}
+
+	if br.pos != 0 {
+		return ErrInvalidZstd
+	}
+
+	return nil
+}
+
+// decodeStreams decodes literals Huffman coded as one stream, or as
+// four following a table of the first three's sizes.
+func (t *huffmanTable) decodeStreams(b []byte, dst []byte, streams int) error {
+	if streams == 1 {
+		return t.decode(b, dst)
+	}
+
+	if len(b) < 6 {
+		return ErrInvalidZstd
+	}
+
+	segment := (len(dst) + 3) / 4
+	if 3*segment > len(dst) {
+		return ErrInvalidZstd
+	}
+
+	data := b[6:]
+	for i := 0; i < 4; i++ {
+		size := len(data)
+		out := dst[i*segment:]
+		if i < 3 {
+			size = int(binary.LittleEndian.Uint16(b[2*i:]))
+			out = out[:segment]
+		}
+		if size > len(data) {
+			return ErrInvalidZstd
+		}
+
+		if err := t.decode(data[:size], out); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+
+	return nil
+}
+
+type zstdReader struct {
+	r   io.Reader
+	err error
+
+	inFrame     bool
+	window      int
+	checksum    bool
+	contentSize int64
+	produced    int64
+	xxh         *xxhash64
+
+	// hist is the frame's output so far, or at least the window's
+	// worth of it. out is where what hasn't been read yet starts.
+	hist     []byte
+	out      int
+	literals []byte
+
+	huffman   *huffmanTable
+	sequences [3]*fseTable
+	offsets   zstdOffsets
+}
+
+func newZstdReader(r io.Reader) io.ReadCloser {
+	return &zstdReader{r: r, xxh: newXXHash64()}
+}
+
+func (zr *zstdReader) Read(p []byte) (int, error) {
+	for zr.out == len(zr.hist) {
+		if zr.err != nil {
+			return 0, zr.err
+		}
+		zr.err = zr.next()
+	}
+
+	n := copy(p, zr.hist[zr.out:])
+	zr.out += n
+	return n, nil
+}
+
+func (zr *zstdReader) Close() error {
+	return nil
+}
+
+// next reads the next frame header or block.
+func (zr *zstdReader) next() error {
+	if !zr.inFrame {
+		return zr.readFrameHeader()
+	}
+
+	// Only keep as much output as matches can refer back to.
+	if len(zr.hist) > 2*zr.window {
+		drop := len(zr.hist) - zr.window
+		zr.hist = append(zr.hist[:0], zr.hist[drop:]...)
+		zr.out -= drop
+	}
+
+	var header [3]byte
+	if _, err := io.ReadFull(zr.r, header[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	h := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	last := h&1 == 1
+	size := h >> 3
+	maxSize := zstdMaxBlockSize
+	if zr.window < maxSize {
+		maxSize = zr.window
+	}
+	if size > maxSize {
+		return ErrInvalidZstd
+	}
+
+	start := len(zr.hist)
+	switch h >> 1 & 3 {
+	case zstdBlockRaw:
+		zr.hist = append(zr.hist, make([]byte, size)...)
+		if _, err := io.ReadFull(zr.r, zr.hist[start:]); err != nil {
+			return unexpectedEOF(err)
+		}
+	case zstdBlockRLE:
+		var b [1]byte
+		if _, err := io.ReadFull(zr.r, b[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		for i := 0; i < size; i++ {
+			zr.hist = append(zr.hist, b[0])
+		}
+	case zstdBlockCompressed:
+		block := make([]byte, size)
+		if _, err := io.ReadFull(zr.r, block); err != nil {
+			return unexpectedEOF(err)
+		}
+		if err := zr.decodeBlock(block); err != nil {
+			return err
+		}
+		if len(zr.hist)-start > maxSize {
+			return ErrInvalidZstd
+		}
+	default:
+		return ErrInvalidZstd
+	}
+
+	zr.xxh.Write(zr.hist[start:])
+	zr.produced += int64(len(zr.hist) - start)
+	if last {
+		return zr.endFrame()
+	}
+
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+func (zr *zstdReader) readFrameHeader() error {
+	var b [8]byte
+	if _, err := io.ReadFull(zr.r, b[:4]); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return unexpectedEOF(err)
+	}
+
+	magic := binary.LittleEndian.Uint32(b[:])
+	if magic&zstdSkippableMask == zstdSkippableMagic {
+		if _, err := io.ReadFull(zr.r, b[:4]); err != nil {
+			return unexpectedEOF(err)
+		}
+		_, err := io.CopyN(ioutil.Discard, zr.r, int64(binary.LittleEndian.Uint32(b[:])))
+		return unexpectedEOF(err)
+	}
+	if magic != zstdMagic {
+		return ErrInvalidZstd
+	}
+
+	if _, err := io.ReadFull(zr.r, b[:1]); err != nil {
+		return unexpectedEOF(err)
+	}
+	descriptor := b[0]
+	singleSegment := descriptor&0x20 != 0
+	if descriptor&0x08 != 0 {
+		return ErrInvalidZstd
+	}
+
+	window := 0
+	if !singleSegment {
+		if _, err := io.ReadFull(zr.r, b[:1]); err != nil {
+			return unexpectedEOF(err)
+		}
+		base := 1 << (10 + b[0]>>3)
+		window = base + base/8*int(b[0]&7)
+	}
+
+	dictionaryIDSize := [4]int{0, 1, 2, 4}[descriptor&3]
+	if _, err := io.ReadFull(zr.r, b[:dictionaryIDSize]); err != nil {
+		return unexpectedEOF(err)
+	}
+	for _, c := range b[:dictionaryIDSize] {
+		if c != 0 {
+			return ErrZstdDictionary
+		}
+	}
+
+	contentSizeSize := [4]int{0, 2, 4, 8}[descriptor>>6]
+	if contentSizeSize == 0 && singleSegment {
+		contentSizeSize = 1
+	}
+	b = [8]byte{}
+	if _, err := io.ReadFull(zr.r, b[:contentSizeSize]); err != nil {
+		return unexpectedEOF(err)
+	}
+	zr.contentSize = -1
+	if contentSizeSize > 0 {
+		zr.contentSize = int64(binary.LittleEndian.Uint64(b[:]))
+		if contentSizeSize == 2 {
+			zr.contentSize += 256
+		}
+	}
+
+	if singleSegment {
+		if zr.contentSize >= zstdMaxWindowSize {
+			return ErrInvalidZstd
+		}
+		window = int(zr.contentSize)
+	}
+	if window > zstdMaxWindowSize {
+		return ErrInvalidZstd
+	}
+
+	zr.inFrame = true
+	zr.window = window
+	zr.checksum = descriptor&0x04 != 0
+	zr.produced = 0
+	zr.xxh.Reset()
+	zr.hist = zr.hist[:0]
+	zr.out = 0
+	zr.huffman = nil
+	zr.sequences = [3]*fseTable{}
+	zr.offsets = zstdInitialOffsets
+	return nil
+}
+
+func (zr *zstdReader) endFrame() error {
+	zr.inFrame = false
+	if zr.contentSize != -1 && zr.produced != zr.contentSize {
+		return ErrInvalidZstd
+	}
+
+	if zr.checksum {
+		var b [4]byte
+		if _, err := io.ReadFull(zr.r, b[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		if binary.LittleEndian.Uint32(b[:]) != uint32(zr.xxh.Sum64()) {
+			return ErrZstdChecksum
+		}
+	}
+
+	return nil
+}
+
+func (zr *zstdReader) decodeBlock(block []byte) error {
+	literals, n, err := zr.decodeLiterals(block)
+	if err != nil {
+		return err
+	}
+
+	return zr.decodeSequences(block[n:], literals)
+}
+
+// decodeLiterals returns a block's literals and the size of the
+// section they were in.
+func (zr *zstdReader) decodeLiterals(b []byte) ([]byte, int, error) {
+	if len(b) < 1 {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	kind, format := b[0]&3, b[0]>>2&3
+	if kind == zstdBlockRaw || kind == zstdBlockRLE {
+		var size, n int
+		switch format {
+		case 0, 2:
+			size, n = int(b[0]>>3), 1
+		case 1:
+			if len(b) < 2 {
+				return nil, 0, ErrInvalidZstd
+			}
+			size, n = int(b[0]>>4)|int(b[1])<<4, 2
+		case 3:
+			if len(b) < 3 {
+				return nil, 0, ErrInvalidZstd
+			}
+			size, n = int(b[0]>>4)|int(b[1])<<4|int(b[2])<<12, 3
+		}
+		if size > zstdMaxBlockSize {
+			return nil, 0, ErrInvalidZstd
+		}
+
+		if kind == zstdBlockRaw {
+			if len(b) < n+size {
+				return nil, 0, ErrInvalidZstd
+			}
+			return b[n : n+size], n + size, nil
+		}
+
+		if len(b) < n+1 {
+			return nil, 0, ErrInvalidZstd
+		}
+		zr.literals = zr.literals[:0]
+		for i := 0; i < size; i++ {
+			zr.literals = append(zr.literals, b[n])
+		}
+		return zr.literals, n + 1, nil
+	}
+
+	// Huffman coded, with a new table or the previous block's.
+	streams, sizeBits, n := 4, uint(10), 3
+	switch format {
+	case 0:
+		streams = 1
+	case 2:
+		sizeBits, n = 14, 4
+	case 3:
+		sizeBits, n = 18, 5
+	}
+	if len(b) < n {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	var h uint64
+	for i := n - 1; i >= 0; i-- {
+		h = h<<8 | uint64(b[i])
+	}
+	mask := uint64(1)<<sizeBits - 1
+	size := int(h >> 4 & mask)
+	compressedSize := int(h >> (4 + sizeBits) & mask)
+	if size > zstdMaxBlockSize || len(b) < n+compressedSize {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	data := b[n : n+compressedSize]
+	if kind == zstdBlockCompressed {
+		table, tableSize, err := readHuffmanTable(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		zr.huffman = table
+		data = data[tableSize:]
+	} else if zr.huffman == nil {
+		return nil, 0, ErrInvalidZstd
+	}
+
+	if cap(zr.literals) < size {
+		zr.literals = make([]byte, size)
+	}
+	zr.literals = zr.literals[:size]
+	if err := zr.huffman.decodeStreams(data, zr.literals, streams); err != nil {
+		return nil, 0, err
+	}
+
+	return zr.literals, n + compressedSize, nil
+}
+
+// readSequenceTable sets up the table for the kind'th field of
+// sequences and returns the number of bytes its description took up.
+func (zr *zstdReader) readSequenceTable(kind int, mode byte, b []byte) (int, error) {
+	k := zstdSequenceKinds[kind]
+	switch mode {
+	case 0:
+		zr.sequences[kind] = k.predefined
+		return 0, nil
+	case 1:
+		if len(b) < 1 || int(b[0]) > k.maxSymbol {
+			return 0, ErrInvalidZstd
+		}
+		zr.sequences[kind] = &fseTable{entries: []fseEntry{{symbol: b[0]}}}
+		return 1, nil
+	case 2:
+		table, n, err := readFSETable(b, k.maxLog, k.maxSymbol)
+		if err != nil {
+			return 0, err
+		}
+		zr.sequences[kind] = table
+		return n, nil
+	default:
+		if zr.sequences[kind] == nil {
+			return 0, ErrInvalidZstd
+		}
+		return 0, nil
+	}
+}
+
+func (zr *zstdReader) decodeSequences(b []byte, literals []byte) error {
+	if len(b) < 1 {
+		return ErrInvalidZstd
+	}
+
+	count, n := int(b[0]), 1
+	switch {
+	case count == 0:
+		if len(b) != 1 {
+			return ErrInvalidZstd
+		}
+		zr.hist = append(zr.hist, literals...)
+		return nil
+	case count == 255:
+		if len(b) < 3 {
+			return ErrInvalidZstd
+		}
+		count, n = int(b[1])|int(b[2])<<8+0x7F00, 3
+	case count >= 128:
+		if len(b) < 2 {
+			return ErrInvalidZstd
+		}
+		count, n = (count-128)<<8|int(b[1]), 2
+	}
+
+	if len(b) < n+1 {
+		return ErrInvalidZstd
+	}
+	modes := b[n]
+	n++
+	if modes&3 != 0 {
+		return ErrInvalidZstd
+	}
+
+	for kind := 0; kind < 3; kind++ {
+		size, err := zr.readSequenceTable(kind, modes>>(6-2*kind)&3, b[n:])
+		if err != nil {
+			return err
+		}
+		n += size
+	}
+
+	br, err := newReverseBitReader(b[n:])
+	if err != nil {
+		return err
+	}
+
+	literalLength := fseState{table: zr.sequences[0]}
+	offset := fseState{table: zr.sequences[1]}
+	matchLength := fseState{table: zr.sequences[2]}
+	literalLength.init(br)
+	offset.init(br)
+	matchLength.init(br)
+
+	for i := 0; i < count; i++ {
+		ofCode, mlCode, llCode := offset.symbol(), matchLength.symbol(), literalLength.symbol()
+		if ofCode > zstdMaxOffsetCode || mlCode > zstdMaxMatchLength || llCode > zstdMaxLiteralLength {
+			return ErrInvalidZstd
+		}
+
+		offsetValue := int(1<<ofCode + br.bits(ofCode))
+		ml := int(zstdMatchLengthBase[mlCode]) + int(br.bits(zstdMatchLengthBits[mlCode]))
+		ll := int(zstdLiteralLengthBase[llCode]) + int(br.bits(zstdLiteralLengthBits[llCode]))
+		if i != count-1 {
+			literalLength.update(br)
+			matchLength.update(br)
+			offset.update(br)
+		}
+
+		if ll > len(literals) {
+			return ErrInvalidZstd
+		}
+		zr.hist = append(zr.hist, literals[:ll]...)
+		literals = literals[ll:]
+
+		o := zr.offsets.resolve(offsetValue, ll)
+		if o <= 0 || o > len(zr.hist) {
+			return ErrInvalidZstd
+		}
+
+		start := len(zr.hist) - o
+		if o >= ml {
+			zr.hist = append(zr.hist, zr.hist[start:start+ml]...)
+		} else {
+			for j := 0; j < ml; j++ {
+				zr.hist = append(zr.hist, zr.hist[start+j])
+			}
+		}
+	}
+
+	if br.pos != 0 {
+		return ErrInvalidZstd
+	}
+
+	zr.hist = append(zr.hist, literals...)
+	return nil
+}
+
+// zstdOffsets are the last three offsets, which sequences can repeat
+// more cheaply than spelling out a new one.
+type zstdOffsets [3]int
+
+var zstdInitialOffsets = zstdOffsets{1, 4, 8}
+
+// resolve turns an offset value into a distance back. Values up to 3
+// repeat one of the last three offsets.
+func (r *zstdOffsets) resolve(value, literalLength int) int {
+	if value > 3 {
+		o := value - 3
+		*r = zstdOffsets{o, r[0], r[1]}
+		return o
+	}
+
+	if literalLength == 0 {
+		value++
+	}
+
+	var o int
+	switch value {
+	case 1:
+		return r[0]
+	case 2:
+		o = r[1]
+		r[1] = r[0]
+		r[0] = o
+		return o
+	case 3:
+		o = r[2]
+	default:
+		o = r[0] - 1
+	}
+
+	*r = zstdOffsets{o, r[0], r[1]}
+	return o
+}
+
+var (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 is XXH64 with a zero seed, which zstd frames are checksummed
+// with.
+type xxhash64 struct {
+	v     [4]uint64
+	buf   [32]byte
+	n     int
+	total uint64
+}
+
+func newXXHash64() *xxhash64 {
+	h := &xxhash64{}
+	h.Reset()
+	return h
+}
+
+func (h *xxhash64) Reset() {
+	h.v = [4]uint64{xxhPrime1 + xxhPrime2, xxhPrime2, 0, -xxhPrime1}
+	h.n = 0
+	h.total = 0
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	return bits.RotateLeft64(acc, 31) * xxhPrime1
+}
+
+func xxhMergeRound(acc, v uint64) uint64 {
+	acc ^= xxhRound(0, v)
+	return acc*xxhPrime1 + xxhPrime4
+}
+
+func (h *xxhash64) stripe(b []byte) {
+	for i := range h.v {
+		h.v[i] = xxhRound(h.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (h *xxhash64) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+
+	if h.n > 0 {
+		c := copy(h.buf[h.n:], p)
+		h.n += c
+		p = p[c:]
+		if h.n < len(h.buf) {
+			return n, nil
+		}
+		h.stripe(h.buf[:])
+		h.n = 0
+	}
+
+	for len(p) >= len(h.buf) {
+		h.stripe(p)
+		p = p[len(h.buf):]
+	}
+
+	h.n = copy(h.buf[:], p)
+	return n, nil
+}
+
+func (h *xxhash64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = xxhMergeRound(acc, v)
+		}
+	} else {
+		acc = xxhPrime5
+	}
+	acc += h.total
+
+	p := h.buf[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(p)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, c := range p {
+		acc ^= uint64(c) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,850 @@
+package gozip
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	zstdWindowLog   = 20
+	zstdWindowSize  = 1 << zstdWindowLog
+	zstdHashLog     = 17
+	zstdSearchDepth = 8
+	zstdMinMatch    = 4
+	// Matches at least this long are taken without looking further.
+	zstdNiceLength = 32
+)
+
+// bitWriter writes a bitstream least significant bit first, which is
+// how zstd's backwards read bitstreams are produced.
+type bitWriter struct {
+	out []byte
+	acc uint64
+	n   uint
+}
+
+func (bw *bitWriter) bits(v uint64, n uint8) {
+	bw.acc |= (v & (1<<n - 1)) << bw.n
+	bw.n += uint(n)
+	for bw.n >= 8 {
+		bw.out = append(bw.out, byte(bw.acc))
+		bw.acc >>= 8
+		bw.n -= 8
+	}
+}
+
+// close marks where the bitstream starts for a reader coming from the
+// end.
+func (bw *bitWriter) close() []byte {
+	bw.bits(1, 1)
+	if bw.n > 0 {
+		bw.out = append(bw.out, byte(bw.acc))
+	}
+
+	return bw.out
+}
+
+type fseSymbolTransform struct {
+	deltaFindState int
+	deltaNbBits    uint32
+}
+
+type fseEncoder struct {
+	distribution []int16
+	log          uint8
+	states       []uint32
+	transforms   []fseSymbolTransform
+}
+
+func newFSEEncoder(distribution []int16, log uint8) *fseEncoder {
+	symbols, err := spreadFSESymbols(distribution, log)
+	if err != nil {
+		panic(err)
+	}
+
+	size := 1 << log
+	next := make([]int, len(distribution)+1)
+	for s, count := range distribution {
+		if count == -1 {
+			count = 1
+		}
+		next[s+1] = next[s] + int(count)
+	}
+
+	states := make([]uint32, size)
+	for i, s := range symbols {
+		states[next[s]] = uint32(size + i)
+		next[s]++
+	}
+
+	transforms := make([]fseSymbolTransform, len(distribution))
+	total := 0
+	for s, count := range distribution {
+		switch count {
+		case 0:
+			transforms[s].deltaNbBits = uint32(int(log+1)<<16 - size)
+		case -1, 1:
+			transforms[s] = fseSymbolTransform{total - 1, uint32(int(log)<<16 - size)}
+			total++
+		default:
+			maxBitsOut := int(log) - (bits.Len(uint(count-1)) - 1)
+			minStatePlus := int(count) << maxBitsOut
+			transforms[s] = fseSymbolTransform{total - int(count), uint32(maxBitsOut<<16 - minStatePlus)}
+			total += int(count)
+		}
+	}
+
+	return &fseEncoder{distribution: distribution, log: log, states: states, transforms: transforms}
+}
+
+// fseEncoderState is the state of one FSE coded field. Without an
+// encoder it is for a field whose codes are all the same, which takes
+// no bits.
+type fseEncoderState struct {
+	e     *fseEncoder
+	state uint32
+}
+
+func (s *fseEncoderState) init(symbol uint8) {
+	if s.e == nil {
+		return
+	}
+
+	t := s.e.transforms[symbol]
+	n := (t.deltaNbBits + 1<<15) >> 16
+	value := n<<16 - t.deltaNbBits
+	s.state = s.e.states[int(value>>n)+t.deltaFindState]
+}
+
+func (s *fseEncoderState) encode(bw *bitWriter, symbol uint8) {
+	if s.e == nil {
+		return
+	}
+
+	t := s.e.transforms[symbol]
+	n := (s.state + t.deltaNbBits) >> 16
+	bw.bits(uint64(s.state), uint8(n))
+	s.state = s.e.states[int(s.state>>n)+t.deltaFindState]
+}
+
+func (s *fseEncoderState) flush(bw *bitWriter) {
+	if s.e == nil {
+		return
+	}
+
+	bw.bits(uint64(s.state), s.e.log)
+}
+
+var zstdPredefinedEncoders = [3]*fseEncoder{
+	newFSEEncoder(zstdLiteralLengthDistribution, 6),
+	newFSEEncoder(zstdOffsetDistribution, 5),
+	newFSEEncoder(zstdMatchLengthDistribution, 6),
+}
+
+// cost estimates how many bits coding symbols with the given counts
+// would take, not counting extra bits.
+func (e *fseEncoder) cost(counts []int) float64 {
+	bits := 0.0
+	for s, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if s >= len(e.distribution) || e.distribution[s] == 0 {
+			return math.Inf(1)
+		}
+
+		p := float64(e.distribution[s])
+		if p < 0 {
+			p = 1
+		}
+		bits += float64(c) * (float64(e.log) - math.Log2(p))
+	}
+
+	return bits
+}
+
+// zstdCode returns the code whose base value is the largest not above
+// v, and the extra bits that make up the difference.
+func zstdCode(base []uint32, v int) (uint8, uint64) {
+	code := sort.Search(len(base), func(i int) bool { return int(base[i]) > v }) - 1
+	return uint8(code), uint64(v - int(base[code]))
+}
+
+// huffmanLengths returns code lengths of at most maxBits for the
+// symbols with non-zero frequencies, halving frequencies until the
+// code fits.
+func huffmanLengths(freqs []int, maxBits int) []uint8 {
+	freqs = append([]int{}, freqs...)
+	for {
+		lengths := huffmanTreeLengths(freqs)
+		fits := true
+		for _, l := range lengths {
+			if int(l) > maxBits {
+				fits = false
+			}
+		}
+		if fits {
+			return lengths
+		}
+
+		for i := range freqs {
+			freqs[i] = (freqs[i] + 1) / 2
+		}
+	}
+}
+
+func huffmanTreeLengths(freqs []int) []uint8 {
+	type node struct {
+		freq   int
+		parent int
+	}
+
+	var leaves []int
+	for s, f := range freqs {
+		if f > 0 {
+			leaves = append(leaves, s)
+		}
+	}
+	sort.SliceStable(leaves, func(i, j int) bool { return freqs[leaves[i]] < freqs[leaves[j]] })
+
+	// Leaves come first, sorted, then internal nodes in the order
+	// they're made, which is also sorted. The two smallest are always
+	// at the front of one or the other.
+	nodes := make([]node, len(leaves), 2*len(leaves)-1)
+	for i, s := range leaves {
+		nodes[i] = node{freq: freqs[s], parent: -1}
+	}
+
+	nextLeaf, nextInternal := 0, len(leaves)
+	smallest := func() int {
+		if nextLeaf < len(leaves) && (nextInternal == len(nodes) || nodes[nextLeaf].freq <= nodes[nextInternal].freq) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextInternal++
+		return nextInternal - 1
+	}
+	for len(nodes) < cap(nodes) {
+		a, b := smallest(), smallest()
+		nodes = append(nodes, node{freq: nodes[a].freq + nodes[b].freq, parent: -1})
+		nodes[a].parent = len(nodes) - 1
+		nodes[b].parent = len(nodes) - 1
+	}
+
+	depths := make([]uint8, len(nodes))
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depths[i] = depths[nodes[i].parent] + 1
+	}
+
+	lengths := make([]uint8, len(freqs))
+	for i, s := range leaves {
+		lengths[s] = depths[i]
+	}
+
+	return lengths
+}
+
+// appendLiteralsHeader appends a raw or RLE literals section header.
+func appendLiteralsHeader(dst []byte, kind byte, size int) []byte {
+	switch {
+	case size < 32:
+		return append(dst, kind|byte(size)<<3)
+	case size < 4096:
+		return append(dst, kind|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(dst, kind|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// appendLiterals appends a literals section, Huffman coding the
+// literals if that makes them smaller.
+func appendLiterals(dst []byte, literals []byte) []byte {
+	if len(literals) == 0 {
+		return appendLiteralsHeader(dst, zstdBlockRaw, 0)
+	}
+
+	var freqs [256]int
+	for _, c := range literals {
+		freqs[c]++
+	}
+	if freqs[literals[0]] == len(literals) {
+		return append(appendLiteralsHeader(dst, zstdBlockRLE, len(literals)), literals[0])
+	}
+
+	if compressed := huffmanLiterals(literals, freqs[:]); compressed != nil {
+		return append(dst, compressed...)
+	}
+
+	return append(appendLiteralsHeader(dst, zstdBlockRaw, len(literals)), literals...)
+}
+
+// huffmanLiterals returns a Huffman coded literals section, or nil if
+// it wouldn't be smaller than storing the literals. Weights are only
+// written directly, four bits each, so the last symbol used must be
+// below 128.
+func huffmanLiterals(literals []byte, freqs []int) []byte {
+	last := 0
+	for s, f := range freqs {
+		if f > 0 {
+			last = s
+		}
+	}
+	if last > 128 || len(literals) < 64 {
+		return nil
+	}
+
+	lengths := huffmanLengths(freqs[:last+1], zstdMaxHuffmanBits)
+	maxBits := uint8(0)
+	for _, l := range lengths {
+		if l > maxBits {
+			maxBits = l
+		}
+	}
+
+	// Same code assignment as readHuffmanTable.
+	weights := make([]uint8, len(lengths))
+	var rankStart [zstdMaxHuffmanBits + 2]int
+	for s, l := range lengths {
+		if l > 0 {
+			weights[s] = maxBits + 1 - l
+			rankStart[weights[s]+1] += 1 << (weights[s] - 1)
+		}
+	}
+	for w := 2; w < len(rankStart); w++ {
+		rankStart[w] += rankStart[w-1]
+	}
+	codes := make([]uint64, len(lengths))
+	for s, w := range weights {
+		if w > 0 {
+			codes[s] = uint64(rankStart[w] >> (w - 1))
+			rankStart[w] += 1 << (w - 1)
+		}
+	}
+
+	// The last symbol's weight is implied.
+	table := []byte{byte(127 + last)}
+	for i := 0; i < last; i += 2 {
+		b := weights[i] << 4
+		if i+1 < last {
+			b |= weights[i+1]
+		}
+		table = append(table, b)
+	}
+
+	encode := func(src []byte) []byte {
+		var bw bitWriter
+		for i := len(src) - 1; i >= 0; i-- {
+			bw.bits(codes[src[i]], lengths[src[i]])
+		}
+		return bw.close()
+	}
+
+	format, sizeBits, headerSize := byte(0), uint(10), 3
+	streams := table
+	if len(literals) < 1024 {
+		streams = append(streams, encode(literals)...)
+	} else {
+		segment := (len(literals) + 3) / 4
+		var encoded [4][]byte
+		for i := range encoded {
+			end := (i + 1) * segment
+			if end > len(literals) {
+				end = len(literals)
+			}
+			encoded[i] = encode(literals[i*segment : end])
+		}
+
+		for _, e := range encoded[:3] {
+			if len(e) > 0xFFFF {
+				return nil
+			}
+			streams = append(streams, byte(len(e)), byte(len(e)>>8))
+		}
+		for _, e := range encoded {
+			streams = append(streams, e...)
+		}
+
+		switch {
+		case len(literals) < 1<<14 && len(streams) < 1<<14:
+			format, sizeBits, headerSize = 2, 14, 4
+		default:
+			format, sizeBits, headerSize = 3, 18, 5
+		}
+	}
+
+	if len(streams) >= 1<<sizeBits || headerSize+len(streams) >= len(literals) {
+		return nil
+	}
+
+	h := uint64(zstdBlockCompressed) | uint64(format)<<2 |
+		uint64(len(literals))<<4 | uint64(len(streams))<<(4+sizeBits)
+	var header [8]byte
+	binary.LittleEndian.PutUint64(header[:], h)
+	return append(header[:headerSize], streams...)
+}
+
+type zstdSequence struct {
+	literalLength int
+	matchLength   int
+	offset        int
+}
+
+// appendSequences appends a sequences section, updating offsets as
+// the decoder will.
+func appendSequences(dst []byte, sequences []zstdSequence, offsets *zstdOffsets) []byte {
+	n := len(sequences)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7F00:
+		dst = append(dst, byte(n>>8+128), byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+
+	// Codes in the order their tables are written: literal length,
+	// offset, match length.
+	var codes [3][]uint8
+	var extra [3][]uint64
+	for i := range codes {
+		codes[i] = make([]uint8, n)
+		extra[i] = make([]uint64, n)
+	}
+	for i, s := range sequences {
+		codes[0][i], extra[0][i] = zstdCode(zstdLiteralLengthBase[:], s.literalLength)
+		codes[2][i], extra[2][i] = zstdCode(zstdMatchLengthBase[:], s.matchLength)
+		offsetValue := offsets.value(s.offset, s.literalLength)
+		offsets.resolve(offsetValue, s.literalLength)
+		codes[1][i] = uint8(bits.Len(uint(offsetValue)) - 1)
+		extra[1][i] = uint64(offsetValue - 1<<codes[1][i])
+	}
+
+	var modes byte
+	var tables []byte
+	var states [3]fseEncoderState
+	for kind := range codes {
+		mode, e, table := chooseSequenceEncoder(kind, codes[kind])
+		modes |= mode << (6 - 2*kind)
+		tables = append(tables, table...)
+		states[kind].e = e
+	}
+	dst = append(append(dst, modes), tables...)
+
+	// Sequences are written last first so they are read first first.
+	var bw bitWriter
+	ll, of, ml := &states[0], &states[1], &states[2]
+	for i := n - 1; i >= 0; i-- {
+		if i == n-1 {
+			ml.init(codes[2][i])
+			of.init(codes[1][i])
+			ll.init(codes[0][i])
+		} else {
+			of.encode(&bw, codes[1][i])
+			ml.encode(&bw, codes[2][i])
+			ll.encode(&bw, codes[0][i])
+		}
+
+		bw.bits(extra[0][i], zstdLiteralLengthBits[codes[0][i]])
+		bw.bits(extra[2][i], zstdMatchLengthBits[codes[2][i]])
+		bw.bits(extra[1][i], codes[1][i])
+	}
+	ml.flush(&bw)
+	of.flush(&bw)
+	ll.flush(&bw)
+
+	return append(dst, bw.close()...)
+}
+
+// chooseSequenceEncoder picks how the kind'th field of a block's
+// sequences is coded, returning the mode, the encoder, which is nil if
+// every code is the same, and the table description to write.
+func chooseSequenceEncoder(kind int, codes []uint8) (byte, *fseEncoder, []byte) {
+	counts := make([]int, zstdSequenceKinds[kind].maxSymbol+1)
+	distinct := 0
+	for _, c := range codes {
+		if counts[c] == 0 {
+			distinct++
+		}
+		counts[c]++
+	}
+	if distinct == 1 {
+		return 1, nil, []byte{codes[0]}
+	}
+
+	predefined := zstdPredefinedEncoders[kind]
+	log := uint8(bits.Len(uint(len(codes))) - 1)
+	if log < 5 {
+		log = 5
+	}
+	for 1<<log < 2*distinct {
+		log++
+	}
+	if max := uint8(zstdSequenceKinds[kind].maxLog); log > max {
+		log = max
+	}
+
+	distribution := normalizeFSECounts(counts, len(codes), log)
+	e := newFSEEncoder(distribution, log)
+	table := writeFSETable(distribution, log)
+	if float64(8*len(table))+e.cost(counts) < predefined.cost(counts) {
+		return 2, e, table
+	}
+
+	return 0, predefined, nil
+}
+
+// normalizeFSECounts scales counts to add up to 1<<log, giving every
+// symbol that occurs at least the "less than one" probability, -1.
+func normalizeFSECounts(counts []int, total int, log uint8) []int16 {
+	last := len(counts) - 1
+	for counts[last] == 0 {
+		last--
+	}
+
+	size := 1 << log
+	distribution := make([]int16, last+1)
+	sum, largest := 0, 0
+	for s, c := range counts[:last+1] {
+		if c == 0 {
+			continue
+		}
+
+		n := c * size / total
+		if n == 0 {
+			distribution[s] = -1
+			sum++
+		} else {
+			distribution[s] = int16(n)
+			sum += n
+		}
+		if c > counts[largest] {
+			largest = s
+		}
+	}
+
+	for ; sum > size; sum-- {
+		m := largest
+		for s, n := range distribution {
+			if n > distribution[m] {
+				m = s
+			}
+		}
+		distribution[m]--
+	}
+	distribution[largest] += int16(size - sum)
+
+	return distribution
+}
+
+// writeFSETable is the inverse of readFSETable.
+func writeFSETable(distribution []int16, log uint8) []byte {
+	var bw bitWriter
+	bw.bits(uint64(log-5), 4)
+
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	n := log + 1
+	previousZero := false
+	for s := 0; remaining > 1; {
+		if previousZero {
+			zeros := 0
+			for distribution[s] == 0 {
+				zeros++
+				s++
+			}
+			for ; zeros >= 3; zeros -= 3 {
+				bw.bits(3, 2)
+			}
+			bw.bits(uint64(zeros), 2)
+		}
+
+		count := int(distribution[s])
+		s++
+		max := 2*threshold - 1 - remaining
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			bw.bits(uint64(count), n-1)
+		} else {
+			bw.bits(uint64(count), n)
+		}
+
+		previousZero = count == 1
+		for remaining < threshold {
+			n--
+			threshold >>= 1
+		}
+	}
+
+	if bw.n > 0 {
+		bw.out = append(bw.out, byte(bw.acc))
+	}
+	return bw.out
+}
+
+// value is the inverse of resolve: the offset value that refers to
+// offset, as a repeat if it is one.
+func (r *zstdOffsets) value(offset, literalLength int) int {
+	repeats := [3]int{r[0], r[1], r[2]}
+	if literalLength == 0 {
+		repeats = [3]int{r[1], r[2], r[0] - 1}
+	}
+
+	for i, o := range repeats {
+		if o == offset {
+			return i + 1
+		}
+	}
+
+	return offset + 3
+}
+
+// zstdWriter compresses to a single zstd frame, finding matches in a
+// window of the last 1MiB with hash chains.
+type zstdWriter struct {
+	w      io.Writer
+	err    error
+	header bool
+	xxh    *xxhash64
+
+	// hist holds the window followed by data not yet compressed,
+	// which starts at pending. head and chain hold positions in hist
+	// plus one, so zero means none. Positions before inserted are in
+	// them.
+	hist     []byte
+	pending  int
+	inserted int
+	offsets  zstdOffsets
+	head     []int32
+	chain    []int32
+}
+
+func newZstdWriter(w io.Writer) *zstdWriter {
+	return &zstdWriter{
+		w:       w,
+		xxh:     newXXHash64(),
+		offsets: zstdInitialOffsets,
+		head:    make([]int32, 1<<zstdHashLog),
+		chain:   make([]int32, zstdWindowSize),
+	}
+}
+
+func (zw *zstdWriter) Write(p []byte) (int, error) {
+	if zw.err != nil {
+		return 0, zw.err
+	}
+
+	zw.xxh.Write(p)
+	zw.hist = append(zw.hist, p...)
+	// The last block has to be marked as such, so always leave some
+	// data for Close.
+	for len(zw.hist)-zw.pending > zstdMaxBlockSize {
+		if zw.err = zw.writeBlock(zw.pending+zstdMaxBlockSize, false); zw.err != nil {
+			return 0, zw.err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last block and the checksum, it does not close the
+// underlying writer.
+func (zw *zstdWriter) Close() error {
+	if zw.err != nil {
+		return zw.err
+	}
+
+	if zw.err = zw.writeBlock(len(zw.hist), true); zw.err != nil {
+		return zw.err
+	}
+
+	var checksum [4]byte
+	binary.LittleEndian.PutUint32(checksum[:], uint32(zw.xxh.Sum64()))
+	_, zw.err = zw.w.Write(checksum[:])
+	return zw.err
+}
+
+// writeBlock compresses hist from pending to end into a block.
+func (zw *zstdWriter) writeBlock(end int, last bool) error {
+	if !zw.header {
+		zw.header = true
+		// Checksummed, with no content size and a 1MiB window.
+		header := []byte{0, 0, 0, 0, 0x04, (zstdWindowLog - 10) << 3}
+		binary.LittleEndian.PutUint32(header, zstdMagic)
+		if _, err := zw.w.Write(header); err != nil {
+			return err
+		}
+	}
+
+	start := zw.pending
+	offsets := zw.offsets
+	literals, sequences := zw.findMatches(start, end)
+	block := appendSequences(appendLiterals(nil, literals), sequences, &zw.offsets)
+	kind := zstdBlockCompressed
+	if len(block) >= end-start {
+		// Stored blocks leave the decoder's offsets alone.
+		block = zw.hist[start:end]
+		kind = zstdBlockRaw
+		zw.offsets = offsets
+	}
+
+	h := len(block)<<3 | kind<<1
+	if last {
+		h |= 1
+	}
+	if _, err := zw.w.Write([]byte{byte(h), byte(h >> 8), byte(h >> 16)}); err != nil {
+		return err
+	}
+	if _, err := zw.w.Write(block); err != nil {
+		return err
+	}
+
+	zw.pending = end
+	zw.slide()
+	return nil
+}
+
+// slide drops whatever has fallen out of the window from hist. It
+// drops whole windows so positions keep their place in chain.
+func (zw *zstdWriter) slide() {
+	drop := (zw.pending - zstdWindowSize) / zstdWindowSize * zstdWindowSize
+	if drop <= 0 {
+		return
+	}
+
+	zw.hist = append(zw.hist[:0], zw.hist[drop:]...)
+	zw.pending -= drop
+	zw.inserted -= drop
+	for _, table := range [][]int32{zw.head, zw.chain} {
+		for i, p := range table {
+			if int(p) > drop {
+				table[i] = p - int32(drop)
+			} else {
+				table[i] = 0
+			}
+		}
+	}
+}
+
+func (zw *zstdWriter) hash(i int) uint32 {
+	return binary.LittleEndian.Uint32(zw.hist[i:]) * 2654435761 >> (32 - zstdHashLog)
+}
+
+// insert adds the positions up to and including i to the hash chains.
+func (zw *zstdWriter) insert(i int) {
+	for ; zw.inserted <= i && zw.inserted+zstdMinMatch <= len(zw.hist); zw.inserted++ {
+		h := zw.hash(zw.inserted)
+		zw.chain[zw.inserted&(zstdWindowSize-1)] = zw.head[h]
+		zw.head[h] = int32(zw.inserted + 1)
+	}
+}
+
+func (zw *zstdWriter) matchLength(candidate, i, end int) int {
+	n := 0
+	for ; i+n+8 <= end; n += 8 {
+		diff := binary.LittleEndian.Uint64(zw.hist[candidate+n:]) ^ binary.LittleEndian.Uint64(zw.hist[i+n:])
+		if diff != 0 {
+			return n + bits.TrailingZeros64(diff)/8
+		}
+	}
+
+	for i+n < end && zw.hist[candidate+n] == zw.hist[i+n] {
+		n++
+	}
+
+	return n
+}
+
+// longestMatch returns the length and distance of the longest match
+// for i found in its hash chain, not going past end. i must already be
+// inserted. A match at the repeat distance is preferred unless another
+// is more than a byte longer, since repeats are cheaper to code.
+func (zw *zstdWriter) longestMatch(i, end, repeat int) (int, int) {
+	best, distance := 0, 0
+	if repeat <= i && repeat < zstdWindowSize {
+		if n := zw.matchLength(i-repeat, i, end); n >= zstdMinMatch {
+			best, distance = n, repeat
+		}
+	}
+
+	candidate := int(zw.chain[i&(zstdWindowSize-1)]) - 1
+	for depth := 0; candidate >= 0 && depth < zstdSearchDepth; depth++ {
+		if i-candidate >= zstdWindowSize || i+best == end || best >= zstdNiceLength {
+			break
+		}
+
+		if zw.hist[candidate+best] == zw.hist[i+best] {
+			n := zw.matchLength(candidate, i, end)
+			if n > best && (distance != repeat || n > best+1) {
+				best, distance = n, i-candidate
+			}
+		}
+
+		next := int(zw.chain[candidate&(zstdWindowSize-1)]) - 1
+		if next >= candidate {
+			break
+		}
+		candidate = next
+	}
+
+	return best, distance
+}
+
+// findMatches splits hist from start to end into literals and
+// sequences, matching lazily: a match is only taken if the next
+// position doesn't have a longer one.
+func (zw *zstdWriter) findMatches(start, end int) ([]byte, []zstdSequence) {
+	var literals []byte
+	var sequences []zstdSequence
+	repeat := zw.offsets[0]
+	literalStart := start
+	i := start
+	for i+zstdMinMatch <= end {
+		zw.insert(i)
+		length, distance := zw.longestMatch(i, end, repeat)
+		if length < zstdMinMatch {
+			// Look less closely the longer nothing has matched, so
+			// incompressible data goes quickly.
+			i += 1 + (i-literalStart)>>8
+			continue
+		}
+
+		for length < zstdNiceLength && i+1+zstdMinMatch <= end {
+			zw.insert(i + 1)
+			nextLength, nextDistance := zw.longestMatch(i+1, end, repeat)
+			if nextLength <= length {
+				break
+			}
+			i++
+			length, distance = nextLength, nextDistance
+		}
+
+		literals = append(literals, zw.hist[literalStart:i]...)
+		sequences = append(sequences, zstdSequence{
+			literalLength: i - literalStart,
+			matchLength:   length,
+			offset:        distance,
+		})
+
+		zw.insert(i + length - 1)
+		i += length
+		literalStart = i
+		repeat = distance
+	}
+
+	return append(literals, zw.hist[literalStart:end]...), sequences
+}